@@ -0,0 +1,111 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"forum/backend/config"
+	"forum/backend/tracing"
+	"time"
+)
+
+// Config - настройки auth-сервиса. Загружается через config.Load
+type Config struct {
+	HTTPAddr string `config:"http_addr" env:"HTTP_ADDR" flag:"http-addr" default:":8081" usage:"адрес HTTP сервера"`
+	GRPCAddr string `config:"grpc_addr" env:"GRPC_ADDR" flag:"grpc-addr" default:":50051" usage:"адрес gRPC сервера"`
+	// ForumGRPCAddr - адрес gRPC сервера forum-сервиса
+	ForumGRPCAddr string `config:"forum_grpc_addr" env:"FORUM_GRPC_ADDR" flag:"forum-grpc-addr" default:"localhost:50052" usage:"адрес gRPC forum-сервиса"`
+
+	// TrustedProxies - адреса и подсети обратных прокси, которым доверяются заголовки
+	// X-Forwarded-For и X-Real-IP. Если список пуст, IP клиента берется из соединения
+	TrustedProxies []string `config:"trusted_proxies" env:"TRUSTED_PROXIES" flag:"trusted-proxies" usage:"адреса и подсети доверенных прокси через запятую"`
+
+	// ShutdownTimeout - сколько ждать завершения текущих запросов при остановке
+	ShutdownTimeout time.Duration `config:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT" default:"15s"`
+
+	Database config.Database `config:"database"`
+
+	Health struct {
+		// MigrationsTable - таблица версий golang-migrate, по которой проверяется схема БД.
+		// Совпадает с таблицей, которую backend/migrate использует для сервиса auth. В существующей
+		// БД migrate при первом запуске переносит в нее версию из общей schema_migrations
+		MigrationsTable string        `config:"migrations_table" env:"MIGRATIONS_TABLE" default:"auth_schema_migrations"`
+		Timeout         time.Duration `config:"timeout" env:"HEALTH_TIMEOUT" default:"2s"`
+		// Interval - как часто обновляется статус grpc.health.v1
+		Interval time.Duration `config:"interval" env:"HEALTH_INTERVAL" default:"10s"`
+	} `config:"health"`
+
+	Tracing tracing.Config `config:"tracing"`
+
+	JWT struct {
+		// SigningKey - PEM файл ключа подписи (RSA или Ed25519). Без него используется временный ключ
+		SigningKey string `config:"signing_key" env:"JWT_SIGNING_KEY" flag:"jwt-signing-key" usage:"PEM файл ключа подписи JWT"`
+		// VerifyKeys - файлы предыдущих ключей, токены которых еще должны проверяться
+		VerifyKeys []string `config:"verify_keys" env:"JWT_VERIFY_KEYS" usage:"PEM файлы предыдущих ключей"`
+	} `config:"jwt"`
+
+	Mail struct {
+		// Mailer=smtp отправляет письма через SMTP, log пишет их в Dir. Без Dir
+		// в лог попадают только адрес и тема письма
+		Mailer       string `config:"mailer" env:"MAILER" default:"log"`
+		SMTPAddr     string `config:"smtp_addr" env:"SMTP_ADDR"`
+		SMTPUsername string `config:"smtp_username" env:"SMTP_USERNAME"`
+		SMTPPassword string `config:"smtp_password" env:"SMTP_PASSWORD" secret:"true"`
+		From         string `config:"from" env:"MAIL_FROM"`
+		Dir          string `config:"dir" env:"MAIL_DIR"`
+	} `config:"mail"`
+
+	PasswordResetURL     string `config:"password_reset_url" env:"PASSWORD_RESET_URL" default:"http://localhost:3000/reset-password"`
+	EmailVerificationURL string `config:"email_verification_url" env:"EMAIL_VERIFICATION_URL" default:"http://localhost:8081/auth/verify"`
+
+	// RequireAdminMFA запрещает администраторам без 2FA пользоваться правами роли
+	RequireAdminMFA bool   `config:"require_admin_mfa" env:"REQUIRE_ADMIN_MFA" flag:"require-admin-mfa"`
+	TOTPIssuer      string `config:"totp_issuer" env:"TOTP_ISSUER" default:"Forum"`
+
+	OIDC struct {
+		// Providers - провайдеры по именам: секции oidc.providers.<name> в файле
+		// или переменные OIDC_<NAME>_ISSUER, _CLIENT_ID и т.д.
+		Providers     map[string]OIDCProvider `config:"providers" env:"OIDC_"`
+		AutoProvision bool                    `config:"auto_provision" env:"OIDC_AUTO_PROVISION" default:"true"`
+	} `config:"oidc"`
+
+	// UserRestoreGracePeriod - сколько удаленный аккаунт можно восстановить
+	UserRestoreGracePeriod time.Duration `config:"user_restore_grace_period" env:"USER_RESTORE_GRACE_PERIOD" default:"720h"`
+}
+
+// OIDCProvider - параметры OIDC провайдера
+type OIDCProvider struct {
+	Issuer       string `config:"issuer" env:"ISSUER" required:"true"`
+	ClientID     string `config:"client_id" env:"CLIENT_ID" required:"true"`
+	ClientSecret string `config:"client_secret" env:"CLIENT_SECRET" secret:"true"`
+	RedirectURL  string `config:"redirect_url" env:"REDIRECT_URL" required:"true"`
+	// Scopes дополняют обязательный openid. Допускаются и разделенные пробелами значения
+	Scopes []string `config:"scopes" env:"SCOPES" default:"email,profile"`
+}
+
+// Validate проверяет адреса и настройки почты
+func (c *Config) Validate() error {
+	errs := []error{
+		config.CheckAddr("http_addr (HTTP_ADDR)", c.HTTPAddr),
+		config.CheckAddr("grpc_addr (GRPC_ADDR)", c.GRPCAddr),
+		config.CheckAddr("forum_grpc_addr (FORUM_GRPC_ADDR)", c.ForumGRPCAddr),
+	}
+	switch c.Mail.Mailer {
+	case "log":
+	case "smtp":
+		if c.Mail.SMTPAddr == "" || c.Mail.From == "" {
+			errs = append(errs, errors.New("mail: для MAILER=smtp нужны SMTP_ADDR и MAIL_FROM"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("mail.mailer (MAILER): неизвестный способ отправки %q", c.Mail.Mailer))
+	}
+	if c.UserRestoreGracePeriod <= 0 {
+		errs = append(errs, errors.New("user_restore_grace_period (USER_RESTORE_GRACE_PERIOD): должен быть больше нуля"))
+	}
+	if c.Health.Timeout <= 0 || c.Health.Interval <= 0 {
+		errs = append(errs, errors.New("health: timeout (HEALTH_TIMEOUT) и interval (HEALTH_INTERVAL) должны быть больше нуля"))
+	}
+	if c.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("shutdown_timeout (SHUTDOWN_TIMEOUT): должен быть больше нуля"))
+	}
+	return errors.Join(errs...)
+}
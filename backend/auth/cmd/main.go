@@ -1,12 +1,34 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"forum/backend/auth/internal/db"
+	"forum/backend/auth/internal/external"
 	"forum/backend/auth/internal/grpc"
+	"forum/backend/auth/internal/handlers"
+	"forum/backend/auth/internal/jwt"
 	"forum/backend/auth/internal/logger"
+	"forum/backend/auth/internal/mailer"
+	"forum/backend/auth/internal/middleware"
+	"forum/backend/auth/internal/models"
+	"forum/backend/auth/internal/oidc"
+	"forum/backend/config"
+	"forum/backend/health"
+	"forum/backend/lifecycle"
+	"forum/backend/metrics"
+	userpb "forum/backend/protos/go"
+	"forum/backend/tracing"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/rs/zerolog/log"
+	grpchealth "google.golang.org/grpc/health"
 )
 
 var router *gin.Engine
@@ -15,18 +37,168 @@ func main() {
 	logger.InitLogger()
 	log.Info().Msg("Starting auth service")
 
+	var cfg Config
+	printConfig := flag.Bool("print-config", false, "вывести действующую конфигурацию и выйти")
+	if err := config.Load(&cfg, flag.CommandLine, os.Args[1:]); err != nil {
+		log.Fatal().Err(err).Msg("Invalid configuration")
+	}
+	if *printConfig {
+		if err := config.Print(os.Stdout, &cfg); err != nil {
+			log.Fatal().Err(err).Msg("Failed to print configuration")
+		}
+		return
+	}
+	log.Info().Interface("config", config.Redacted(&cfg)).Msg("Configuration loaded")
+
+	shutdownTracing, err := tracing.Setup(context.Background(), "auth", cfg.Tracing)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to set up tracing")
+	}
+
+	if cfg.JWT.SigningKey != "" {
+		keys, err := jwt.LoadKeys(cfg.JWT.SigningKey, cfg.JWT.VerifyKeys)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to load JWT keys")
+		}
+		jwt.SetKeys(keys)
+	} else {
+		log.Warn().Msg("JWT_SIGNING_KEY is not set, using an ephemeral signing key")
+	}
+
+	if cfg.Mail.Mailer == "smtp" {
+		mailer.Default = mailer.NewSMTPMailer(cfg.Mail.SMTPAddr, cfg.Mail.SMTPUsername,
+			cfg.Mail.SMTPPassword, cfg.Mail.From)
+	} else {
+		if cfg.Mail.Dir == "" {
+			log.Warn().Msg("MAILER=log without MAIL_DIR, mail is not delivered and only its recipients are logged")
+		}
+		mailer.Default = mailer.NewLogMailer(cfg.Mail.Dir)
+	}
+	handlers.PasswordResetURL = cfg.PasswordResetURL
+	handlers.EmailVerificationURL = cfg.EmailVerificationURL
+
+	middleware.RequireAdminMFA = cfg.RequireAdminMFA
+	handlers.TOTPIssuer = cfg.TOTPIssuer
+
+	for name, p := range cfg.OIDC.Providers {
+		var scopes []string
+		for _, scope := range p.Scopes {
+			scopes = append(scopes, strings.Fields(scope)...)
+		}
+		handlers.RegisterOIDCProvider(oidc.NewProvider(oidc.Config{
+			Name:         name,
+			Issuer:       p.Issuer,
+			ClientID:     p.ClientID,
+			ClientSecret: p.ClientSecret,
+			RedirectURL:  p.RedirectURL,
+			Scopes:       scopes,
+		}))
+		log.Info().Str("provider", name).Str("issuer", p.Issuer).Msg("OIDC provider configured")
+	}
+	handlers.OIDCAutoProvision = cfg.OIDC.AutoProvision
+
+	models.UserRestoreGracePeriod = cfg.UserRestoreGracePeriod
+
 	log.Info().Msg("Setting up database connection")
-	db.SetupDB()
+	if err := db.Open(cfg.Database.DSN()); err != nil {
+		log.Fatal().Err(err).Msg("Failed to connect to database")
+	}
+	if err := metrics.RegisterDB(db.Db, cfg.Database.Name); err != nil {
+		log.Fatal().Err(err).Msg("Failed to register database metrics")
+	}
 
-	log.Info().Msg("Starting gRPC server")
-	go grpc.StartGRPCServer()
+	if err := external.ConnectForumService(cfg.ForumGRPCAddr); err != nil {
+		log.Fatal().Err(err).Msg("Failed to create forum service client")
+	}
+
+	// Проверка access токенов по denylist
+	jwt.IsRevoked = func(ctx context.Context, claims *jwt.Claims) (bool, error) {
+		var issuedAt time.Time
+		if claims.IssuedAt != nil {
+			issuedAt = claims.IssuedAt.Time
+		}
+		return models.IsTokenRevoked(ctx, claims.ID, claims.UserID, issuedAt)
+	}
+
+	checker := health.New(cfg.Health.Timeout)
+	checker.Add("database", health.Database(db.Db))
+	checker.Add("migrations", health.Migrations(db.Db, cfg.Health.MigrationsTable, db.SchemaVersion))
+	checker.Add("forum_grpc", external.PingForumService)
+	healthServer := grpchealth.NewServer()
 
 	log.Info().Msg("Initializing router")
 	router = gin.Default()
-	InitializeRoutes()
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Fatal().Err(err).Msg("Invalid trusted proxies")
+	}
+	InitializeRoutes(checker)
+
+	runner := lifecycle.New(cfg.ShutdownTimeout)
+	runner.Add(lifecycle.Worker("anonymizer", func(ctx context.Context) {
+		anonymizeDeletedUsers(ctx, time.Hour)
+	}))
+	runner.Add(lifecycle.GRPCServer("grpc", grpc.NewGRPCServer(healthServer), cfg.GRPCAddr))
+	runner.Add(lifecycle.HTTPServer("http", &http.Server{Addr: cfg.HTTPAddr, Handler: router}))
+	// Статус gRPC зависит только от БД, чтобы недоступность forum-сервиса не выводила
+	// auth из балансировки. Останавливается первым и переводит сервер в NOT_SERVING
+	runner.Add(lifecycle.Worker("health", func(ctx context.Context) {
+		checker.WatchGRPC(ctx, healthServer, cfg.Health.Interval,
+			[]string{userpb.AuthService_ServiceDesc.ServiceName}, "database", "migrations")
+	}))
+	runner.OnShutdown("database", db.Db.Close)
+	runner.OnShutdown("tracing", func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+		defer cancel()
+		return shutdownTracing(ctx)
+	})
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := runner.Run(ctx); err != nil {
+		log.Fatal().Err(err).Msg("Auth service failed")
+	}
+	log.Info().Msg("Auth service stopped")
+}
 
-	log.Info().Msg("Starting HTTP server on :8081")
-	if err := router.Run(":8081"); err != nil {
-		log.Fatal().Err(err).Msg("Failed to start HTTP server")
+// anonymizeDeletedUsers периодически обезличивает аккаунты, срок восстановления которых истек,
+// и сообщает forum-сервису об удалениях и обезличиваниях. Уведомление, которое forum-сервис
+// не принял, повторяется на следующем проходе
+func anonymizeDeletedUsers(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		ids, err := models.AnonymizeDeletedUsers(ctx)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to anonymize deleted users")
+		}
+		for _, id := range ids {
+			log.Info().Int("user_id", id).Msg("Deleted user anonymized")
+		}
+		notifyDeletedUsers(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// notifyDeletedUsers отправляет forum-сервису уведомления об удалении и обезличивании
+// пользователей, которые он еще не подтвердил
+func notifyDeletedUsers(ctx context.Context) {
+	notices, err := models.PendingDeletionNotices(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to load pending deletion notices")
+		return
+	}
+	for _, n := range notices {
+		if err := external.NotifyUserDeletedInBackend(ctx, n.UserID, n.Anonymized); err != nil {
+			log.Warn().Err(err).Int("user_id", n.UserID).Msg("Failed to notify forum service about deleted user")
+			continue
+		}
+		if err := models.MarkDeletionNotified(ctx, n); err != nil {
+			log.Error().Err(err).Int("user_id", n.UserID).Msg("Failed to mark deletion notice as sent")
+		}
 	}
 }
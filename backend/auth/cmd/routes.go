@@ -3,26 +3,58 @@ package main
 import (
 	"forum/backend/auth/internal/handlers"
 	"forum/backend/auth/internal/middleware"
+	"forum/backend/auth/internal/models"
+	"forum/backend/health"
+	"forum/backend/metrics"
+	"forum/backend/tracing"
 	"log"
 )
 
-func InitializeRoutes() {
+func InitializeRoutes(checker *health.Checker) {
 	log.Println("Initializing routes")
+	router.Use(tracing.HTTPMiddleware("auth"))
+	router.Use(metrics.HTTPMiddleware())
 	router.Use(middleware.CorsMiddleware())
 
+	router.GET("/healthz", health.Liveness)
+	router.GET("/readyz", checker.Readiness)
+	router.GET("/metrics", metrics.Handler())
+
+	router.GET("/.well-known/jwks.json", handlers.GetJWKS)
+
 	authRoutes := router.Group("/auth")
 	{
 		authRoutes.POST("/login", handlers.Login)
+		authRoutes.POST("/login/mfa", handlers.LoginMFA)
 		authRoutes.POST("/register", handlers.Register)
+		authRoutes.POST("/refresh", handlers.Refresh)
+		authRoutes.POST("/forgot", handlers.ForgotPassword)
+		authRoutes.POST("/reset", handlers.ResetPassword)
+		authRoutes.GET("/verify", handlers.VerifyEmail)
+		authRoutes.POST("/verify/resend", middleware.AuthMiddleware(), handlers.ResendVerification)
+		authRoutes.POST("/logout", middleware.AuthMiddleware(), handlers.Logout)
+		authRoutes.POST("/logout-all", middleware.AuthMiddleware(), handlers.LogoutAll)
+		authRoutes.GET("/oidc", handlers.GetOIDCProviders)
+		authRoutes.GET("/oidc/:provider/login", handlers.OIDCLogin)
+		authRoutes.GET("/oidc/:provider/callback", handlers.OIDCCallback)
 	}
 
 	userRoutes := router.Group("/users")
 	userRoutes.Use(middleware.AuthMiddleware())
 	{
-		userRoutes.GET("", handlers.GetAllUsers)
+		userRoutes.GET("", middleware.RequirePermission(models.PermUsersList), handlers.GetAllUsers)
 		userRoutes.GET("/:user_id", handlers.GetUser)
-		userRoutes.POST("", handlers.PostNewUser)
-		userRoutes.DELETE("/:user_id", handlers.DeleteUser)
+		userRoutes.POST("/me/password", handlers.ChangePassword)
+		userRoutes.POST("/me/2fa/setup", handlers.SetupTOTP)
+		userRoutes.POST("/me/2fa/enable", handlers.EnableTOTP)
+		userRoutes.POST("/me/2fa/disable", handlers.DisableTOTP)
+		userRoutes.POST("/me/2fa/recovery-codes", handlers.RegenerateRecoveryCodes)
+		userRoutes.POST("", middleware.RequirePermission(models.PermUsersCreate), handlers.PostNewUser)
+		userRoutes.POST("/:user_id/restore", middleware.RequirePermission(models.PermUsersDelete), handlers.RestoreUser)
+		userRoutes.POST("/:user_id/unlock", middleware.RequirePermission(models.PermUsersUpdate), handlers.UnlockUser)
+		// Свой профиль можно менять без users:update, проверка в обработчике
 		userRoutes.PUT("/:user_id", handlers.PutUser)
+		// Свой аккаунт можно удалить без users:delete, проверка в обработчике
+		userRoutes.DELETE("/:user_id", handlers.DeleteUser)
 	}
 }
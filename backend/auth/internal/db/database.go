@@ -2,21 +2,29 @@ package db
 
 import (
 	"database/sql"
+	"fmt"
+	"forum/backend/tracing"
+
 	_ "github.com/lib/pq"
-	"log"
 )
 
 var Db *sql.DB
 
-func SetupDB() {
+// SchemaVersion - номер последней миграции auth-сервиса, с которой совместим код.
+// Обновляется вместе с добавлением миграции
+const SchemaVersion = 9
+
+// Open подключается к БД по строке подключения dsn и проверяет соединение.
+// Запросы с контекстом трассировки записываются как спаны
+func Open(dsn string) error {
 	var err error
-	connStr := "user=postgres password=Sashaezhak2006 dbname=forumDB sslmode=disable"
-	Db, err = sql.Open("postgres", connStr)
+	Db, err = tracing.OpenDB("postgres", dsn)
 	if err != nil {
-		log.Fatal("Ошибка подключения к БД:", err)
+		return fmt.Errorf("ошибка подключения к БД: %w", err)
 	}
 
 	if err = Db.Ping(); err != nil {
-		log.Fatal("БД недоступна:", err)
+		return fmt.Errorf("БД недоступна: %w", err)
 	}
+	return nil
 }
@@ -2,23 +2,103 @@ package external
 
 import (
 	"context"
+	"forum/backend/health"
+	"forum/backend/metrics"
 	"forum/backend/protos/go"
+	"forum/backend/tracing"
 	"log"
+	"sync"
+	"time"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
 )
 
-func GetUserCommentsFromBackend(userID int) ([]*userpb.Comment, error) {
-	conn, err := grpc.Dial("localhost:50052", grpc.WithInsecure())
+const (
+	defaultForumServiceAddr = "localhost:50052"
+	forumCallTimeout        = 3 * time.Second
+)
+
+var (
+	forumConn   *grpc.ClientConn
+	forumConnMu sync.Mutex
+)
+
+// ConnectForumService устанавливает общее соединение с forum-сервисом по адресу addr.
+// Предыдущее соединение, если оно было, закрывается
+func ConnectForumService(addr string) error {
+	conn, err := grpc.NewClient(addr, dialOptions()...)
+	if err != nil {
+		return err
+	}
+
+	forumConnMu.Lock()
+	old := forumConn
+	forumConn = conn
+	forumConnMu.Unlock()
+
+	if old != nil {
+		old.Close()
+	}
+	return nil
+}
+
+// dialOptions - параметры соединения с forum-сервисом
+func dialOptions() []grpc.DialOption {
+	return []grpc.DialOption{
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUnaryInterceptor(metrics.UnaryClientInterceptor()),
+		tracing.DialOption(),
+	}
+}
+
+// forumConnection возвращает общее долгоживущее соединение с forum-сервисом.
+// Если соединение еще не установлено, оно создается с адресом по умолчанию
+func forumConnection() (*grpc.ClientConn, error) {
+	forumConnMu.Lock()
+	defer forumConnMu.Unlock()
+
+	if forumConn == nil {
+		conn, err := grpc.NewClient(defaultForumServiceAddr, dialOptions()...)
+		if err != nil {
+			return nil, err
+		}
+		forumConn = conn
+	}
+	return forumConn, nil
+}
+
+// forumClient возвращает клиент forum-сервиса поверх общего соединения
+func forumClient() (userpb.BackendServiceClient, error) {
+	conn, err := forumConnection()
+	if err != nil {
+		return nil, err
+	}
+	return userpb.NewBackendServiceClient(conn), nil
+}
+
+// PingForumService проверяет, что gRPC сервер forum-сервиса доступен
+func PingForumService(ctx context.Context) error {
+	conn, err := forumConnection()
+	if err != nil {
+		return err
+	}
+	return health.PingGRPC(ctx, conn)
+}
+
+// GetUserCommentsFromBackend возвращает комментарии пользователя из forum-сервиса.
+// Вызов продолжает трассировку из ctx
+func GetUserCommentsFromBackend(ctx context.Context, userID int) ([]*userpb.Comment, error) {
+	client, err := forumClient()
 	if err != nil {
 		log.Printf("Failed to connect to forum service: %v", err)
 		return nil, err
 	}
-	defer conn.Close()
 
-	client := userpb.NewBackendServiceClient(conn)
+	ctx, cancel := context.WithTimeout(ctx, forumCallTimeout)
+	defer cancel()
 
-	resp, err := client.GetUserComments(context.Background(), &userpb.UserCommentsRequest{UserId: int32(userID)})
+	resp, err := client.GetUserComments(ctx, &userpb.UserCommentsRequest{UserId: int32(userID)})
 	if err != nil {
 		log.Printf("Error calling GetUserComments: %v", err)
 		return nil, err
@@ -26,3 +106,44 @@ func GetUserCommentsFromBackend(userID int) ([]*userpb.Comment, error) {
 
 	return resp.GetComments(), nil
 }
+
+// InvalidateUserNameInBackend сообщает forum-сервису, что имя пользователя изменилось.
+// Вызов продолжает трассировку из ctx, но не прерывается его отменой: изменение уже сохранено
+func InvalidateUserNameInBackend(ctx context.Context, userID int) error {
+	client, err := forumClient()
+	if err != nil {
+		log.Printf("Failed to connect to forum service: %v", err)
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), forumCallTimeout)
+	defer cancel()
+
+	_, err = client.InvalidateUserName(ctx, &userpb.UserRequest{UserId: int32(userID)})
+	if err != nil {
+		log.Printf("Error calling InvalidateUserName: %v", err)
+		return err
+	}
+	return nil
+}
+
+// NotifyUserDeletedInBackend сообщает forum-сервису, что пользователь удален
+// или что его данные обезличены после срока восстановления.
+// Вызов продолжает трассировку из ctx, но не прерывается его отменой
+func NotifyUserDeletedInBackend(ctx context.Context, userID int, anonymized bool) error {
+	client, err := forumClient()
+	if err != nil {
+		log.Printf("Failed to connect to forum service: %v", err)
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), forumCallTimeout)
+	defer cancel()
+
+	_, err = client.UserDeleted(ctx, &userpb.UserDeletedRequest{UserId: int32(userID), Anonymized: anonymized})
+	if err != nil {
+		log.Printf("Error calling UserDeleted: %v", err)
+		return err
+	}
+	return nil
+}
@@ -2,41 +2,93 @@ package grpc
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"forum/backend/auth/internal/jwt"
 	"forum/backend/auth/internal/models"
+	"forum/backend/metrics"
 	"forum/backend/protos/go"
+	"forum/backend/tracing"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/reflection"
-	"log"
-	"net"
-	"os"
+	"google.golang.org/grpc/status"
 )
 
-func StartGRPCServer() {
-	grpcServer := grpc.NewServer()
+// NewGRPCServer создает gRPC сервер auth-сервиса. Статус grpc.health.v1
+// берется из healthServer
+func NewGRPCServer(healthServer *health.Server) *grpc.Server {
+	grpcServer := grpc.NewServer(
+		grpc.UnaryInterceptor(metrics.UnaryServerInterceptor()),
+		tracing.ServerOption(),
+	)
 	userpb.RegisterAuthServiceServer(grpcServer, &server{})
+	healthpb.RegisterHealthServer(grpcServer, healthServer)
 	reflection.Register(grpcServer)
+	return grpcServer
+}
 
-	listener, err := net.Listen("tcp", ":50051")
+type server struct {
+	userpb.UnimplementedAuthServiceServer
+}
+
+func (s *server) GetUserName(ctx context.Context, req *userpb.UserRequest) (*userpb.UserResponse, error) {
+	username, err := models.GetUsernameByUserID(ctx, int(req.GetUserId()))
 	if err != nil {
-		log.Fatalf("Failed to listen: %v", err)
-		os.Exit(1)
+		return nil, err
 	}
+	return &userpb.UserResponse{UserName: username}, nil
+}
 
-	log.Println("gRPC server started on :50051")
+func (s *server) GetUserNames(ctx context.Context, req *userpb.UserNamesRequest) (*userpb.UserNamesResponse, error) {
+	ids := make([]int, len(req.GetUserIds()))
+	for i, id := range req.GetUserIds() {
+		ids[i] = int(id)
+	}
 
-	if err := grpcServer.Serve(listener); err != nil {
-		log.Fatalf("Failed to serve: %v", err)
+	usernames, err := models.GetUsernamesByUserIDs(ctx, ids)
+	if err != nil {
+		return nil, err
 	}
-}
 
-type server struct {
-	userpb.UnimplementedAuthServiceServer
+	res := make(map[int32]string, len(usernames))
+	for id, username := range usernames {
+		res[int32(id)] = username
+	}
+	return &userpb.UserNamesResponse{UserNames: res}, nil
 }
 
-func (s *server) GetUserName(ctx context.Context, req *userpb.UserRequest) (*userpb.UserResponse, error) {
-	username, err := models.GetUsernameByUserID(int(req.GetUserId()))
+func (s *server) ValidateToken(ctx context.Context, req *userpb.ValidateTokenRequest) (*userpb.ValidateTokenResponse, error) {
+	claims, err := jwt.ValidateToken(ctx, req.GetToken())
+	if err != nil {
+		if errors.Is(err, jwt.ErrRevocationUnavailable) {
+			return nil, status.Error(codes.Unavailable, "token revocation check unavailable")
+		}
+		return nil, status.Error(codes.Unauthenticated, "invalid token")
+	}
+
+	user, err := models.GetUserByID(ctx, claims.UserID)
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, status.Error(codes.Unauthenticated, "user not found")
+		}
 		return nil, err
 	}
-	return &userpb.UserResponse{UserName: username}, nil
+
+	// Роль берется из БД, а не из токена, чтобы смена роли действовала сразу
+	permissions, err := models.GetRolePermissions(ctx, user.Role)
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, "permission lookup unavailable")
+	}
+
+	return &userpb.ValidateTokenResponse{
+		UserId:        int32(user.ID),
+		UserName:      user.Username,
+		IsAdmin:       user.IsAdmin,
+		Role:          user.Role,
+		Permissions:   permissions,
+		EmailVerified: user.IsEmailVerified(),
+	}, nil
 }
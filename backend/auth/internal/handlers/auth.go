@@ -1,6 +1,8 @@
 package handlers
 
 import (
+	"context"
+	"errors"
 	"forum/backend/auth/internal/jwt"
 	"forum/backend/auth/internal/logger"
 	"forum/backend/auth/internal/models"
@@ -16,6 +18,26 @@ func init() {
 	log1 = logger.GetLogger("auth_handler")
 }
 
+// issueTokens выпускает access токен и refresh токен новой сессии.
+// Для пользователя с 2FA вызывается только после проверки второго фактора
+func issueTokens(ctx context.Context, user *models.User) (gin.H, error) {
+	token, err := jwt.GenerateToken(user.ID, user.Username, user.Role, user.IsEmailVerified(), user.IsTOTPEnabled())
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := models.CreateRefreshToken(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return gin.H{
+		"token":         token,
+		"refresh_token": refreshToken,
+		"expires_in":    int(jwt.AccessTokenTTL.Seconds()),
+	}, nil
+}
+
 type LoginRequest struct {
 	Username string `json:"username" binding:"required"`
 	Password string `json:"password" binding:"required"`
@@ -26,7 +48,7 @@ func Login(c *gin.Context) {
 	if err := c.ShouldBindJSON(&req); err != nil {
 		log1.Error().
 			Err(err).
-			Interface("request", req).
+			Str("username", req.Username).
 			Msg("Invalid login request format")
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -36,17 +58,59 @@ func Login(c *gin.Context) {
 		Str("username", req.Username).
 		Msg("Attempting user login")
 
-	user, err := models.AuthenticateUser(req.Username, req.Password)
+	if !checkLoginAllowed(c, req.Username) {
+		return
+	}
+
+	user, err := models.AuthenticateUser(c.Request.Context(), req.Username, req.Password)
 	if err != nil {
-		log1.Error().
-			Err(err).
+		if !errors.Is(err, models.ErrInvalidCredentials) {
+			log1.Error().
+				Err(err).
+				Str("username", req.Username).
+				Msg("Authentication failed")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to authenticate"})
+			return
+		}
+		log1.Warn().
 			Str("username", req.Username).
-			Msg("Authentication failed")
+			Msg("Invalid credentials")
+		recordLoginFailure(c, models.AuditEntry{Event: models.AuditLoginFailed, Username: req.Username})
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
 	}
 
-	token, err := jwt.GenerateToken(user.ID, user.Username)
+	completeLogin(c, user)
+}
+
+// completeLogin завершает вход пользователя, прошедшего первый фактор: выдает токены сессии
+// или, если включена 2FA, токен mfa_pending
+func completeLogin(c *gin.Context, user *models.User) {
+	// С включенной 2FA первый фактор обменивается только на токен mfa_pending.
+	// Счетчик неудач не сбрасывается до проверки второго фактора
+	if user.IsTOTPEnabled() {
+		mfaToken, err := jwt.GenerateMFAToken(user.ID)
+		if err != nil {
+			log1.Error().
+				Err(err).
+				Int("user_id", user.ID).
+				Msg("Failed to generate MFA token")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+			return
+		}
+
+		log1.Info().
+			Int("user_id", user.ID).
+			Msg("First factor accepted, second factor required")
+		c.JSON(http.StatusOK, gin.H{
+			"mfa_required": true,
+			"mfa_token":    mfaToken,
+			"expires_in":   int(jwt.MFATokenTTL.Seconds()),
+		})
+		return
+	}
+
+	tokens, err := issueTokens(c.Request.Context(), user)
 	if err != nil {
 		log1.Error().
 			Err(err).
@@ -56,61 +120,66 @@ func Login(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
 	}
+	resetLoginFailures(c.Request.Context(), user)
+	logins.WithLabelValues("success").Inc()
 
 	log1.Info().
 		Int("user_id", user.ID).
 		Str("username", user.Username).
 		Msg("User successfully logged in")
 
-	c.JSON(http.StatusOK, gin.H{
-		"token": token,
-		"user": gin.H{
-			"id":       user.ID,
-			"username": user.Username,
-			"email":    user.Email,
-			"is_admin": user.IsAdmin,
-		},
-	})
+	tokens["user"] = privateUser(user)
+	c.JSON(http.StatusOK, tokens)
 }
 
 func Register(c *gin.Context) {
-	var newUser models.User
-	if err := c.ShouldBindJSON(&newUser); err != nil {
+	var req RegisterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
 		log1.Error().
 			Err(err).
-			Interface("user", newUser).
+			Str("username", req.Username).
 			Msg("Invalid registration request format")
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
 	log1.Info().
-		Str("username", newUser.Username).
-		Str("email", newUser.Email).
+		Str("username", req.Username).
+		Str("email", req.Email).
 		Msg("Attempting user registration")
 
-	hashedPassword, err := models.HashPassword(newUser.PasswordHash)
+	hashedPassword, err := models.HashPassword(req.Password)
 	if err != nil {
 		log1.Error().
 			Err(err).
-			Str("username", newUser.Username).
+			Str("username", req.Username).
 			Msg("Failed to hash password")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
 		return
 	}
-	newUser.PasswordHash = hashedPassword
 
-	userID, err := models.AddUser(&newUser)
+	// Роль при регистрации не выбирается
+	userID, err := models.AddUser(c.Request.Context(), &models.User{
+		Name:         req.Name,
+		Username:     req.Username,
+		Email:        req.Email,
+		PasswordHash: hashedPassword,
+		Role:         models.RoleUser,
+	})
 	if err != nil {
+		if errors.Is(err, models.ErrUserExists) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Username or email already taken"})
+			return
+		}
 		log1.Error().
 			Err(err).
-			Str("username", newUser.Username).
+			Str("username", req.Username).
 			Msg("Failed to add user to database")
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to register user"})
 		return
 	}
 
-	user, err := models.GetUserByID(userID)
+	user, err := models.GetUserByID(c.Request.Context(), userID)
 	if err != nil {
 		log1.Error().
 			Err(err).
@@ -120,7 +189,7 @@ func Register(c *gin.Context) {
 		return
 	}
 
-	token, err := jwt.GenerateToken(user.ID, user.Username)
+	tokens, err := issueTokens(c.Request.Context(), user)
 	if err != nil {
 		log1.Error().
 			Err(err).
@@ -131,18 +200,157 @@ func Register(c *gin.Context) {
 		return
 	}
 
+	// Регистрация не зависит от доставки письма: его можно запросить повторно
+	if _, err := sendVerificationEmail(c.Request.Context(), user); err != nil {
+		log1.Warn().
+			Err(err).
+			Int("user_id", user.ID).
+			Msg("Failed to send verification email")
+	}
+
+	registrations.WithLabelValues("password").Inc()
 	log1.Info().
 		Int("user_id", user.ID).
 		Str("username", user.Username).
 		Msg("User successfully registered")
 
-	c.JSON(http.StatusCreated, gin.H{
-		"token": token,
-		"user": gin.H{
-			"id":       user.ID,
-			"username": user.Username,
-			"email":    user.Email,
-			"is_admin": user.IsAdmin,
-		},
+	tokens["user"] = privateUser(user)
+	c.JSON(http.StatusCreated, tokens)
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+func Refresh(c *gin.Context) {
+	var req RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log1.Error().
+			Err(err).
+			Msg("Invalid refresh request format")
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, refreshToken, err := models.RotateRefreshToken(c.Request.Context(), req.RefreshToken)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRefreshTokenReused):
+			log1.Warn().
+				Err(err).
+				Msg("Refresh token reuse detected, session revoked")
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+		case errors.Is(err, models.ErrRefreshTokenInvalid):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+		default:
+			log1.Error().
+				Err(err).
+				Msg("Failed to rotate refresh token")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh token"})
+		}
+		return
+	}
+
+	user, err := models.GetUserByID(c.Request.Context(), userID)
+	if err != nil {
+		log1.Error().
+			Err(err).
+			Int("user_id", userID).
+			Msg("Failed to get user for refresh")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+		return
+	}
+
+	token, err := jwt.GenerateToken(user.ID, user.Username, user.Role, user.IsEmailVerified(), user.IsTOTPEnabled())
+	if err != nil {
+		log1.Error().
+			Err(err).
+			Int("user_id", user.ID).
+			Msg("Failed to generate JWT token")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	log1.Info().
+		Int("user_id", user.ID).
+		Msg("Token refreshed")
+
+	c.JSON(http.StatusOK, gin.H{
+		"token":         token,
+		"refresh_token": refreshToken,
+		"expires_in":    int(jwt.AccessTokenTTL.Seconds()),
 	})
 }
+
+// revokeCurrentToken добавляет access токен текущего запроса в denylist
+func revokeCurrentToken(c *gin.Context) error {
+	tokenID := c.GetString("token_id")
+	if tokenID == "" {
+		return nil
+	}
+	return models.RevokeAccessToken(c.Request.Context(), tokenID, c.GetTime("token_expires_at"))
+}
+
+// Logout завершает текущую сессию: отзывает refresh токен (если передан) и текущий access токен
+func Logout(c *gin.Context) {
+	var req struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	// Тело запроса необязательно
+	_ = c.ShouldBindJSON(&req)
+
+	userID := c.GetInt("user_id")
+	if req.RefreshToken != "" {
+		err := models.RevokeRefreshToken(c.Request.Context(), req.RefreshToken, userID)
+		if err != nil && !errors.Is(err, models.ErrRefreshTokenInvalid) {
+			log1.Error().
+				Err(err).
+				Int("user_id", userID).
+				Msg("Failed to revoke refresh token")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to logout"})
+			return
+		}
+	}
+
+	if err := revokeCurrentToken(c); err != nil {
+		log1.Error().
+			Err(err).
+			Int("user_id", userID).
+			Msg("Failed to revoke access token")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to logout"})
+		return
+	}
+
+	log1.Info().
+		Int("user_id", userID).
+		Msg("User logged out")
+	c.Status(http.StatusNoContent)
+}
+
+// LogoutAll завершает все сессии пользователя
+func LogoutAll(c *gin.Context) {
+	userID := c.GetInt("user_id")
+
+	if err := models.RevokeAllRefreshTokens(c.Request.Context(), userID); err != nil {
+		log1.Error().
+			Err(err).
+			Int("user_id", userID).
+			Msg("Failed to revoke refresh tokens")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to logout"})
+		return
+	}
+
+	if err := models.RevokeAllAccessTokens(c.Request.Context(), userID); err != nil {
+		log1.Error().
+			Err(err).
+			Int("user_id", userID).
+			Msg("Failed to revoke access tokens")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to logout"})
+		return
+	}
+
+	log1.Info().
+		Int("user_id", userID).
+		Msg("User logged out from all sessions")
+	c.Status(http.StatusNoContent)
+}
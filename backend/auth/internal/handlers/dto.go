@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"forum/backend/auth/internal/models"
+	"time"
+)
+
+// RegisterRequest - тело POST /auth/register. Пароль передается в открытом виде
+// и хешируется на сервере
+type RegisterRequest struct {
+	Name     string `json:"name" binding:"required"`
+	Username string `json:"username" binding:"required"`
+	Email    string `json:"email" binding:"required,email"`
+	Password string `json:"password" binding:"required,min=8"`
+}
+
+// CreateUserRequest - тело POST /users
+type CreateUserRequest struct {
+	Name     string `json:"name" binding:"required"`
+	Username string `json:"username" binding:"required"`
+	Email    string `json:"email" binding:"required,email"`
+	Password string `json:"password" binding:"required,min=8"`
+	Role     string `json:"role"`
+}
+
+// UpdateUserRequest - тело PUT /users/:user_id. Не переданные поля не меняются.
+// Свой пароль меняется через POST /users/me/password, чужой - полем password
+type UpdateUserRequest struct {
+	Name     *string `json:"name" binding:"omitempty,min=1"`
+	Username *string `json:"username" binding:"omitempty,min=1"`
+	Email    *string `json:"email" binding:"omitempty,email"`
+	Role     *string `json:"role"`
+	Password *string `json:"password" binding:"omitempty,min=8"`
+}
+
+// DeleteUserRequest - тело DELETE /users/:user_id. При удалении своего аккаунта
+// нужно подтвердить текущий пароль, если он задан
+type DeleteUserRequest struct {
+	Password string `json:"password"`
+}
+
+// PublicUser - профиль, который видят все пользователи
+type PublicUser struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	Username  string    `json:"username"`
+	IsAdmin   bool      `json:"is_admin"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// PrivateUser - профиль для самого пользователя и администраторов
+type PrivateUser struct {
+	PublicUser
+	Email         string    `json:"email"`
+	EmailVerified bool      `json:"email_verified"`
+	TOTPEnabled   bool      `json:"totp_enabled"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+func publicUser(u *models.User) PublicUser {
+	return PublicUser{
+		ID:        u.ID,
+		Name:      u.Name,
+		Username:  u.Username,
+		IsAdmin:   u.IsAdmin,
+		Role:      u.Role,
+		CreatedAt: u.CreatedAt,
+	}
+}
+
+func privateUser(u *models.User) PrivateUser {
+	return PrivateUser{
+		PublicUser:    publicUser(u),
+		Email:         u.Email,
+		EmailVerified: u.IsEmailVerified(),
+		TOTPEnabled:   u.IsTOTPEnabled(),
+		UpdatedAt:     u.UpdatedAt,
+	}
+}
@@ -0,0 +1,14 @@
+package handlers
+
+import (
+	"forum/backend/auth/internal/jwt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetJWKS отдает открытые ключи, которыми другие сервисы проверяют access токены
+func GetJWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, jwt.PublicJWKS())
+}
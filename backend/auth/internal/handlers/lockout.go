@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"context"
+	"forum/backend/auth/internal/models"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// audit пишет событие в журнал безопасности. Ошибка записи не прерывает запрос
+func audit(c *gin.Context, entry models.AuditEntry) {
+	entry.IP = c.ClientIP()
+	if err := models.WriteAudit(c.Request.Context(), entry); err != nil {
+		log1.Error().
+			Err(err).
+			Str("event", entry.Event).
+			Msg("Failed to write audit entry")
+	}
+}
+
+// checkLoginAllowed отвечает 429, если вход для имени пользователя или IP временно заблокирован.
+// Блокировка не зависит от существования пользователя, поэтому не раскрывает его
+func checkLoginAllowed(c *gin.Context, username string) bool {
+	wait, err := models.LoginBlockedFor(c.Request.Context(), username, c.ClientIP())
+	if err != nil {
+		log1.Error().
+			Err(err).
+			Msg("Failed to check login throttle")
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Login temporarily unavailable"})
+		return false
+	}
+	if wait == 0 {
+		return true
+	}
+
+	logins.WithLabelValues("blocked").Inc()
+	audit(c, models.AuditEntry{Event: models.AuditLoginBlocked, Username: username})
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many failed login attempts, try again later"})
+	return false
+}
+
+// recordLoginFailure учитывает неудачную попытку входа и пишет ее в журнал
+func recordLoginFailure(c *gin.Context, entry models.AuditEntry) {
+	logins.WithLabelValues("failure").Inc()
+	audit(c, entry)
+
+	locked, err := models.RecordLoginFailure(c.Request.Context(), entry.Username, c.ClientIP())
+	if err != nil {
+		log1.Error().
+			Err(err).
+			Str("username", entry.Username).
+			Msg("Failed to record login failure")
+		return
+	}
+	if locked {
+		log1.Warn().
+			Str("username", entry.Username).
+			Msg("Account locked after failed login attempts")
+		audit(c, models.AuditEntry{Event: models.AuditAccountLocked, UserID: entry.UserID, Username: entry.Username})
+	}
+}
+
+// resetLoginFailures сбрасывает счетчик неудачных попыток после успешного входа
+func resetLoginFailures(ctx context.Context, user *models.User) {
+	if err := models.ResetLoginFailures(ctx, user.Username); err != nil {
+		log1.Error().
+			Err(err).
+			Int("user_id", user.ID).
+			Msg("Failed to reset login failures")
+	}
+}
+
+// UnlockUser снимает блокировку входа с пользователя
+func UnlockUser(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	user, err := models.GetUserByID(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
+	if err := models.ResetLoginFailures(c.Request.Context(), user.Username); err != nil {
+		log1.Error().
+			Err(err).
+			Int("user_id", userID).
+			Msg("Failed to unlock user")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unlock user"})
+		return
+	}
+
+	audit(c, models.AuditEntry{
+		Event:    models.AuditAccountUnlocked,
+		UserID:   &user.ID,
+		Username: user.Username,
+		Detail:   "unlocked by user " + strconv.Itoa(c.GetInt("user_id")),
+	})
+	log1.Info().
+		Int("user_id", userID).
+		Int("admin_id", c.GetInt("user_id")).
+		Msg("User unlocked")
+	c.Status(http.StatusNoContent)
+}
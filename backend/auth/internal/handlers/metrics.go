@@ -0,0 +1,20 @@
+package handlers
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	// registrations считает новых пользователей по способу регистрации: password или oidc
+	registrations = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "auth_registrations_total",
+		Help: "Количество зарегистрированных пользователей",
+	}, []string{"method"})
+
+	// logins считает попытки входа по результату: success, failure или blocked
+	logins = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "auth_logins_total",
+		Help: "Количество попыток входа",
+	}, []string{"result"})
+)
@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"forum/backend/auth/internal/models"
+	"forum/backend/auth/internal/oidc"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+var oidcProviders = map[string]*oidc.Provider{}
+
+// OIDCAutoProvision разрешает создавать пользователя при первом входе через провайдера
+var OIDCAutoProvision = true
+
+// RegisterOIDCProvider добавляет провайдера для входа через /auth/oidc/:provider.
+// Вызывается при старте сервиса
+func RegisterOIDCProvider(p *oidc.Provider) {
+	oidcProviders[p.Name] = p
+}
+
+// GetOIDCProviders возвращает имена настроенных провайдеров
+func GetOIDCProviders(c *gin.Context) {
+	names := make([]string, 0, len(oidcProviders))
+	for name := range oidcProviders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	c.JSON(http.StatusOK, gin.H{"providers": names})
+}
+
+func oidcProvider(c *gin.Context) (*oidc.Provider, bool) {
+	p, ok := oidcProviders[c.Param("provider")]
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "unknown provider"})
+	}
+	return p, ok
+}
+
+// oidcStateCookie привязывает state к браузеру, начавшему вход. Без нее злоумышленник
+// мог бы подсунуть жертве ссылку на callback со своими code и state и войти ею в свой аккаунт
+const oidcStateCookie = "oidc_state"
+
+func hashState(state string) string {
+	sum := sha256.Sum256([]byte(state))
+	return hex.EncodeToString(sum[:])
+}
+
+// setStateCookie сохраняет хеш state в HttpOnly cookie. SameSite=Lax, так как провайдер
+// возвращает пользователя обычным переходом с другого сайта
+func setStateCookie(c *gin.Context, p *oidc.Provider, value string, maxAge int) {
+	// Cookie нужна только на callback, адрес которого задан у провайдера
+	path := "/"
+	if u, err := url.Parse(p.RedirectURL); err == nil && u.Path != "" {
+		path = u.Path
+	}
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcStateCookie, value, maxAge, path, "", strings.HasPrefix(p.RedirectURL, "https://"), true)
+}
+
+// OIDCLogin перенаправляет пользователя на страницу входа провайдера
+func OIDCLogin(c *gin.Context) {
+	p, ok := oidcProvider(c)
+	if !ok {
+		return
+	}
+
+	state, nonce, verifier := oidc.NewState(), oidc.NewState(), oidc.NewCodeVerifier()
+	authURL, err := p.AuthCodeURL(c.Request.Context(), state, nonce, verifier)
+	if err != nil {
+		log1.Error().
+			Err(err).
+			Str("provider", p.Name).
+			Msg("Failed to build OIDC authorization URL")
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Identity provider unavailable"})
+		return
+	}
+
+	if err := models.CreateOIDCState(c.Request.Context(), p.Name, state, nonce, verifier); err != nil {
+		log1.Error().
+			Err(err).
+			Str("provider", p.Name).
+			Msg("Failed to save OIDC state")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start login"})
+		return
+	}
+
+	setStateCookie(c, p, hashState(state), int(models.OIDCStateTTL.Seconds()))
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// OIDCCallback завершает вход через провайдера: проверяет state и его cookie, обменивает код
+// на id_token и выдает те же токены, что и вход по паролю
+func OIDCCallback(c *gin.Context) {
+	p, ok := oidcProvider(c)
+	if !ok {
+		return
+	}
+
+	if providerErr := c.Query("error"); providerErr != "" {
+		log1.Warn().
+			Str("provider", p.Name).
+			Str("error", providerErr).
+			Str("description", c.Query("error_description")).
+			Msg("Identity provider returned error")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Login cancelled or denied by identity provider"})
+		return
+	}
+
+	code, state := c.Query("code"), c.Query("state")
+	if code == "" || state == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "code and state are required"})
+		return
+	}
+
+	// state должен совпадать с выданным этому браузеру, cookie одноразовая
+	cookie, _ := c.Cookie(oidcStateCookie)
+	setStateCookie(c, p, "", -1)
+	if subtle.ConstantTimeCompare([]byte(cookie), []byte(hashState(state))) != 1 {
+		log1.Warn().
+			Str("provider", p.Name).
+			Msg("OIDC state does not match browser cookie")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired login state"})
+		return
+	}
+
+	nonce, verifier, err := models.ConsumeOIDCState(c.Request.Context(), p.Name, state)
+	if err != nil {
+		if errors.Is(err, models.ErrOIDCStateInvalid) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired login state"})
+			return
+		}
+		log1.Error().
+			Err(err).
+			Msg("Failed to get OIDC state")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to complete login"})
+		return
+	}
+
+	claims, err := p.Exchange(c.Request.Context(), code, verifier, nonce)
+	if err != nil {
+		log1.Warn().
+			Err(err).
+			Str("provider", p.Name).
+			Msg("OIDC code exchange failed")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Identity provider login failed"})
+		return
+	}
+
+	user, created, err := models.FindOrProvisionUser(c.Request.Context(), models.ExternalIdentity{
+		Provider:          p.Name,
+		Subject:           claims.Subject,
+		Email:             claims.Email,
+		EmailVerified:     claims.EmailVerified,
+		Name:              claims.Name,
+		PreferredUsername: claims.PreferredUsername,
+	}, OIDCAutoProvision)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrIdentityEmailTaken):
+			c.JSON(http.StatusConflict, gin.H{"error": "An account with this email already exists, sign in with password"})
+		case errors.Is(err, models.ErrIdentityEmailRequired):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Identity provider did not share an email address"})
+		case errors.Is(err, models.ErrProvisioningDisabled):
+			c.JSON(http.StatusForbidden, gin.H{"error": "No account is linked to this identity"})
+		default:
+			log1.Error().
+				Err(err).
+				Str("provider", p.Name).
+				Msg("Failed to find or provision OIDC user")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to complete login"})
+		}
+		return
+	}
+
+	if created {
+		registrations.WithLabelValues("oidc").Inc()
+		log1.Info().
+			Int("user_id", user.ID).
+			Str("provider", p.Name).
+			Msg("User provisioned from identity provider")
+	}
+	completeLogin(c, user)
+}
@@ -0,0 +1,54 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"forum/backend/auth/internal/handlers"
+	"forum/backend/auth/internal/oidc"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOIDCCallbackRequiresStateCookie(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handlers.RegisterOIDCProvider(oidc.NewProvider(oidc.Config{
+		Name:        "test",
+		Issuer:      "https://idp.example.com",
+		ClientID:    "forum",
+		RedirectURL: "https://forum.example.com/auth/oidc/test/callback",
+	}))
+	router := gin.New()
+	router.GET("/auth/oidc/:provider/callback", handlers.OIDCCallback)
+
+	tests := []struct {
+		name   string
+		cookie *http.Cookie
+	}{
+		{"No Cookie", nil},
+		{"Other State", &http.Cookie{Name: "oidc_state", Value: "0000"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/auth/oidc/test/callback?code=attacker-code&state=attacker-state", nil)
+			if tt.cookie != nil {
+				req.AddCookie(tt.cookie)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			// Ссылка с чужими code и state отклоняется до обращения к БД и провайдеру
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			cookies := w.Result().Cookies()
+			require.Len(t, cookies, 1)
+			assert.Equal(t, "oidc_state", cookies[0].Name)
+			assert.Equal(t, -1, cookies[0].MaxAge)
+			assert.Equal(t, "/auth/oidc/test/callback", cookies[0].Path)
+			assert.True(t, cookies[0].HttpOnly)
+			assert.True(t, cookies[0].Secure)
+		})
+	}
+}
@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"forum/backend/auth/internal/mailer"
+	"forum/backend/auth/internal/models"
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PasswordResetURL - страница фронтенда, куда ведет ссылка из письма. Токен добавляется параметром token
+var PasswordResetURL = "http://localhost:3000/reset-password"
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required,min=8"`
+}
+
+// ChangePassword меняет пароль текущего пользователя. Остальные сессии завершаются,
+// а для текущей выпускаются новые токены
+func ChangePassword(c *gin.Context) {
+	var req ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log1.Error().
+			Err(err).
+			Msg("Invalid password change request format")
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := c.GetInt("user_id")
+	user, err := models.GetUserByID(c.Request.Context(), userID)
+	if err != nil {
+		log1.Error().
+			Err(err).
+			Int("user_id", userID).
+			Msg("Failed to get user for password change")
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
+	if !models.CheckPasswordHash(req.CurrentPassword, user.PasswordHash) {
+		log1.Warn().
+			Int("user_id", userID).
+			Msg("Password change with wrong current password")
+		c.JSON(http.StatusForbidden, gin.H{"error": "Invalid current password"})
+		return
+	}
+
+	if err := setPassword(c.Request.Context(), userID, req.NewPassword); err != nil {
+		log1.Error().
+			Err(err).
+			Int("user_id", userID).
+			Msg("Failed to change password")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change password"})
+		return
+	}
+
+	// Токены текущей сессии отозваны вместе с остальными
+	tokens, err := issueTokens(c.Request.Context(), user)
+	if err != nil {
+		log1.Error().
+			Err(err).
+			Int("user_id", userID).
+			Msg("Failed to restart session after password change")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change password"})
+		return
+	}
+
+	log1.Info().
+		Int("user_id", userID).
+		Msg("Password changed")
+	c.JSON(http.StatusOK, tokens)
+}
+
+// setPassword хеширует и сохраняет пароль, завершая все сессии пользователя
+func setPassword(ctx context.Context, userID int, password string) error {
+	hash, err := models.HashPassword(password)
+	if err != nil {
+		return err
+	}
+	return models.UpdatePassword(context.WithoutCancel(ctx), userID, hash)
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// ForgotPassword отправляет ссылку для сброса пароля. Ответ всегда 202 и не зависит от того,
+// есть ли пользователь с таким email, чтобы по нему нельзя было проверять адреса.
+// Письмо отправляется в фоне, чтобы и время ответа не выдавало существующий адрес
+func ForgotPassword(c *gin.Context) {
+	var req ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "If the email is registered, a reset link has been sent"})
+
+	user, err := models.GetUserByEmail(c.Request.Context(), req.Email)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			log1.Error().
+				Err(err).
+				Msg("Failed to get user for password reset")
+		}
+		return
+	}
+
+	token, err := models.CreatePasswordResetToken(c.Request.Context(), user.ID)
+	if err != nil {
+		if errors.Is(err, models.ErrResetRateLimited) {
+			log1.Warn().
+				Int("user_id", user.ID).
+				Msg("Password reset rate limited")
+			return
+		}
+		log1.Error().
+			Err(err).
+			Int("user_id", user.ID).
+			Msg("Failed to create password reset token")
+		return
+	}
+
+	msg := mailer.Message{
+		To:      user.Email,
+		Subject: "Password reset",
+		Body: fmt.Sprintf("To reset your password, open the link below. It is valid for %s.\n\n%s?token=%s\n",
+			models.PasswordResetTTL, PasswordResetURL, url.QueryEscape(token)),
+	}
+	go func() {
+		if err := mailer.Default.Send(msg); err != nil {
+			log1.Error().
+				Err(err).
+				Int("user_id", user.ID).
+				Msg("Failed to send password reset email")
+			return
+		}
+		log1.Info().
+			Int("user_id", user.ID).
+			Msg("Password reset requested")
+	}()
+}
+
+type ResetPasswordRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required,min=8"`
+}
+
+// ResetPassword задает новый пароль по токену из письма и завершает все сессии пользователя
+func ResetPassword(c *gin.Context) {
+	var req ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	hash, err := models.HashPassword(req.NewPassword)
+	if err != nil {
+		log1.Error().
+			Err(err).
+			Msg("Failed to hash password for reset")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+		return
+	}
+
+	userID, err := models.ConsumePasswordResetToken(context.WithoutCancel(c.Request.Context()), req.Token, hash)
+	if err != nil {
+		if errors.Is(err, models.ErrResetTokenInvalid) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired reset token"})
+			return
+		}
+		log1.Error().
+			Err(err).
+			Msg("Failed to reset password")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+		return
+	}
+
+	log1.Info().
+		Int("user_id", userID).
+		Msg("Password reset")
+	c.Status(http.StatusNoContent)
+}
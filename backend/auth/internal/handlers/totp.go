@@ -0,0 +1,338 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"forum/backend/auth/internal/jwt"
+	"forum/backend/auth/internal/models"
+	"forum/backend/auth/internal/totp"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TOTPIssuer - название сервиса в приложении-аутентификаторе
+var TOTPIssuer = "Forum"
+
+// SetupTOTP начинает подключение 2FA: создает секрет и возвращает otpauth:// ссылку.
+// 2FA включается только после подтверждения кодом в EnableTOTP
+func SetupTOTP(c *gin.Context) {
+	userID := c.GetInt("user_id")
+	user, err := models.GetUserByID(c.Request.Context(), userID)
+	if err != nil {
+		log1.Error().
+			Err(err).
+			Int("user_id", userID).
+			Msg("Failed to get user for TOTP setup")
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		log1.Error().
+			Err(err).
+			Msg("Failed to generate TOTP secret")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set up two-factor authentication"})
+		return
+	}
+
+	if err := models.StartTOTPEnrollment(c.Request.Context(), userID, secret); err != nil {
+		if errors.Is(err, models.ErrTOTPAlreadyEnabled) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication already enabled"})
+			return
+		}
+		log1.Error().
+			Err(err).
+			Int("user_id", userID).
+			Msg("Failed to start TOTP enrollment")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set up two-factor authentication"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"secret":      secret,
+		"otpauth_uri": totp.URI(TOTPIssuer, user.Username, secret),
+	})
+}
+
+type TOTPCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// EnableTOTP включает 2FA по первому коду из приложения. Остальные сессии завершаются,
+// в ответе - коды восстановления и токены новой сессии
+func EnableTOTP(c *gin.Context) {
+	var req TOTPCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := c.GetInt("user_id")
+	codes, err := models.EnableTOTP(c.Request.Context(), userID, req.Code)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrTOTPAlreadyEnabled):
+			c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication already enabled"})
+		case errors.Is(err, models.ErrTOTPNotConfigured):
+			c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication setup not started"})
+		case errors.Is(err, models.ErrSecondFactorInvalid):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid code"})
+		default:
+			log1.Error().
+				Err(err).
+				Int("user_id", userID).
+				Msg("Failed to enable TOTP")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enable two-factor authentication"})
+		}
+		return
+	}
+
+	// Сессии, открытые без второго фактора, больше не действуют
+	tokens, err := restartSession(c.Request.Context(), userID)
+	if err != nil {
+		log1.Error().
+			Err(err).
+			Int("user_id", userID).
+			Msg("Failed to restart session after enabling TOTP")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enable two-factor authentication"})
+		return
+	}
+
+	log1.Info().
+		Int("user_id", userID).
+		Msg("Two-factor authentication enabled")
+
+	tokens["recovery_codes"] = codes
+	c.JSON(http.StatusOK, tokens)
+}
+
+// restartSession завершает все сессии пользователя и открывает новую
+func restartSession(ctx context.Context, userID int) (gin.H, error) {
+	if err := models.RevokeAllRefreshTokens(ctx, userID); err != nil {
+		return nil, err
+	}
+	if err := models.RevokeAllAccessTokens(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	user, err := models.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return issueTokens(ctx, user)
+}
+
+type DisableTOTPRequest struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+// DisableTOTP выключает 2FA. Нужны пароль и код TOTP или код восстановления
+func DisableTOTP(c *gin.Context) {
+	var req DisableTOTPRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := c.GetInt("user_id")
+	user, err := models.GetUserByID(c.Request.Context(), userID)
+	if err != nil {
+		log1.Error().
+			Err(err).
+			Int("user_id", userID).
+			Msg("Failed to get user for TOTP disable")
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
+	// Подбор пароля и кода ограничивается тем же счетчиком, что и вход
+	if !checkLoginAllowed(c, user.Username) {
+		return
+	}
+
+	if !models.CheckPasswordHash(req.Password, user.PasswordHash) {
+		log1.Warn().
+			Int("user_id", userID).
+			Msg("TOTP disable with wrong password")
+		recordLoginFailure(c, models.AuditEntry{
+			Event:    models.AuditLoginFailed,
+			UserID:   &user.ID,
+			Username: user.Username,
+		})
+		c.JSON(http.StatusForbidden, gin.H{"error": "Invalid password"})
+		return
+	}
+
+	if !verifySecondFactor(c, user, req.Code) {
+		return
+	}
+
+	if err := models.DisableTOTP(c.Request.Context(), userID); err != nil {
+		log1.Error().
+			Err(err).
+			Int("user_id", userID).
+			Msg("Failed to disable TOTP")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disable two-factor authentication"})
+		return
+	}
+
+	log1.Info().
+		Int("user_id", userID).
+		Msg("Two-factor authentication disabled")
+	c.Status(http.StatusNoContent)
+}
+
+// RegenerateRecoveryCodes выдает новые коды восстановления взамен старых
+func RegenerateRecoveryCodes(c *gin.Context) {
+	var req TOTPCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := c.GetInt("user_id")
+	user, err := models.GetUserByID(c.Request.Context(), userID)
+	if err != nil {
+		log1.Error().
+			Err(err).
+			Int("user_id", userID).
+			Msg("Failed to get user for recovery codes regeneration")
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
+	// Подбор кода ограничивается тем же счетчиком, что и вход
+	if !checkLoginAllowed(c, user.Username) {
+		return
+	}
+	if !verifySecondFactor(c, user, req.Code) {
+		return
+	}
+
+	codes, err := models.RegenerateRecoveryCodes(c.Request.Context(), userID)
+	if err != nil {
+		log1.Error().
+			Err(err).
+			Int("user_id", userID).
+			Msg("Failed to regenerate recovery codes")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to regenerate recovery codes"})
+		return
+	}
+
+	log1.Info().
+		Int("user_id", userID).
+		Msg("Recovery codes regenerated")
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// verifySecondFactor проверяет код и при ошибке сам отвечает клиенту. Неверный код
+// учитывается как неудачная попытка входа. Перед вызовом нужно проверить checkLoginAllowed
+func verifySecondFactor(c *gin.Context, user *models.User, code string) bool {
+	if err := models.VerifySecondFactor(c.Request.Context(), user.ID, code); err != nil {
+		if errors.Is(err, models.ErrSecondFactorInvalid) {
+			recordLoginFailure(c, models.AuditEntry{
+				Event:    models.AuditMFAFailed,
+				UserID:   &user.ID,
+				Username: user.Username,
+			})
+		}
+		secondFactorError(c, user.ID, err)
+		return false
+	}
+	return true
+}
+
+// secondFactorError отвечает клиенту на ошибку проверки второго фактора
+func secondFactorError(c *gin.Context, userID int, err error) {
+	switch {
+	case errors.Is(err, models.ErrTOTPNotEnabled):
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication not enabled"})
+	case errors.Is(err, models.ErrSecondFactorInvalid):
+		log1.Warn().
+			Int("user_id", userID).
+			Msg("Invalid second factor code")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid code"})
+	default:
+		log1.Error().
+			Err(err).
+			Int("user_id", userID).
+			Msg("Failed to verify second factor")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify code"})
+	}
+}
+
+type LoginMFARequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+// LoginMFA завершает вход с 2FA: обменивает токен mfa_pending и код на токены сессии
+func LoginMFA(c *gin.Context) {
+	var req LoginMFARequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	claims, err := jwt.ValidateMFAToken(c.Request.Context(), req.MFAToken)
+	if err != nil {
+		if errors.Is(err, jwt.ErrRevocationUnavailable) {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Token validation unavailable"})
+			return
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid MFA token"})
+		return
+	}
+
+	user, err := models.GetUserByID(c.Request.Context(), claims.UserID)
+	if err != nil {
+		log1.Error().
+			Err(err).
+			Int("user_id", claims.UserID).
+			Msg("Failed to get user for MFA login")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid MFA token"})
+		return
+	}
+
+	// Подбор кода ограничивается тем же счетчиком, что и подбор пароля
+	if !checkLoginAllowed(c, user.Username) {
+		return
+	}
+
+	if !verifySecondFactor(c, user, req.Code) {
+		return
+	}
+
+	// Токен mfa_pending одноразовый
+	if err := models.RevokeAccessToken(c.Request.Context(), claims.ID, claims.ExpiresAt.Time); err != nil {
+		log1.Error().
+			Err(err).
+			Int("user_id", user.ID).
+			Msg("Failed to revoke MFA token")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	tokens, err := issueTokens(c.Request.Context(), user)
+	if err != nil {
+		log1.Error().
+			Err(err).
+			Int("user_id", user.ID).
+			Msg("Failed to generate JWT token")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+	resetLoginFailures(c.Request.Context(), user)
+	logins.WithLabelValues("success").Inc()
+
+	log1.Info().
+		Int("user_id", user.ID).
+		Str("username", user.Username).
+		Msg("User successfully logged in with second factor")
+
+	tokens["user"] = privateUser(user)
+	c.JSON(http.StatusOK, tokens)
+}
@@ -1,13 +1,15 @@
 package handlers
 
 import (
+	"context"
+	"errors"
 	"forum/backend/auth/internal/external"
 	"forum/backend/auth/internal/logger"
+	"forum/backend/auth/internal/middleware"
 	"forum/backend/auth/internal/models"
 	proto "forum/backend/protos/go"
 	"net/http"
 	"strconv"
-	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/rs/zerolog"
@@ -19,22 +21,92 @@ func init() {
 	log = logger.GetLogger("user_handler")
 }
 
+// hasPermission проверяет право текущего пользователя, при ошибке отвечает 503
+func hasPermission(c *gin.Context, permission string) (bool, bool) {
+	if !middleware.AdminMFASatisfied(c) {
+		return false, true
+	}
+
+	ok, err := models.HasPermission(c.Request.Context(), c.GetString("role"), permission)
+	if err != nil {
+		log.Error().
+			Err(err).
+			Str("permission", permission).
+			Msg("Failed to check permission")
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Permission check unavailable"})
+		return false, false
+	}
+	return ok, true
+}
+
+// checkRoleChange разрешает смену роли только при праве users:manage_roles
+func checkRoleChange(c *gin.Context, current, requested string) bool {
+	if requested == current {
+		return true
+	}
+
+	allowed, ok := hasPermission(c, models.PermUsersManageRoles)
+	if !ok {
+		return false
+	}
+	if !allowed {
+		log.Warn().
+			Int("user_id", c.GetInt("user_id")).
+			Str("role", requested).
+			Msg("Forbidden role change")
+		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+		return false
+	}
+
+	valid, err := models.IsValidRole(c.Request.Context(), requested)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to validate role")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to validate role"})
+		return false
+	}
+	if !valid {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unknown role"})
+		return false
+	}
+	return true
+}
+
+// GetAllUsers возвращает список пользователей. Как и в GetUser, email и другие
+// приватные поля видны только тем, кто может менять пользователей
 func GetAllUsers(c *gin.Context) {
+	private, ok := hasPermission(c, models.PermUsersUpdate)
+	if !ok {
+		return
+	}
+
 	log.Info().Msg("Getting all users")
-	users := models.GetAllUsers()
+	users := models.GetAllUsers(c.Request.Context())
 	log.Info().Int("users_count", len(users)).Msg("Successfully retrieved all users")
-	c.JSON(http.StatusOK, users)
+	if private {
+		res := make([]PrivateUser, 0, len(users))
+		for i := range users {
+			res = append(res, privateUser(&users[i]))
+		}
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	res := make([]PublicUser, 0, len(users))
+	for i := range users {
+		res = append(res, publicUser(&users[i]))
+	}
+	c.JSON(http.StatusOK, res)
 }
 
+// GetUser возвращает профиль пользователя с его комментариями. Email и другие
+// приватные поля видны только самому пользователю и тем, кто может менять пользователей
 func GetUser(c *gin.Context) {
-	type UserInfo struct {
-		ID        int              `json:"id"`
-		Name      string           `json:"name"`
-		Username  string           `json:"username"`
-		Email     string           `json:"email"`
-		IsAdmin   bool             `json:"is_admin"`
-		CreatedAt time.Time        `json:"created_at"`
-		Comments  []*proto.Comment `json:"comments"`
+	type PublicProfile struct {
+		PublicUser
+		Comments []*proto.Comment `json:"comments"`
+	}
+	type PrivateProfile struct {
+		PrivateUser
+		Comments []*proto.Comment `json:"comments"`
 	}
 
 	userID, err := strconv.Atoi(c.Param("user_id"))
@@ -48,7 +120,7 @@ func GetUser(c *gin.Context) {
 	}
 
 	log.Info().Int("user_id", userID).Msg("Getting user information")
-	user, err := models.GetUserByID(userID)
+	user, err := models.GetUserByID(c.Request.Context(), userID)
 	if err != nil {
 		log.Error().
 			Err(err).
@@ -58,7 +130,7 @@ func GetUser(c *gin.Context) {
 		return
 	}
 
-	comments, err := external.GetUserCommentsFromBackend(userID)
+	comments, err := external.GetUserCommentsFromBackend(c.Request.Context(), userID)
 	if err != nil {
 		log.Error().
 			Err(err).
@@ -68,55 +140,98 @@ func GetUser(c *gin.Context) {
 		return
 	}
 
-	res := UserInfo{
-		ID:        userID,
-		Name:      user.Name,
-		Username:  user.Username,
-		Email:     user.Email,
-		IsAdmin:   user.IsAdmin,
-		CreatedAt: user.CreatedAt,
-		Comments:  comments,
+	private := userID == c.GetInt("user_id")
+	if !private {
+		allowed, ok := hasPermission(c, models.PermUsersUpdate)
+		if !ok {
+			return
+		}
+		private = allowed
 	}
 
 	log.Info().
 		Int("user_id", userID).
 		Int("comments_count", len(comments)).
 		Msg("Successfully retrieved user information")
-	c.JSON(http.StatusOK, res)
+	if private {
+		c.JSON(http.StatusOK, PrivateProfile{PrivateUser: privateUser(user), Comments: comments})
+		return
+	}
+	c.JSON(http.StatusOK, PublicProfile{PublicUser: publicUser(user), Comments: comments})
 }
 
 func PostNewUser(c *gin.Context) {
-	var newUser models.User
-	if err := c.ShouldBindJSON(&newUser); err != nil {
+	var req CreateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
 		log.Error().
 			Err(err).
-			Interface("user", newUser).
+			Str("username", req.Username).
 			Msg("Invalid user creation request format")
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	if req.Role == "" {
+		req.Role = models.RoleUser
+	}
+	if !checkRoleChange(c, models.RoleUser, req.Role) {
+		return
+	}
+
 	log.Info().
-		Str("username", newUser.Username).
-		Str("email", newUser.Email).
+		Str("username", req.Username).
+		Str("email", req.Email).
 		Msg("Creating new user")
 
-	user := &models.User{
-		Name:         newUser.Name,
-		Username:     newUser.Username,
-		Email:        newUser.Email,
-		PasswordHash: newUser.PasswordHash,
-		IsAdmin:      newUser.IsAdmin,
+	hashedPassword, err := models.HashPassword(req.Password)
+	if err != nil {
+		log.Error().
+			Err(err).
+			Str("username", req.Username).
+			Msg("Failed to hash password")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		return
+	}
+
+	userID, err := models.AddUser(c.Request.Context(), &models.User{
+		Name:         req.Name,
+		Username:     req.Username,
+		Email:        req.Email,
+		PasswordHash: hashedPassword,
+		Role:         req.Role,
+	})
+	if err != nil {
+		if errors.Is(err, models.ErrUserExists) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Username or email already taken"})
+			return
+		}
+		log.Error().
+			Err(err).
+			Str("username", req.Username).
+			Msg("Failed to create user")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
+		return
+	}
+
+	user, err := models.GetUserByID(c.Request.Context(), userID)
+	if err != nil {
+		log.Error().
+			Err(err).
+			Int("user_id", userID).
+			Msg("Failed to get created user")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get created user"})
+		return
 	}
 
-	models.AddUser(user)
 	log.Info().
 		Int("user_id", user.ID).
 		Str("username", user.Username).
 		Msg("Successfully created new user")
-	c.JSON(http.StatusCreated, newUser)
+	c.JSON(http.StatusCreated, privateUser(user))
 }
 
+// DeleteUser удаляет аккаунт: свой или чужой при праве users:delete.
+// Аккаунт помечается удаленным и может быть восстановлен в течение models.UserRestoreGracePeriod
 func DeleteUser(c *gin.Context) {
 	userID, err := strconv.Atoi(c.Param("user_id"))
 	if err != nil {
@@ -128,12 +243,127 @@ func DeleteUser(c *gin.Context) {
 		return
 	}
 
+	var req DeleteUserRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	self := userID == c.GetInt("user_id")
+	if !self {
+		allowed, ok := hasPermission(c, models.PermUsersDelete)
+		if !ok {
+			return
+		}
+		if !allowed {
+			log.Warn().
+				Int("user_id", userID).
+				Int("current_user_id", c.GetInt("user_id")).
+				Msg("Forbidden user deletion")
+			c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+			return
+		}
+	}
+
+	user, err := models.GetUserByID(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
+	// Украденный access токен не должен позволять удалить аккаунт.
+	// У пользователей, вошедших только через OIDC, пароля нет
+	if self && user.PasswordHash != "" && !models.CheckPasswordHash(req.Password, user.PasswordHash) {
+		log.Warn().
+			Int("user_id", userID).
+			Msg("Account deletion with wrong password")
+		c.JSON(http.StatusForbidden, gin.H{"error": "Invalid password"})
+		return
+	}
+
 	log.Info().Int("user_id", userID).Msg("Deleting user")
-	models.DeleteUserByID(userID)
+	if err := models.SoftDeleteUser(c.Request.Context(), userID); err != nil {
+		if errors.Is(err, models.ErrUserNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+			return
+		}
+		log.Error().
+			Err(err).
+			Int("user_id", userID).
+			Msg("Failed to delete user")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete user"})
+		return
+	}
+
+	audit(c, models.AuditEntry{
+		Event:    models.AuditAccountDeleted,
+		UserID:   &user.ID,
+		Username: user.Username,
+		Detail:   "deleted by user " + strconv.Itoa(c.GetInt("user_id")),
+	})
+	// Неотправленное уведомление повторяет фоновая задача
+	if err := external.NotifyUserDeletedInBackend(c.Request.Context(), userID, false); err != nil {
+		log.Warn().
+			Err(err).
+			Int("user_id", userID).
+			Msg("Failed to notify forum service about deleted user, will retry")
+	} else if err := models.MarkDeletionNotified(context.WithoutCancel(c.Request.Context()),
+		models.DeletionNotice{UserID: userID}); err != nil {
+		log.Error().
+			Err(err).
+			Int("user_id", userID).
+			Msg("Failed to mark deletion notice as sent")
+	}
+
 	log.Info().Int("user_id", userID).Msg("Successfully deleted user")
-	c.JSON(http.StatusNoContent, gin.H{
-		"message": "User deleted",
+	c.Status(http.StatusNoContent)
+}
+
+// RestoreUser восстанавливает удаленный аккаунт, пока не истек срок восстановления
+func RestoreUser(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	user, err := models.RestoreUser(c.Request.Context(), userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrUserNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "deleted user not found"})
+		case errors.Is(err, models.ErrRestorePeriodExpired):
+			c.JSON(http.StatusGone, gin.H{"error": "Restore period has expired"})
+		default:
+			log.Error().
+				Err(err).
+				Int("user_id", userID).
+				Msg("Failed to restore user")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore user"})
+		}
+		return
+	}
+
+	audit(c, models.AuditEntry{
+		Event:    models.AuditAccountRestored,
+		UserID:   &user.ID,
+		Username: user.Username,
+		Detail:   "restored by user " + strconv.Itoa(c.GetInt("user_id")),
 	})
+	if err := external.InvalidateUserNameInBackend(c.Request.Context(), userID); err != nil {
+		log.Warn().
+			Err(err).
+			Int("user_id", userID).
+			Msg("Failed to invalidate cached username in forum service")
+	}
+
+	log.Info().
+		Int("user_id", userID).
+		Int("admin_id", c.GetInt("user_id")).
+		Msg("User restored")
+	c.JSON(http.StatusOK, privateUser(user))
 }
 
 func PutUser(c *gin.Context) {
@@ -147,33 +377,104 @@ func PutUser(c *gin.Context) {
 		return
 	}
 
-	var newUser models.User
-	if err := c.ShouldBindJSON(&newUser); err != nil {
+	var req UpdateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
 		log.Error().
 			Err(err).
-			Interface("user", newUser).
+			Int("user_id", id).
 			Msg("Invalid user update request format")
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	if id != c.GetInt("user_id") {
+		allowed, ok := hasPermission(c, models.PermUsersUpdate)
+		if !ok {
+			return
+		}
+		if !allowed {
+			log.Warn().
+				Int("user_id", id).
+				Int("current_user_id", c.GetInt("user_id")).
+				Msg("Forbidden user update")
+			c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+			return
+		}
+	}
+
+	existing, err := models.GetUserByID(c.Request.Context(), id)
+	if err != nil {
+		log.Error().
+			Err(err).
+			Int("user_id", id).
+			Msg("Failed to get user from database")
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
+	// Свой пароль меняется только с подтверждением текущего через POST /users/me/password
+	if req.Password != nil && id == c.GetInt("user_id") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Use POST /users/me/password to change your password"})
+		return
+	}
+
+	newUser := *existing
+	if req.Name != nil {
+		newUser.Name = *req.Name
+	}
+	if req.Username != nil {
+		newUser.Username = *req.Username
+	}
+	if req.Email != nil {
+		newUser.Email = *req.Email
+	}
+	// Без явной роли сохраняется текущая, is_admin определяется ролью
+	if req.Role != nil && *req.Role != "" {
+		newUser.Role = *req.Role
+	}
+	if !checkRoleChange(c, existing.Role, newUser.Role) {
+		return
+	}
+
 	log.Info().
 		Int("user_id", id).
 		Str("username", newUser.Username).
 		Msg("Updating user")
 
-	updated, err := models.PutUser(id, newUser)
+	updated, err := models.PutUser(c.Request.Context(), id, newUser)
 	if err != nil {
+		if errors.Is(err, models.ErrUserExists) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Username or email already taken"})
+			return
+		}
 		log.Error().
 			Err(err).
 			Int("user_id", id).
 			Msg("Failed to update user")
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
 		return
 	}
 
+	if req.Password != nil {
+		if err := setPassword(c.Request.Context(), id, *req.Password); err != nil {
+			log.Error().
+				Err(err).
+				Int("user_id", id).
+				Msg("Failed to set user password")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set password"})
+			return
+		}
+	}
+
+	if err := external.InvalidateUserNameInBackend(c.Request.Context(), id); err != nil {
+		log.Warn().
+			Err(err).
+			Int("user_id", id).
+			Msg("Failed to invalidate cached username in forum service")
+	}
+
 	log.Info().
 		Int("user_id", id).
 		Msg("Successfully updated user")
-	c.JSON(http.StatusOK, updated)
+	c.JSON(http.StatusOK, privateUser(&updated))
 }
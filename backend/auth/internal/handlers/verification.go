@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"forum/backend/auth/internal/mailer"
+	"forum/backend/auth/internal/models"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// EmailVerificationURL - адрес, куда ведет ссылка из письма. Токен добавляется параметром token
+var EmailVerificationURL = "http://localhost:8081/auth/verify"
+
+// sendVerificationEmail отправляет пользователю ссылку для подтверждения email.
+// При превышении лимита возвращает models.ErrVerificationRateLimited и время до следующей попытки
+func sendVerificationEmail(ctx context.Context, user *models.User) (time.Duration, error) {
+	token, wait, err := models.CreateEmailVerificationToken(ctx, user)
+	if err != nil {
+		return wait, err
+	}
+
+	return 0, mailer.Default.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Confirm your email",
+		Body: fmt.Sprintf("To confirm your email, open the link below. It is valid for %s.\n\n%s?token=%s\n",
+			models.EmailVerificationTTL, EmailVerificationURL, url.QueryEscape(token)),
+	})
+}
+
+func VerifyEmail(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "token is required"})
+		return
+	}
+
+	userID, err := models.VerifyEmail(c.Request.Context(), token)
+	if err != nil {
+		if errors.Is(err, models.ErrVerificationTokenInvalid) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired verification token"})
+			return
+		}
+		log1.Error().
+			Err(err).
+			Msg("Failed to verify email")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify email"})
+		return
+	}
+
+	log1.Info().
+		Int("user_id", userID).
+		Msg("Email verified")
+	c.JSON(http.StatusOK, gin.H{"message": "Email verified"})
+}
+
+// ResendVerification повторно отправляет письмо подтверждения текущему пользователю
+func ResendVerification(c *gin.Context) {
+	userID := c.GetInt("user_id")
+	user, err := models.GetUserByID(c.Request.Context(), userID)
+	if err != nil {
+		log1.Error().
+			Err(err).
+			Int("user_id", userID).
+			Msg("Failed to get user for verification resend")
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
+	if user.IsEmailVerified() {
+		c.JSON(http.StatusConflict, gin.H{"error": "Email already verified"})
+		return
+	}
+
+	wait, err := sendVerificationEmail(c.Request.Context(), user)
+	if err != nil {
+		if errors.Is(err, models.ErrVerificationRateLimited) {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many verification emails, try again later"})
+			return
+		}
+		log1.Error().
+			Err(err).
+			Int("user_id", userID).
+			Msg("Failed to send verification email")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send verification email"})
+		return
+	}
+
+	log1.Info().
+		Int("user_id", userID).
+		Msg("Verification email resent")
+	c.JSON(http.StatusAccepted, gin.H{"message": "Verification email sent"})
+}
@@ -1,34 +1,88 @@
 package jwt
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
-var jwtKey = []byte("key")
+// AccessTokenTTL - срок действия access токена. Для продления сессии используется refresh токен
+var AccessTokenTTL = 15 * time.Minute
+
+// MFATokenTTL - сколько действует токен mfa_pending, выданный после проверки пароля
+var MFATokenTTL = 5 * time.Minute
+
+// PurposeMFAPending - назначение токена, который подтверждает только пароль
+// и обменивается на access токен после ввода второго фактора
+const PurposeMFAPending = "mfa_pending"
+
+var (
+	// ErrTokenRevoked возвращается для токена из denylist
+	ErrTokenRevoked = errors.New("token revoked")
+	// ErrRevocationUnavailable возвращается, если denylist не удалось проверить
+	ErrRevocationUnavailable = errors.New("token revocation check unavailable")
+	// ErrWrongPurpose возвращается для токена с другим назначением,
+	// например при попытке использовать mfa_pending как access токен
+	ErrWrongPurpose = errors.New("unexpected token purpose")
+)
+
+// IsRevoked проверяет токен по denylist. Подменяется при старте сервиса
+var IsRevoked = func(ctx context.Context, claims *Claims) (bool, error) {
+	return false, nil
+}
+
+func init() {
+	// Миллисекунды в iat нужны, чтобы токен, выпущенный сразу после выхода со всех
+	// устройств, не считался отозванным
+	jwt.TimePrecision = time.Millisecond
+}
 
 type Claims struct {
 	UserID   int    `json:"user_id"`
 	Username string `json:"username"`
+	Role     string `json:"role"`
+	// EmailVerified - подтвержден ли email на момент выпуска токена
+	EmailVerified bool `json:"email_verified"`
+	// MFA - сессия открыта с проверкой второго фактора
+	MFA bool `json:"mfa,omitempty"`
+	// Purpose пуст у access токенов
+	Purpose string `json:"purpose,omitempty"`
 	jwt.RegisteredClaims
 }
 
-func GenerateToken(userID int, username string) (string, error) {
-	expirationTime := time.Now().Add(24 * time.Hour)
-	claims := &Claims{
-		UserID:   userID,
-		Username: username,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(expirationTime),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()),
-		},
+func GenerateToken(userID int, username, role string, emailVerified, mfa bool) (string, error) {
+	return sign(&Claims{
+		UserID:        userID,
+		Username:      username,
+		Role:          role,
+		EmailVerified: emailVerified,
+		MFA:           mfa,
+	}, AccessTokenTTL)
+}
+
+// GenerateMFAToken выпускает токен mfa_pending для пользователя, прошедшего проверку пароля
+func GenerateMFAToken(userID int) (string, error) {
+	return sign(&Claims{UserID: userID, Purpose: PurposeMFAPending}, MFATokenTTL)
+}
+
+func sign(claims *Claims, ttl time.Duration) (string, error) {
+	now := time.Now()
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		ID:        newTokenID(),
+		ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		IssuedAt:  jwt.NewNumericDate(now),
+		NotBefore: jwt.NewNumericDate(now),
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(jwtKey)
+	signing := currentKeys().signing
+	token := jwt.NewWithClaims(signing.Method, claims)
+	token.Header["kid"] = signing.ID
+	tokenString, err := token.SignedString(signing.Private)
 	if err != nil {
 		return "", err
 	}
@@ -36,11 +90,31 @@ func GenerateToken(userID int, username string) (string, error) {
 	return tokenString, nil
 }
 
-func ValidateToken(tokenString string) (*Claims, error) {
+// ValidateToken проверяет access токен
+func ValidateToken(ctx context.Context, tokenString string) (*Claims, error) {
+	return validate(ctx, tokenString, "")
+}
+
+// ValidateMFAToken проверяет токен mfa_pending
+func ValidateMFAToken(ctx context.Context, tokenString string) (*Claims, error) {
+	return validate(ctx, tokenString, PurposeMFAPending)
+}
+
+func validate(ctx context.Context, tokenString, purpose string) (*Claims, error) {
 	claims := &Claims{}
+	ks := currentKeys()
 	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-		return jwtKey, nil
-	})
+		kid, _ := token.Header["kid"].(string)
+		key, ok := ks.keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown key id %q", kid)
+		}
+		// Алгоритм задается ключом, а не заголовком токена
+		if token.Method.Alg() != key.Method.Alg() {
+			return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+		}
+		return key.Public, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg()}))
 
 	if err != nil {
 		return nil, err
@@ -50,5 +124,23 @@ func ValidateToken(tokenString string) (*Claims, error) {
 		return nil, fmt.Errorf("invalid token")
 	}
 
+	if claims.Purpose != purpose {
+		return nil, ErrWrongPurpose
+	}
+
+	revoked, err := IsRevoked(ctx, claims)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrRevocationUnavailable, err)
+	}
+	if revoked {
+		return nil, ErrTokenRevoked
+	}
+
 	return claims, nil
 }
+
+func newTokenID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
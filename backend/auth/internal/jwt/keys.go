@@ -0,0 +1,252 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Минимальный размер RSA ключа
+const minRSABits = 2048
+
+// Key - ключ подписи или проверки токенов
+type Key struct {
+	// ID - значение kid, JWK thumbprint публичного ключа (RFC 7638)
+	ID     string
+	Method jwt.SigningMethod
+	Public crypto.PublicKey
+	// Private задан только у ключа подписи
+	Private crypto.Signer
+}
+
+// KeySet - ключ подписи и все ключи, которыми можно проверить токен.
+// При ротации старый ключ остается в наборе проверки, пока не истекут выпущенные им токены
+type KeySet struct {
+	signing *Key
+	keys    map[string]*Key
+}
+
+var (
+	keys   *KeySet
+	keysMu sync.RWMutex
+)
+
+func init() {
+	// До загрузки ключей из файлов используется временный ключ
+	ks, err := GenerateKeySet()
+	if err != nil {
+		panic(err)
+	}
+	SetKeys(ks)
+}
+
+// SetKeys задает набор ключей для выпуска и проверки токенов
+func SetKeys(ks *KeySet) {
+	keysMu.Lock()
+	keys = ks
+	keysMu.Unlock()
+}
+
+func currentKeys() *KeySet {
+	keysMu.RLock()
+	defer keysMu.RUnlock()
+	return keys
+}
+
+// GenerateKeySet создает набор с временным Ed25519 ключом. Токены, подписанные им,
+// перестают проверяться после перезапуска сервиса
+func GenerateKeySet() (*KeySet, error) {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	key, err := newKey(private.Public(), private)
+	if err != nil {
+		return nil, err
+	}
+	return NewKeySet(key)
+}
+
+// NewKeySet создает набор с ключом подписи signing и дополнительными ключами проверки
+func NewKeySet(signing *Key, verify ...*Key) (*KeySet, error) {
+	if signing.Private == nil {
+		return nil, errors.New("ключ подписи должен содержать закрытый ключ")
+	}
+
+	ks := &KeySet{
+		signing: signing,
+		keys:    map[string]*Key{signing.ID: signing},
+	}
+	for _, key := range verify {
+		ks.keys[key.ID] = key
+	}
+	return ks, nil
+}
+
+// LoadKeys читает ключ подписи и ключи проверки из PEM файлов.
+// Файлы проверки могут содержать как открытые, так и закрытые ключи
+func LoadKeys(signingFile string, verifyFiles []string) (*KeySet, error) {
+	signing, err := LoadKeyFile(signingFile)
+	if err != nil {
+		return nil, err
+	}
+
+	verify := make([]*Key, 0, len(verifyFiles))
+	for _, file := range verifyFiles {
+		key, err := LoadKeyFile(file)
+		if err != nil {
+			return nil, err
+		}
+		// Закрытая часть старых ключей не нужна
+		key.Private = nil
+		verify = append(verify, key)
+	}
+	return NewKeySet(signing, verify...)
+}
+
+// LoadKeyFile читает RSA или Ed25519 ключ в формате PEM (PKCS#8, PKCS#1 или PKIX)
+func LoadKeyFile(path string) (*Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось прочитать ключ %s: %w", path, err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("файл %s не содержит PEM блок", path)
+	}
+
+	var parsed any
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("неподдерживаемый тип PEM блока %q в %s", block.Type, path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("не удалось разобрать ключ %s: %w", path, err)
+	}
+
+	var key *Key
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		key, err = newKey(k.Public(), k)
+	case ed25519.PrivateKey:
+		key, err = newKey(k.Public(), k)
+	default:
+		key, err = newKey(k, nil)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ключ %s: %w", path, err)
+	}
+	return key, nil
+}
+
+func newKey(public crypto.PublicKey, private crypto.Signer) (*Key, error) {
+	key := &Key{Public: public, Private: private}
+	switch k := public.(type) {
+	case *rsa.PublicKey:
+		if k.N.BitLen() < minRSABits {
+			return nil, fmt.Errorf("RSA ключ должен быть не короче %d бит", minRSABits)
+		}
+		key.Method = jwt.SigningMethodRS256
+	case ed25519.PublicKey:
+		key.Method = jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("неподдерживаемый тип ключа %T", public)
+	}
+
+	key.ID = thumbprint(key.JWK())
+	return key, nil
+}
+
+// JWK - открытый ключ в формате RFC 7517
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// Ed25519
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKS - набор открытых ключей для GET /.well-known/jwks.json
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWK возвращает открытую часть ключа
+func (k *Key) JWK() JWK {
+	jwk := JWK{Kid: k.ID, Use: "sig"}
+	switch public := k.Public.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.Alg = jwt.SigningMethodRS256.Alg()
+		jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Alg = jwt.SigningMethodEdDSA.Alg()
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(public)
+	}
+	return jwk
+}
+
+// thumbprint вычисляет JWK thumbprint по обязательным полям ключа (RFC 7638)
+func thumbprint(jwk JWK) string {
+	var members any
+	switch jwk.Kty {
+	case "RSA":
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{jwk.E, jwk.Kty, jwk.N}
+	default:
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{jwk.Crv, jwk.Kty, jwk.X}
+	}
+
+	data, _ := json.Marshal(members)
+	sum := sha256.Sum256(data)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// PublicJWKS возвращает открытые ключи текущего набора
+func PublicJWKS() JWKS {
+	ks := currentKeys()
+	set := JWKS{Keys: make([]JWK, 0, len(ks.keys))}
+	// Ключ подписи первым
+	set.Keys = append(set.Keys, ks.signing.JWK())
+	for id, key := range ks.keys {
+		if id != ks.signing.ID {
+			set.Keys = append(set.Keys, key.JWK())
+		}
+	}
+	return set
+}
@@ -0,0 +1,200 @@
+package jwt_test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"forum/backend/auth/internal/jwt"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writePEM сохраняет блок PEM во временный файл и возвращает путь
+func writePEM(t *testing.T, blockType string, der []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "key.pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+	return path
+}
+
+func writePKCS8(t *testing.T, key any) string {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	return writePEM(t, "PRIVATE KEY", der)
+}
+
+func writePKIX(t *testing.T, key any) string {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(key)
+	require.NoError(t, err)
+	return writePEM(t, "PUBLIC KEY", der)
+}
+
+func generateRSA(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	return key
+}
+
+func generateEd25519(t *testing.T) ed25519.PrivateKey {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	return key
+}
+
+// useKeys подменяет набор ключей на время теста
+func useKeys(t *testing.T, ks *jwt.KeySet) {
+	t.Helper()
+	previous, err := jwt.GenerateKeySet()
+	require.NoError(t, err)
+	jwt.SetKeys(ks)
+	t.Cleanup(func() { jwt.SetKeys(previous) })
+}
+
+func decodeB64(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	require.NoError(t, err)
+	return b
+}
+
+func TestKeyIDIsRFC7638Thumbprint(t *testing.T) {
+	// RFC 7638, раздел 3.1
+	rsaKey := &rsa.PublicKey{
+		N: new(big.Int).SetBytes(decodeB64(t, "0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw")),
+		E: 65537,
+	}
+	key, err := jwt.LoadKeyFile(writePKIX(t, rsaKey))
+	require.NoError(t, err)
+	assert.Equal(t, "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs", key.ID)
+
+	// RFC 8037, приложение A.3
+	edKey := ed25519.PublicKey(decodeB64(t, "11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"))
+	key, err = jwt.LoadKeyFile(writePKIX(t, edKey))
+	require.NoError(t, err)
+	assert.Equal(t, "kPrK_qmxVWaYVA9wwBF6Iuo3vVzz7TxHCTwXBygrS4k", key.ID)
+}
+
+func TestLoadKeyFileFormats(t *testing.T) {
+	rsaKey := generateRSA(t)
+	edKey := generateEd25519(t)
+
+	pkcs1 := writePEM(t, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey))
+	for name, path := range map[string]string{
+		"pkcs1": pkcs1,
+		"pkcs8": writePKCS8(t, rsaKey),
+	} {
+		key, err := jwt.LoadKeyFile(path)
+		require.NoError(t, err, name)
+		assert.Equal(t, "RS256", key.Method.Alg(), name)
+		assert.NotNil(t, key.Private, name)
+	}
+
+	key, err := jwt.LoadKeyFile(writePKCS8(t, edKey))
+	require.NoError(t, err)
+	assert.Equal(t, "EdDSA", key.Method.Alg())
+	assert.NotNil(t, key.Private)
+
+	// Открытый ключ дает тот же kid, что и закрытый
+	public, err := jwt.LoadKeyFile(writePKIX(t, edKey.Public()))
+	require.NoError(t, err)
+	assert.Nil(t, public.Private)
+	assert.Equal(t, key.ID, public.ID)
+}
+
+func TestLoadKeyFileRejects(t *testing.T) {
+	short, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+	empty := filepath.Join(t.TempDir(), "empty.pem")
+	require.NoError(t, os.WriteFile(empty, nil, 0o600))
+
+	for name, path := range map[string]string{
+		"short rsa":   writePKCS8(t, short),
+		"certificate": writePEM(t, "CERTIFICATE", []byte("garbage")),
+		"bad der":     writePEM(t, "PRIVATE KEY", []byte("garbage")),
+		"missing":     filepath.Join(t.TempDir(), "missing.pem"),
+		"not pem":     empty,
+	} {
+		_, err := jwt.LoadKeyFile(path)
+		assert.Error(t, err, name)
+	}
+}
+
+func TestPublicJWKS(t *testing.T) {
+	signing := generateEd25519(t)
+	old := generateRSA(t)
+
+	ks, err := jwt.LoadKeys(writePKCS8(t, signing), []string{writePKCS8(t, old)})
+	require.NoError(t, err)
+	useKeys(t, ks)
+
+	signingKey, err := jwt.LoadKeyFile(writePKCS8(t, signing))
+	require.NoError(t, err)
+	oldKey, err := jwt.LoadKeyFile(writePKIX(t, old.Public()))
+	require.NoError(t, err)
+
+	jwks := jwt.PublicJWKS()
+	require.Len(t, jwks.Keys, 2)
+	// Ключ подписи первым
+	assert.Equal(t, signingKey.ID, jwks.Keys[0].Kid)
+	assert.Equal(t, "OKP", jwks.Keys[0].Kty)
+	assert.Equal(t, "Ed25519", jwks.Keys[0].Crv)
+	assert.Equal(t, oldKey.ID, jwks.Keys[1].Kid)
+	assert.Equal(t, "RSA", jwks.Keys[1].Kty)
+	assert.Equal(t, "RS256", jwks.Keys[1].Alg)
+	assert.Equal(t, "AQAB", jwks.Keys[1].E)
+	for _, k := range jwks.Keys {
+		assert.Equal(t, "sig", k.Use)
+	}
+
+	_, err = jwt.LoadKeys(writePKIX(t, signing.Public()), nil)
+	assert.Error(t, err, "ключ подписи без закрытой части")
+}
+
+func TestVerifyWithRotatedOutKey(t *testing.T) {
+	ctx := context.Background()
+	oldPath := writePKCS8(t, generateRSA(t))
+	newPath := writePKCS8(t, generateEd25519(t))
+
+	ks, err := jwt.LoadKeys(oldPath, nil)
+	require.NoError(t, err)
+	useKeys(t, ks)
+	token, err := jwt.GenerateToken(1, "alice", "user", true, false)
+	require.NoError(t, err)
+
+	// После ротации старый ключ остается в наборе проверки
+	ks, err = jwt.LoadKeys(newPath, []string{oldPath})
+	require.NoError(t, err)
+	jwt.SetKeys(ks)
+	claims, err := jwt.ValidateToken(ctx, token)
+	require.NoError(t, err)
+	assert.Equal(t, 1, claims.UserID)
+
+	fresh, err := jwt.GenerateToken(2, "bob", "user", true, false)
+	require.NoError(t, err)
+	_, err = jwt.ValidateToken(ctx, fresh)
+	assert.NoError(t, err)
+
+	// Когда старый ключ убран из набора, его токены больше не проверяются
+	ks, err = jwt.LoadKeys(newPath, nil)
+	require.NoError(t, err)
+	jwt.SetKeys(ks)
+	_, err = jwt.ValidateToken(ctx, token)
+	assert.Error(t, err)
+	_, err = jwt.ValidateToken(ctx, fresh)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,123 @@
+package mailer
+
+import (
+	"fmt"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"forum/backend/auth/internal/logger"
+
+	"github.com/rs/zerolog"
+)
+
+var log zerolog.Logger
+
+func init() {
+	log = logger.GetLogger("mailer")
+}
+
+// Message - письмо пользователю
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer отправляет письма
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// Default - отправитель, через который сервис отправляет письма. Подменяется при старте
+var Default Mailer = NewLogMailer("")
+
+// SMTPMailer отправляет письма через SMTP сервер
+type SMTPMailer struct {
+	// Addr - адрес сервера в формате host:port
+	Addr     string
+	Username string
+	Password string
+	From     string
+}
+
+// NewSMTPMailer создает SMTPMailer. Если username пустой, отправка идет без аутентификации
+func NewSMTPMailer(addr, username, password, from string) *SMTPMailer {
+	return &SMTPMailer{Addr: addr, Username: username, Password: password, From: from}
+}
+
+func (m *SMTPMailer) Send(msg Message) error {
+	var auth smtp.Auth
+	if m.Username != "" {
+		host := m.Addr
+		if i := strings.LastIndex(host, ":"); i >= 0 {
+			host = host[:i]
+		}
+		auth = smtp.PlainAuth("", m.Username, m.Password, host)
+	}
+
+	if err := smtp.SendMail(m.Addr, auth, m.From, []string{msg.To}, m.format(msg)); err != nil {
+		return fmt.Errorf("не удалось отправить письмо: %w", err)
+	}
+	return nil
+}
+
+// headerValue убирает переводы строк, чтобы значение не могло добавить свои заголовки
+var headerValue = strings.NewReplacer("\r", "", "\n", "")
+
+func (m *SMTPMailer) format(msg Message) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", headerValue.Replace(m.From))
+	fmt.Fprintf(&b, "To: %s\r\n", headerValue.Replace(msg.To))
+	fmt.Fprintf(&b, "Subject: %s\r\n", headerValue.Replace(msg.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return []byte(b.String())
+}
+
+// LogMailer для локальной разработки: пишет письма в каталог Dir. Если Dir пустой,
+// в лог попадают только адрес и тема: текст содержит ссылки с действующими токенами
+type LogMailer struct {
+	Dir string
+}
+
+// NewLogMailer создает LogMailer
+func NewLogMailer(dir string) *LogMailer {
+	return &LogMailer{Dir: dir}
+}
+
+func (m *LogMailer) Send(msg Message) error {
+	if m.Dir == "" {
+		log.Info().
+			Str("to", msg.To).
+			Str("subject", msg.Subject).
+			Msg("Mail body is not logged, set MAIL_DIR to save mail")
+		return nil
+	}
+
+	if err := os.MkdirAll(m.Dir, 0o700); err != nil {
+		return fmt.Errorf("не удалось создать каталог для писем: %w", err)
+	}
+	name := fmt.Sprintf("%s-%s.eml", time.Now().Format("20060102-150405.000000000"), sanitize(msg.To))
+	content := fmt.Sprintf("To: %s\nSubject: %s\n\n%s\n", msg.To, msg.Subject, msg.Body)
+	if err := os.WriteFile(filepath.Join(m.Dir, name), []byte(content), 0o600); err != nil {
+		return fmt.Errorf("не удалось сохранить письмо: %w", err)
+	}
+	return nil
+}
+
+// sanitize оставляет в адресе только символы, допустимые в имени файла
+func sanitize(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-', r == '_', r == '@':
+			return r
+		}
+		return '_'
+	}, s)
+}
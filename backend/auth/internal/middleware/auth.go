@@ -1,13 +1,18 @@
 package middleware
 
 import (
+	"errors"
 	"forum/backend/auth/internal/jwt"
+	"forum/backend/auth/internal/models"
 	"net/http"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 )
 
+// RequireAdminMFA запрещает администраторам действия с правами без 2FA
+var RequireAdminMFA bool
+
 func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
@@ -25,15 +30,60 @@ func AuthMiddleware() gin.HandlerFunc {
 		}
 
 		tokenString := parts[1]
-		claims, err := jwt.ValidateToken(tokenString)
+		claims, err := jwt.ValidateToken(c.Request.Context(), tokenString)
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			switch {
+			case errors.Is(err, jwt.ErrRevocationUnavailable):
+				c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Token validation unavailable"})
+			case errors.Is(err, jwt.ErrTokenRevoked):
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Token revoked"})
+			default:
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			}
 			c.Abort()
 			return
 		}
 
 		c.Set("user_id", claims.UserID)
 		c.Set("username", claims.Username)
+		c.Set("role", claims.Role)
+		c.Set("email_verified", claims.EmailVerified)
+		c.Set("mfa", claims.MFA)
+		// Нужны для отзыва текущего токена при выходе
+		c.Set("token_id", claims.ID)
+		if claims.ExpiresAt != nil {
+			c.Set("token_expires_at", claims.ExpiresAt.Time)
+		}
+		c.Next()
+	}
+}
+
+// AdminMFASatisfied проверяет, что администратор вошел со вторым фактором, если это требуется
+func AdminMFASatisfied(c *gin.Context) bool {
+	return !RequireAdminMFA || c.GetString("role") != models.RoleAdmin || c.GetBool("mfa")
+}
+
+// RequirePermission пропускает запрос, только если у роли пользователя есть право permission.
+// Используется после AuthMiddleware
+func RequirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !AdminMFASatisfied(c) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Two-factor authentication required"})
+			c.Abort()
+			return
+		}
+
+		ok, err := models.HasPermission(c.Request.Context(), c.GetString("role"), permission)
+		if err != nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Permission check unavailable"})
+			c.Abort()
+			return
+		}
+		if !ok {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+			c.Abort()
+			return
+		}
 		c.Next()
 	}
 }
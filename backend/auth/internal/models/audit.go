@@ -0,0 +1,46 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"forum/backend/auth/internal/db"
+)
+
+// События журнала безопасности
+const (
+	AuditLoginFailed     = "login_failed"
+	AuditLoginBlocked    = "login_blocked"
+	AuditMFAFailed       = "mfa_failed"
+	AuditAccountLocked   = "account_locked"
+	AuditAccountUnlocked = "account_unlocked"
+	AuditAccountDeleted  = "account_deleted"
+	AuditAccountRestored = "account_restored"
+)
+
+// AuditEntry - запись журнала безопасности. UserID равен nil, если пользователь не известен
+type AuditEntry struct {
+	Event    string
+	UserID   *int
+	Username string
+	IP       string
+	Detail   string
+}
+
+// WriteAudit сохраняет запись в журнал безопасности
+func WriteAudit(ctx context.Context, e AuditEntry) error {
+	// Событие записывается, даже если клиент разорвал соединение, не дождавшись ответа
+	ctx = context.WithoutCancel(ctx)
+	_, err := db.Db.ExecContext(ctx, `
+		INSERT INTO auth_audit_log (event, user_id, username, ip, detail)
+		VALUES ($1, $2, $3, $4, $5)
+	`, e.Event, e.UserID, nullString(e.Username), nullString(e.IP), nullString(e.Detail))
+	if err != nil {
+		return fmt.Errorf("не удалось записать событие в журнал: %w", err)
+	}
+	return nil
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
@@ -0,0 +1,214 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"forum/backend/auth/internal/db"
+	"strconv"
+	"time"
+)
+
+// UserRestoreGracePeriod - сколько удаленный аккаунт можно восстановить.
+// После этого данные аккаунта обезличиваются
+var UserRestoreGracePeriod = 30 * 24 * time.Hour
+
+// DeletedUsername показывается вместо имени удаленного пользователя
+const DeletedUsername = "[deleted user]"
+
+var (
+	// ErrUserNotFound - пользователь не найден или уже удален
+	ErrUserNotFound = errors.New("user not found")
+	// ErrRestorePeriodExpired - аккаунт удален слишком давно и уже обезличен
+	ErrRestorePeriodExpired = errors.New("restore period expired")
+)
+
+// SoftDeleteUser помечает пользователя удаленным и завершает все его сессии.
+// Строка пользователя остается, чтобы сохранить его темы и комментарии. Уведомление
+// forum-сервиса об удалении становится ожидающим до MarkDeletionNotified
+func SoftDeleteUser(ctx context.Context, userID int) error {
+	tx, err := db.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("не удалось начать транзакцию: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `
+		UPDATE users
+		SET deleted_at = CURRENT_TIMESTAMP, deletion_notified_at = NULL, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND deleted_at IS NULL
+	`, userID)
+	if err != nil {
+		return fmt.Errorf("не удалось удалить пользователя: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrUserNotFound
+	}
+
+	if err := revokeUserRefreshTokens(ctx, tx, userID); err != nil {
+		return err
+	}
+	if err := revokeUserAccessTokens(ctx, tx, userID); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("не удалось зафиксировать транзакцию: %w", err)
+	}
+	return nil
+}
+
+// RestoreUser снимает пометку об удалении, если срок восстановления не истек
+func RestoreUser(ctx context.Context, userID int) (*User, error) {
+	var deletedAt, anonymizedAt *time.Time
+	err := db.Db.QueryRowContext(ctx, "SELECT deleted_at, anonymized_at FROM users WHERE id = $1", userID).
+		Scan(&deletedAt, &anonymizedAt)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("не удалось получить пользователя: %w", err)
+	}
+	if err != nil || deletedAt == nil {
+		return nil, ErrUserNotFound
+	}
+	if anonymizedAt != nil || time.Since(*deletedAt) > UserRestoreGracePeriod {
+		return nil, ErrRestorePeriodExpired
+	}
+
+	var u User
+	err = scanUser(db.Db.QueryRowContext(ctx, `
+		UPDATE users SET deleted_at = NULL, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND deleted_at IS NOT NULL AND anonymized_at IS NULL
+		RETURNING `+userColumns, userID), &u)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось восстановить пользователя: %w", err)
+	}
+	return &u, nil
+}
+
+// AnonymizeDeletedUsers обезличивает аккаунты, срок восстановления которых истек:
+// удаляет имя, email, пароль, 2FA и привязанные внешние аккаунты.
+// Возвращает ID обезличенных пользователей
+func AnonymizeDeletedUsers(ctx context.Context) ([]int, error) {
+	rows, err := db.Db.QueryContext(ctx, `
+		SELECT id FROM users
+		WHERE deleted_at < $1 AND anonymized_at IS NULL
+	`, time.Now().Add(-UserRestoreGracePeriod))
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить удаленных пользователей: %w", err)
+	}
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("не удалось получить удаленных пользователей: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("не удалось получить удаленных пользователей: %w", err)
+	}
+
+	anonymized := make([]int, 0, len(ids))
+	for _, id := range ids {
+		if err := anonymizeUser(ctx, id); err != nil {
+			return anonymized, err
+		}
+		anonymized = append(anonymized, id)
+	}
+	return anonymized, nil
+}
+
+// DeletionNotice - уведомление forum-сервиса об удалении пользователя
+type DeletionNotice struct {
+	UserID int
+	// Anonymized - данные пользователя обезличены, восстановить его уже нельзя
+	Anonymized bool
+}
+
+// PendingDeletionNotices возвращает уведомления об удалении и обезличивании,
+// которые forum-сервис еще не подтвердил. Уведомление об удалении восстановленного
+// пользователя не отправляется, а обезличивание заменяет собой удаление
+func PendingDeletionNotices(ctx context.Context) ([]DeletionNotice, error) {
+	rows, err := db.Db.QueryContext(ctx, `
+		SELECT id, anonymized_at IS NOT NULL FROM users
+		WHERE (deleted_at IS NOT NULL AND anonymized_at IS NULL AND deletion_notified_at IS NULL)
+		   OR (anonymized_at IS NOT NULL AND anonymization_notified_at IS NULL)
+		ORDER BY id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить неотправленные уведомления: %w", err)
+	}
+	defer rows.Close()
+
+	var notices []DeletionNotice
+	for rows.Next() {
+		var n DeletionNotice
+		if err := rows.Scan(&n.UserID, &n.Anonymized); err != nil {
+			return nil, fmt.Errorf("не удалось получить неотправленные уведомления: %w", err)
+		}
+		notices = append(notices, n)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("не удалось получить неотправленные уведомления: %w", err)
+	}
+	return notices, nil
+}
+
+// MarkDeletionNotified отмечает, что forum-сервис принял уведомление n. Если пользователя
+// успели восстановить, отметка не ставится
+func MarkDeletionNotified(ctx context.Context, n DeletionNotice) error {
+	query := `
+		UPDATE users SET deletion_notified_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND deleted_at IS NOT NULL AND anonymized_at IS NULL
+	`
+	if n.Anonymized {
+		query = `
+			UPDATE users SET anonymization_notified_at = CURRENT_TIMESTAMP
+			WHERE id = $1 AND anonymized_at IS NOT NULL
+		`
+	}
+	if _, err := db.Db.ExecContext(ctx, query, n.UserID); err != nil {
+		return fmt.Errorf("не удалось сохранить отправку уведомления: %w", err)
+	}
+	return nil
+}
+
+func anonymizeUser(ctx context.Context, userID int) error {
+	tx, err := db.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("не удалось начать транзакцию: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Имя и email заменяются уникальными заглушками, чтобы освободить исходные
+	placeholder := "deleted-" + strconv.Itoa(userID)
+	_, err = tx.ExecContext(ctx, `
+		UPDATE users
+		SET name = $2, username = $3, email = $4, password_hash = '',
+			email_verified_at = NULL, totp_secret = NULL, totp_enabled_at = NULL, totp_last_step = NULL,
+			anonymized_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND anonymized_at IS NULL
+	`, userID, DeletedUsername, placeholder, placeholder+"@deleted.invalid")
+	if err != nil {
+		return fmt.Errorf("не удалось обезличить пользователя: %w", err)
+	}
+
+	for _, query := range []string{
+		"DELETE FROM user_identities WHERE user_id = $1",
+		"DELETE FROM recovery_codes WHERE user_id = $1",
+		"DELETE FROM refresh_tokens WHERE user_id = $1",
+		"DELETE FROM password_reset_tokens WHERE user_id = $1",
+		"DELETE FROM email_verification_tokens WHERE user_id = $1",
+	} {
+		if _, err := tx.ExecContext(ctx, query, userID); err != nil {
+			return fmt.Errorf("не удалось удалить данные пользователя: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("не удалось зафиксировать транзакцию: %w", err)
+	}
+	return nil
+}
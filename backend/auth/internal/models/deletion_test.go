@@ -0,0 +1,77 @@
+package models_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"forum/backend/auth/internal/db"
+	"forum/backend/auth/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeletionNoticeLifecycle(t *testing.T) {
+	setupTestDB(t)
+	ctx := context.Background()
+	userID := createTestUser(t, "leaving")
+	notice := models.DeletionNotice{UserID: userID}
+
+	// Уведомление об удалении ожидает отправки, пока forum-сервис его не подтвердит
+	require.NoError(t, models.SoftDeleteUser(ctx, userID))
+	pending, err := models.PendingDeletionNotices(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []models.DeletionNotice{notice}, pending)
+
+	require.NoError(t, models.MarkDeletionNotified(ctx, notice))
+	pending, err = models.PendingDeletionNotices(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+
+	// Повторное удаление после восстановления снова требует уведомления
+	_, err = models.RestoreUser(ctx, userID)
+	require.NoError(t, err)
+	require.NoError(t, models.SoftDeleteUser(ctx, userID))
+	pending, err = models.PendingDeletionNotices(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []models.DeletionNotice{notice}, pending)
+
+	// Восстановленный пользователь не ждет уведомления об удалении
+	_, err = models.RestoreUser(ctx, userID)
+	require.NoError(t, err)
+	pending, err = models.PendingDeletionNotices(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+}
+
+func TestDeletionNoticeAnonymized(t *testing.T) {
+	setupTestDB(t)
+	ctx := context.Background()
+	userID := createTestUser(t, "expired")
+
+	require.NoError(t, models.SoftDeleteUser(ctx, userID))
+	_, err := db.Db.Exec(`UPDATE users SET deleted_at = $2 WHERE id = $1`,
+		userID, time.Now().Add(-models.UserRestoreGracePeriod-time.Hour))
+	require.NoError(t, err)
+	ids, err := models.AnonymizeDeletedUsers(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []int{userID}, ids)
+
+	// Обезличивание заменяет неотправленное уведомление об удалении
+	anonymized := models.DeletionNotice{UserID: userID, Anonymized: true}
+	pending, err := models.PendingDeletionNotices(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []models.DeletionNotice{anonymized}, pending)
+
+	// Запоздалое подтверждение удаления не снимает уведомление об обезличивании
+	require.NoError(t, models.MarkDeletionNotified(ctx, models.DeletionNotice{UserID: userID}))
+	pending, err = models.PendingDeletionNotices(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []models.DeletionNotice{anonymized}, pending)
+
+	require.NoError(t, models.MarkDeletionNotified(ctx, anonymized))
+	pending, err = models.PendingDeletionNotices(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+}
@@ -0,0 +1,208 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"forum/backend/auth/internal/db"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// OIDCStateTTL - сколько ждать возврата пользователя от провайдера
+var OIDCStateTTL = 10 * time.Minute
+
+var (
+	// ErrOIDCStateInvalid - state не найден, истек или уже использован
+	ErrOIDCStateInvalid = errors.New("invalid oidc state")
+	// ErrIdentityEmailTaken - email занят аккаунтом, который нельзя привязать автоматически
+	ErrIdentityEmailTaken = errors.New("email belongs to another account")
+	// ErrIdentityEmailRequired - провайдер не сообщил email, без него аккаунт не создается
+	ErrIdentityEmailRequired = errors.New("identity has no email")
+	// ErrProvisioningDisabled - аккаунт не найден, а автосоздание выключено
+	ErrProvisioningDisabled = errors.New("account provisioning disabled")
+)
+
+// CreateOIDCState сохраняет параметры начатого входа через провайдера
+func CreateOIDCState(ctx context.Context, provider, state, nonce, codeVerifier string) error {
+	_, err := db.Db.ExecContext(ctx, `
+		INSERT INTO oidc_login_states (state_hash, provider, nonce, code_verifier, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`, hashToken(state), provider, nonce, codeVerifier, time.Now().Add(OIDCStateTTL))
+	if err != nil {
+		return fmt.Errorf("не удалось сохранить состояние входа: %w", err)
+	}
+	return nil
+}
+
+// ConsumeOIDCState удаляет state и возвращает сохраненные nonce и code_verifier
+func ConsumeOIDCState(ctx context.Context, provider, state string) (string, string, error) {
+	var nonce, codeVerifier string
+	err := db.Db.QueryRowContext(ctx, `
+		DELETE FROM oidc_login_states
+		WHERE state_hash = $1 AND provider = $2 AND expires_at > CURRENT_TIMESTAMP
+		RETURNING nonce, code_verifier
+	`, hashToken(state), provider).Scan(&nonce, &codeVerifier)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", "", ErrOIDCStateInvalid
+		}
+		return "", "", fmt.Errorf("не удалось получить состояние входа: %w", err)
+	}
+	return nonce, codeVerifier, nil
+}
+
+// ExternalIdentity - пользователь, подтвержденный OIDC провайдером
+type ExternalIdentity struct {
+	Provider          string
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	Name              string
+	PreferredUsername string
+}
+
+// FindOrProvisionUser возвращает пользователя, привязанного к внешнему аккаунту.
+// Непривязанный аккаунт связывается с пользователем с тем же email, если email подтвержден
+// и провайдером, и у нас. Иначе при autoProvision создается новый пользователь.
+// Второе значение - создан ли пользователь
+func FindOrProvisionUser(ctx context.Context, identity ExternalIdentity, autoProvision bool) (*User, bool, error) {
+	tx, err := db.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, false, fmt.Errorf("не удалось начать транзакцию: %w", err)
+	}
+	defer tx.Rollback()
+
+	var userID int
+	err = tx.QueryRowContext(ctx, `
+		UPDATE user_identities SET last_login_at = CURRENT_TIMESTAMP, email = $3
+		WHERE provider = $1 AND subject = $2
+			AND user_id IN (SELECT id FROM users WHERE deleted_at IS NULL)
+		RETURNING user_id
+	`, identity.Provider, identity.Subject, identity.Email).Scan(&userID)
+	switch {
+	case err == nil:
+		if err := tx.Commit(); err != nil {
+			return nil, false, fmt.Errorf("не удалось зафиксировать транзакцию: %w", err)
+		}
+		user, err := GetUserByID(ctx, userID)
+		return user, false, err
+	case !errors.Is(err, sql.ErrNoRows):
+		return nil, false, fmt.Errorf("не удалось найти внешний аккаунт: %w", err)
+	}
+
+	if identity.Email == "" {
+		return nil, false, ErrIdentityEmailRequired
+	}
+
+	var existing User
+	err = scanUser(tx.QueryRowContext(ctx, "SELECT "+userColumns+" FROM users WHERE lower(email) = lower($1) AND deleted_at IS NULL FOR UPDATE",
+		identity.Email), &existing)
+	switch {
+	case err == nil:
+		// Привязка к аккаунту с неподтвержденным email позволила бы захватить аккаунт,
+		// заранее зарегистрированный на чужой адрес
+		if !identity.EmailVerified || !existing.IsEmailVerified() {
+			return nil, false, ErrIdentityEmailTaken
+		}
+		if err := linkIdentity(ctx, tx, existing.ID, identity); err != nil {
+			return nil, false, err
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, false, fmt.Errorf("не удалось зафиксировать транзакцию: %w", err)
+		}
+		return &existing, false, nil
+	case !errors.Is(err, sql.ErrNoRows):
+		return nil, false, fmt.Errorf("не удалось найти пользователя по email: %w", err)
+	}
+
+	if !autoProvision {
+		return nil, false, ErrProvisioningDisabled
+	}
+
+	username, err := availableUsername(ctx, tx, usernameCandidate(identity))
+	if err != nil {
+		return nil, false, err
+	}
+	name := identity.Name
+	if name == "" {
+		name = username
+	}
+	var verifiedAt *time.Time
+	if identity.EmailVerified {
+		now := time.Now()
+		verifiedAt = &now
+	}
+
+	// Пароля у такого пользователя нет: пустой хеш не совпадает ни с одним паролем,
+	// задать пароль можно через сброс по email
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO users (name, username, email, password_hash, is_admin, role, email_verified_at)
+		VALUES ($1, $2, $3, '', FALSE, $4, $5)
+		RETURNING id
+	`, name, username, identity.Email, RoleUser, verifiedAt).Scan(&userID)
+	if err != nil {
+		// Email или внешний аккаунт принадлежат удаленному пользователю
+		if isUniqueViolation(err) {
+			return nil, false, ErrIdentityEmailTaken
+		}
+		return nil, false, fmt.Errorf("не удалось создать пользователя: %w", err)
+	}
+	if err := linkIdentity(ctx, tx, userID, identity); err != nil {
+		return nil, false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, false, fmt.Errorf("не удалось зафиксировать транзакцию: %w", err)
+	}
+	user, err := GetUserByID(ctx, userID)
+	return user, true, err
+}
+
+func linkIdentity(ctx context.Context, e execer, userID int, identity ExternalIdentity) error {
+	_, err := e.ExecContext(ctx, `
+		INSERT INTO user_identities (user_id, provider, subject, email) VALUES ($1, $2, $3, $4)
+	`, userID, identity.Provider, identity.Subject, identity.Email)
+	if err != nil {
+		return fmt.Errorf("не удалось привязать внешний аккаунт: %w", err)
+	}
+	return nil
+}
+
+// usernameCandidate выбирает имя пользователя из preferred_username или email
+func usernameCandidate(identity ExternalIdentity) string {
+	candidate := identity.PreferredUsername
+	if candidate == "" {
+		candidate, _, _ = strings.Cut(identity.Email, "@")
+	}
+
+	var b strings.Builder
+	for _, r := range candidate {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-' || r == '.' {
+			b.WriteRune(r)
+		}
+	}
+	if b.Len() == 0 {
+		return "user"
+	}
+	return b.String()
+}
+
+// availableUsername добавляет к имени номер, если оно уже занято
+func availableUsername(ctx context.Context, tx *sql.Tx, base string) (string, error) {
+	candidate := base
+	for i := 2; ; i++ {
+		var exists bool
+		err := tx.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM users WHERE username = $1)", candidate).Scan(&exists)
+		if err != nil {
+			return "", fmt.Errorf("не удалось проверить имя пользователя: %w", err)
+		}
+		if !exists {
+			return candidate, nil
+		}
+		candidate = base + strconv.Itoa(i)
+	}
+}
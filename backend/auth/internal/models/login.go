@@ -0,0 +1,155 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"forum/backend/auth/internal/db"
+	"strings"
+	"time"
+)
+
+// Ключи счетчиков неудачных попыток входа
+const (
+	ThrottleUser = "user"
+	ThrottleIP   = "ip"
+)
+
+var (
+	// LoginFreeAttempts - сколько неудачных попыток по имени пользователя допускается без задержки
+	LoginFreeAttempts = 3
+	// LoginIPFreeAttempts - то же для IP: за одним адресом может быть много пользователей
+	LoginIPFreeAttempts = 20
+	// LoginBaseDelay - задержка после первой сверх допустимых попытки, дальше удваивается
+	LoginBaseDelay = time.Second
+	// LoginMaxDelay - наибольшая задержка между попытками
+	LoginMaxDelay = 5 * time.Minute
+	// LockoutThreshold - после стольких неудачных попыток аккаунт блокируется
+	LockoutThreshold = 10
+	// LockoutDuration - срок блокировки аккаунта, раньше ее может снять администратор
+	LockoutDuration = 30 * time.Minute
+	// LoginFailureWindow - через столько после последней неудачи счетчик начинается заново
+	LoginFailureWindow = time.Hour
+)
+
+// throttleKey нормализует имя пользователя, чтобы регистр не давал обойти счетчик
+func throttleKey(kind, key string) string {
+	if kind == ThrottleUser {
+		return strings.ToLower(strings.TrimSpace(key))
+	}
+	return key
+}
+
+// LoginBlockedFor возвращает, сколько еще заблокирован вход для имени пользователя или IP.
+// Ноль - вход разрешен
+func LoginBlockedFor(ctx context.Context, username, ip string) (time.Duration, error) {
+	var blockedUntil sql.NullTime
+	err := db.Db.QueryRowContext(ctx, `
+		SELECT max(blocked_until) FROM login_throttle
+		WHERE (kind = $1 AND key = $2) OR (kind = $3 AND key = $4)
+	`, ThrottleUser, throttleKey(ThrottleUser, username), ThrottleIP, ip).Scan(&blockedUntil)
+	if err != nil {
+		return 0, fmt.Errorf("не удалось проверить блокировку входа: %w", err)
+	}
+
+	if !blockedUntil.Valid {
+		return 0, nil
+	}
+	if wait := time.Until(blockedUntil.Time); wait > 0 {
+		return wait, nil
+	}
+	return 0, nil
+}
+
+// RecordLoginFailure увеличивает счетчики неудачных попыток для имени пользователя и IP
+// и назначает задержку до следующей попытки. Возвращает true, если попытка заблокировала аккаунт.
+// Во время блокировки попытки не учитываются, поэтому после ее истечения
+// каждая следующая неудача блокирует аккаунт снова
+func RecordLoginFailure(ctx context.Context, username, ip string) (bool, error) {
+	// Попытка учитывается, даже если клиент разорвал соединение, не дождавшись ответа
+	ctx = context.WithoutCancel(ctx)
+	failures, err := recordFailure(ctx, ThrottleUser, username)
+	if err != nil {
+		return false, err
+	}
+
+	locked := failures >= LockoutThreshold
+	delay := backoff(failures, LoginFreeAttempts)
+	if locked {
+		delay = LockoutDuration
+	}
+	if err := setBlockedUntil(ctx, ThrottleUser, username, delay); err != nil {
+		return false, err
+	}
+
+	if ip != "" {
+		ipFailures, err := recordFailure(ctx, ThrottleIP, ip)
+		if err != nil {
+			return false, err
+		}
+		if err := setBlockedUntil(ctx, ThrottleIP, ip, backoff(ipFailures, LoginIPFreeAttempts)); err != nil {
+			return false, err
+		}
+	}
+
+	return locked, nil
+}
+
+func recordFailure(ctx context.Context, kind, key string) (int, error) {
+	var failures int
+	err := db.Db.QueryRowContext(ctx, `
+		INSERT INTO login_throttle (kind, key, failures, last_failure_at)
+		VALUES ($1, $2, 1, CURRENT_TIMESTAMP)
+		ON CONFLICT (kind, key) DO UPDATE SET
+			failures = CASE
+				WHEN login_throttle.last_failure_at < CURRENT_TIMESTAMP - make_interval(secs => $3)
+				THEN 1 ELSE login_throttle.failures + 1 END,
+			last_failure_at = CURRENT_TIMESTAMP
+		RETURNING failures
+	`, kind, throttleKey(kind, key), LoginFailureWindow.Seconds()).Scan(&failures)
+	if err != nil {
+		return 0, fmt.Errorf("не удалось сохранить неудачную попытку входа: %w", err)
+	}
+	return failures, nil
+}
+
+func setBlockedUntil(ctx context.Context, kind, key string, delay time.Duration) error {
+	if delay <= 0 {
+		return nil
+	}
+	_, err := db.Db.ExecContext(ctx, `
+		UPDATE login_throttle SET blocked_until = $3 WHERE kind = $1 AND key = $2
+	`, kind, throttleKey(kind, key), time.Now().Add(delay))
+	if err != nil {
+		return fmt.Errorf("не удалось сохранить блокировку входа: %w", err)
+	}
+	return nil
+}
+
+// backoff возвращает задержку после failures неудачных попыток: после free попыток
+// без задержки она начинается с LoginBaseDelay и удваивается до LoginMaxDelay
+func backoff(failures, free int) time.Duration {
+	if failures <= free {
+		return 0
+	}
+	delay := LoginBaseDelay
+	for i := free + 1; i < failures && delay < LoginMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > LoginMaxDelay {
+		delay = LoginMaxDelay
+	}
+	return delay
+}
+
+// ResetLoginFailures сбрасывает счетчик и блокировку по имени пользователя.
+// Вызывается после успешного входа и при разблокировке администратором
+func ResetLoginFailures(ctx context.Context, username string) error {
+	_, err := db.Db.ExecContext(ctx, `
+		DELETE FROM login_throttle WHERE kind = $1 AND key = $2
+	`, ThrottleUser, throttleKey(ThrottleUser, username))
+	if err != nil {
+		return fmt.Errorf("не удалось сбросить счетчик попыток входа: %w", err)
+	}
+	return nil
+}
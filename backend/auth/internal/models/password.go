@@ -0,0 +1,132 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"forum/backend/auth/internal/db"
+	"time"
+)
+
+var (
+	// PasswordResetTTL - срок действия токена сброса пароля
+	PasswordResetTTL = time.Hour
+	// PasswordResetInterval - минимальный интервал между письмами сброса пароля на один адрес
+	PasswordResetInterval = time.Minute
+	// PasswordResetHourlyLimit - сколько писем сброса пароля можно отправить на один адрес за час
+	PasswordResetHourlyLimit = 5
+)
+
+var (
+	// ErrResetTokenInvalid - токен сброса не найден, истек или уже использован
+	ErrResetTokenInvalid = errors.New("invalid reset token")
+	// ErrResetRateLimited - сброс пароля запрошен слишком часто
+	ErrResetRateLimited = errors.New("password reset rate limited")
+)
+
+// GetUserByEmail ищет активного пользователя по email без учета регистра
+func GetUserByEmail(ctx context.Context, email string) (*User, error) {
+	var u User
+	err := scanUser(db.Db.QueryRowContext(ctx, "SELECT "+userColumns+" FROM users WHERE lower(email) = lower($1) AND deleted_at IS NULL", email), &u)
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// UpdatePassword сохраняет новый хеш пароля и завершает все сессии пользователя:
+// отзывает refresh токены и выпущенные ранее access токены
+func UpdatePassword(ctx context.Context, userID int, passwordHash string) error {
+	tx, err := db.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("не удалось начать транзакцию: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := updatePassword(ctx, tx, userID, passwordHash); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("не удалось завершить транзакцию: %w", err)
+	}
+	return nil
+}
+
+func updatePassword(ctx context.Context, e execer, userID int, passwordHash string) error {
+	_, err := e.ExecContext(ctx, `
+		UPDATE users SET password_hash = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2
+	`, passwordHash, userID)
+	if err != nil {
+		return fmt.Errorf("не удалось обновить пароль: %w", err)
+	}
+	if err := revokeUserRefreshTokens(ctx, e, userID); err != nil {
+		return err
+	}
+	return revokeUserAccessTokens(ctx, e, userID)
+}
+
+// CreatePasswordResetToken выпускает токен сброса пароля и возвращает его в открытом виде.
+// Если лимит писем исчерпан, возвращает ErrResetRateLimited
+func CreatePasswordResetToken(ctx context.Context, userID int) (string, error) {
+	wait, err := emailRateLimit(ctx, "password_reset_tokens", userID, PasswordResetInterval, PasswordResetHourlyLimit)
+	if err != nil {
+		return "", err
+	}
+	if wait > 0 {
+		return "", ErrResetRateLimited
+	}
+
+	token := randomToken(32)
+	_, err = db.Db.ExecContext(ctx, `
+		INSERT INTO password_reset_tokens (user_id, token_hash, expires_at)
+		VALUES ($1, $2, $3)
+	`, userID, hashToken(token), time.Now().Add(PasswordResetTTL))
+	if err != nil {
+		return "", fmt.Errorf("не удалось сохранить токен сброса пароля: %w", err)
+	}
+	return token, nil
+}
+
+// ConsumePasswordResetToken помечает токен использованным, сохраняет новый хеш пароля
+// как UpdatePassword и возвращает ID пользователя. Остальные неиспользованные токены
+// пользователя тоже становятся недействительными. Все происходит в одной транзакции,
+// поэтому при ошибке ссылка из письма остается действительной
+func ConsumePasswordResetToken(ctx context.Context, token, passwordHash string) (int, error) {
+	tx, err := db.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("не удалось начать транзакцию: %w", err)
+	}
+	defer tx.Rollback()
+
+	var userID int
+	err = tx.QueryRowContext(ctx, `
+		UPDATE password_reset_tokens SET used_at = now()
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > now()
+		RETURNING user_id
+	`, hashToken(token)).Scan(&userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrResetTokenInvalid
+	}
+	if err != nil {
+		return 0, fmt.Errorf("не удалось проверить токен сброса пароля: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE password_reset_tokens SET used_at = now()
+		WHERE user_id = $1 AND used_at IS NULL
+	`, userID)
+	if err != nil {
+		return 0, fmt.Errorf("не удалось отозвать токены сброса пароля: %w", err)
+	}
+
+	if err := updatePassword(ctx, tx, userID, passwordHash); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("не удалось завершить транзакцию: %w", err)
+	}
+	return userID, nil
+}
@@ -0,0 +1,54 @@
+package models_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"forum/backend/auth/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConsumePasswordResetToken(t *testing.T) {
+	setupTestDB(t)
+	ctx := context.Background()
+	userID := createTestUser(t, "forgetful")
+
+	token, err := models.CreatePasswordResetToken(ctx, userID)
+	require.NoError(t, err)
+	refreshToken, err := models.CreateRefreshToken(ctx, userID)
+	require.NoError(t, err)
+	issuedAt := time.Now().Add(-time.Second)
+
+	_, err = models.ConsumePasswordResetToken(ctx, "unknown", "new_hash")
+	assert.ErrorIs(t, err, models.ErrResetTokenInvalid)
+
+	gotUserID, err := models.ConsumePasswordResetToken(ctx, token, "new_hash")
+	require.NoError(t, err)
+	assert.Equal(t, userID, gotUserID)
+
+	// Новый пароль сохранен, а все сессии завершены
+	user, err := models.GetUserByID(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, "new_hash", user.PasswordHash)
+	_, _, err = models.RotateRefreshToken(ctx, refreshToken)
+	assert.Error(t, err)
+	revoked, err := models.IsTokenRevoked(ctx, "access-jti", userID, issuedAt)
+	require.NoError(t, err)
+	assert.True(t, revoked)
+
+	// Ссылка из письма одноразовая
+	_, err = models.ConsumePasswordResetToken(ctx, token, "other_hash")
+	assert.ErrorIs(t, err, models.ErrResetTokenInvalid)
+}
+
+func TestGetUserByEmailIgnoresCase(t *testing.T) {
+	setupTestDB(t)
+	userID := createTestUser(t, "mixedcase")
+
+	user, err := models.GetUserByEmail(context.Background(), "MixedCase@Example.com")
+	require.NoError(t, err)
+	assert.Equal(t, userID, user.ID)
+}
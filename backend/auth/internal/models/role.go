@@ -0,0 +1,95 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"forum/backend/auth/internal/db"
+	"sync"
+	"time"
+)
+
+// Роли пользователей
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+// Права доступа. Соответствие ролей и прав хранится в таблице role_permissions
+const (
+	PermUsersList        = "users:list"
+	PermUsersCreate      = "users:create"
+	PermUsersUpdate      = "users:update"
+	PermUsersDelete      = "users:delete"
+	PermUsersManageRoles = "users:manage_roles"
+	PermTopicsModerate   = "topics:moderate"
+	PermCommentsModerate = "comments:moderate"
+	PermRetentionManage  = "retention:manage"
+)
+
+// PermissionsCacheTTL - как долго права ролей берутся из памяти без обращения к БД
+var PermissionsCacheTTL = time.Minute
+
+var permissionsCache = struct {
+	sync.Mutex
+	roles    map[string][]string
+	loadedAt time.Time
+}{}
+
+// IsValidRole проверяет, что роль существует
+func IsValidRole(ctx context.Context, role string) (bool, error) {
+	var exists bool
+	err := db.Db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM roles WHERE name = $1)", role).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("не удалось проверить роль: %w", err)
+	}
+	return exists, nil
+}
+
+// GetRolePermissions возвращает права роли
+func GetRolePermissions(ctx context.Context, role string) ([]string, error) {
+	permissionsCache.Lock()
+	defer permissionsCache.Unlock()
+
+	if permissionsCache.roles == nil || time.Since(permissionsCache.loadedAt) > PermissionsCacheTTL {
+		roles, err := loadRolePermissions(ctx)
+		if err != nil {
+			return nil, err
+		}
+		permissionsCache.roles = roles
+		permissionsCache.loadedAt = time.Now()
+	}
+	return permissionsCache.roles[role], nil
+}
+
+// HasPermission проверяет, что у роли есть право permission
+func HasPermission(ctx context.Context, role, permission string) (bool, error) {
+	permissions, err := GetRolePermissions(ctx, role)
+	if err != nil {
+		return false, err
+	}
+	for _, p := range permissions {
+		if p == permission {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func loadRolePermissions(ctx context.Context) (map[string][]string, error) {
+	rows, err := db.Db.QueryContext(ctx, "SELECT role, permission FROM role_permissions ORDER BY role, permission")
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить права ролей: %w", err)
+	}
+	defer rows.Close()
+
+	roles := make(map[string][]string)
+	for rows.Next() {
+		var role, permission string
+		if err := rows.Scan(&role, &permission); err != nil {
+			return nil, fmt.Errorf("не удалось прочитать права роли: %w", err)
+		}
+		roles[role] = append(roles[role], permission)
+	}
+	return roles, rows.Err()
+}
@@ -0,0 +1,69 @@
+package models_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+
+	"forum/backend/auth/internal/db"
+	"forum/backend/auth/internal/models"
+
+	"github.com/stretchr/testify/require"
+)
+
+// setupTestDB подключается к тестовой БД auth-сервиса с примененными миграциями
+// и очищает таблицы пользователей и токенов
+func setupTestDB(t *testing.T) {
+	t.Helper()
+
+	// Сохраняем оригинальные значения переменных окружения
+	originalEnv := make(map[string]string)
+	for _, key := range []string{"DB_HOST", "DB_PORT", "DB_USER", "DB_PASSWORD", "DB_NAME", "DB_SSLMODE"} {
+		if value, exists := os.LookupEnv(key); exists {
+			originalEnv[key] = value
+		}
+	}
+	defer func() {
+		// Восстанавливаем оригинальные значения
+		for key, value := range originalEnv {
+			os.Setenv(key, value)
+		}
+	}()
+
+	// Устанавливаем тестовые значения
+	os.Setenv("DB_HOST", "localhost")
+	os.Setenv("DB_PORT", "5432")
+	os.Setenv("DB_USER", "postgres")
+	os.Setenv("DB_PASSWORD", "Sashaezhak2006")
+	os.Setenv("DB_NAME", "auth_test")
+	os.Setenv("DB_SSLMODE", "disable")
+
+	connStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
+		os.Getenv("DB_HOST"),
+		os.Getenv("DB_PORT"),
+		os.Getenv("DB_USER"),
+		os.Getenv("DB_PASSWORD"),
+		os.Getenv("DB_NAME"),
+		os.Getenv("DB_SSLMODE"))
+	require.NoError(t, db.Open(connStr))
+	t.Cleanup(func() { db.Db.Close() })
+
+	_, err := db.Db.Exec(`
+		TRUNCATE users, revoked_tokens, login_throttle, auth_audit_log RESTART IDENTITY CASCADE
+	`)
+	require.NoError(t, err)
+}
+
+// createTestUser добавляет пользователя с ролью user и возвращает его ID
+func createTestUser(t *testing.T, username string) int {
+	t.Helper()
+	id, err := models.AddUser(context.Background(), &models.User{
+		Name:         "Test User",
+		Username:     username,
+		Email:        username + "@example.com",
+		PasswordHash: "test_hash",
+	})
+	require.NoError(t, err)
+	return id
+}
@@ -0,0 +1,205 @@
+package models
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"forum/backend/auth/internal/db"
+	"time"
+)
+
+// RefreshTokenTTL - срок действия refresh токена
+var RefreshTokenTTL = 30 * 24 * time.Hour
+
+var (
+	// ErrRefreshTokenInvalid - токен не найден, истек или отозван
+	ErrRefreshTokenInvalid = errors.New("invalid refresh token")
+	// ErrRefreshTokenReused - повторное использование уже замененного токена.
+	// Вся цепочка ротации отзывается, так как токен мог быть украден
+	ErrRefreshTokenReused = errors.New("refresh token reuse detected")
+)
+
+func randomToken(size int) string {
+	b := make([]byte, size)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateRefreshToken выпускает refresh токен новой сессии и возвращает его в открытом виде
+func CreateRefreshToken(ctx context.Context, userID int) (string, error) {
+	return insertRefreshToken(ctx, db.Db, userID, randomToken(16))
+}
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+func insertRefreshToken(ctx context.Context, e execer, userID int, familyID string) (string, error) {
+	token := randomToken(32)
+	_, err := e.ExecContext(ctx, `
+		INSERT INTO refresh_tokens (user_id, token_hash, family_id, expires_at)
+		VALUES ($1, $2, $3, $4)
+	`, userID, hashToken(token), familyID, time.Now().Add(RefreshTokenTTL))
+	if err != nil {
+		return "", fmt.Errorf("не удалось сохранить refresh токен: %w", err)
+	}
+	return token, nil
+}
+
+// RotateRefreshToken отзывает предъявленный refresh токен и выпускает новый в той же цепочке.
+// Возвращает ID пользователя и новый токен
+func RotateRefreshToken(ctx context.Context, token string) (int, string, error) {
+	tx, err := db.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, "", fmt.Errorf("не удалось начать транзакцию: %w", err)
+	}
+	defer tx.Rollback()
+
+	var (
+		id        int
+		userID    int
+		familyID  string
+		expiresAt time.Time
+		revokedAt sql.NullTime
+	)
+	err = tx.QueryRowContext(ctx, `
+		SELECT id, user_id, family_id, expires_at, revoked_at
+		FROM refresh_tokens
+		WHERE token_hash = $1
+		FOR UPDATE
+	`, hashToken(token)).Scan(&id, &userID, &familyID, &expiresAt, &revokedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, "", ErrRefreshTokenInvalid
+	}
+	if err != nil {
+		return 0, "", fmt.Errorf("не удалось получить refresh токен: %w", err)
+	}
+
+	if revokedAt.Valid {
+		if err := revokeFamily(ctx, tx, familyID); err != nil {
+			return 0, "", err
+		}
+		if err := tx.Commit(); err != nil {
+			return 0, "", fmt.Errorf("не удалось завершить транзакцию: %w", err)
+		}
+		return 0, "", ErrRefreshTokenReused
+	}
+	if time.Now().After(expiresAt) {
+		return 0, "", ErrRefreshTokenInvalid
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE refresh_tokens SET revoked_at = now() WHERE id = $1`, id); err != nil {
+		return 0, "", fmt.Errorf("не удалось отозвать refresh токен: %w", err)
+	}
+	newToken, err := insertRefreshToken(ctx, tx, userID, familyID)
+	if err != nil {
+		return 0, "", err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, "", fmt.Errorf("не удалось завершить транзакцию: %w", err)
+	}
+	return userID, newToken, nil
+}
+
+func revokeFamily(ctx context.Context, e execer, familyID string) error {
+	_, err := e.ExecContext(ctx, `
+		UPDATE refresh_tokens SET revoked_at = now()
+		WHERE family_id = $1 AND revoked_at IS NULL
+	`, familyID)
+	if err != nil {
+		return fmt.Errorf("не удалось отозвать цепочку refresh токенов: %w", err)
+	}
+	return nil
+}
+
+// RevokeRefreshToken завершает сессию: отзывает цепочку, к которой относится токен пользователя
+func RevokeRefreshToken(ctx context.Context, token string, userID int) error {
+	var familyID string
+	err := db.Db.QueryRowContext(ctx, `
+		SELECT family_id FROM refresh_tokens WHERE token_hash = $1 AND user_id = $2
+	`, hashToken(token), userID).Scan(&familyID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrRefreshTokenInvalid
+	}
+	if err != nil {
+		return fmt.Errorf("не удалось получить refresh токен: %w", err)
+	}
+	return revokeFamily(ctx, db.Db, familyID)
+}
+
+// RevokeAllRefreshTokens отзывает все refresh токены пользователя
+func RevokeAllRefreshTokens(ctx context.Context, userID int) error {
+	return revokeUserRefreshTokens(ctx, db.Db, userID)
+}
+
+func revokeUserRefreshTokens(ctx context.Context, e execer, userID int) error {
+	_, err := e.ExecContext(ctx, `
+		UPDATE refresh_tokens SET revoked_at = now()
+		WHERE user_id = $1 AND revoked_at IS NULL
+	`, userID)
+	if err != nil {
+		return fmt.Errorf("не удалось отозвать refresh токены: %w", err)
+	}
+	return nil
+}
+
+// RevokeAccessToken добавляет access токен в denylist до истечения его срока
+func RevokeAccessToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	_, err := db.Db.ExecContext(ctx, `
+		INSERT INTO revoked_tokens (jti, expires_at) VALUES ($1, $2)
+		ON CONFLICT (jti) DO NOTHING
+	`, jti, expiresAt)
+	if err != nil {
+		return fmt.Errorf("не удалось отозвать токен: %w", err)
+	}
+
+	// Истекшие токены в denylist больше не нужны
+	_, err = db.Db.ExecContext(ctx, `DELETE FROM revoked_tokens WHERE expires_at < now()`)
+	if err != nil {
+		return fmt.Errorf("не удалось очистить отозванные токены: %w", err)
+	}
+	return nil
+}
+
+// RevokeAllAccessTokens делает недействительными все access токены пользователя, выпущенные до этого момента
+func RevokeAllAccessTokens(ctx context.Context, userID int) error {
+	return revokeUserAccessTokens(ctx, db.Db, userID)
+}
+
+func revokeUserAccessTokens(ctx context.Context, e execer, userID int) error {
+	_, err := e.ExecContext(ctx, `
+		INSERT INTO user_token_revocations (user_id, revoked_at) VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE SET revoked_at = EXCLUDED.revoked_at
+	`, userID, time.Now().Truncate(time.Millisecond))
+	if err != nil {
+		return fmt.Errorf("не удалось отозвать токены пользователя: %w", err)
+	}
+	return nil
+}
+
+// IsTokenRevoked проверяет access токен по denylist и по времени выхода со всех устройств.
+// iat и время отзыва хранятся с точностью до миллисекунды, поэтому токен, выпущенный
+// в ту же миллисекунду, что и отзыв, остается действительным: иначе токен новой сессии,
+// выпущенный сразу после выхода со всех устройств, был бы отозван вместе со старыми
+func IsTokenRevoked(ctx context.Context, jti string, userID int, issuedAt time.Time) (bool, error) {
+	var revoked bool
+	err := db.Db.QueryRowContext(ctx, `
+		SELECT EXISTS (SELECT 1 FROM revoked_tokens WHERE jti = $1)
+		    OR EXISTS (SELECT 1 FROM user_token_revocations WHERE user_id = $2 AND revoked_at > $3)
+	`, jti, userID, issuedAt).Scan(&revoked)
+	if err != nil {
+		return false, fmt.Errorf("не удалось проверить отзыв токена: %w", err)
+	}
+	return revoked, nil
+}
@@ -0,0 +1,128 @@
+package models_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"forum/backend/auth/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRotateRefreshToken(t *testing.T) {
+	setupTestDB(t)
+	ctx := context.Background()
+	userID := createTestUser(t, "rotate_user")
+
+	first, err := models.CreateRefreshToken(ctx, userID)
+	require.NoError(t, err)
+
+	gotUserID, second, err := models.RotateRefreshToken(ctx, first)
+	require.NoError(t, err)
+	assert.Equal(t, userID, gotUserID)
+	assert.NotEqual(t, first, second)
+
+	// Новый токен цепочки продолжает работать
+	_, third, err := models.RotateRefreshToken(ctx, second)
+	require.NoError(t, err)
+	assert.NotEmpty(t, third)
+
+	_, _, err = models.RotateRefreshToken(ctx, "unknown")
+	assert.ErrorIs(t, err, models.ErrRefreshTokenInvalid)
+}
+
+func TestRefreshTokenReplayRevokesFamily(t *testing.T) {
+	setupTestDB(t)
+	ctx := context.Background()
+	userID := createTestUser(t, "replay_user")
+
+	stolen, err := models.CreateRefreshToken(ctx, userID)
+	require.NoError(t, err)
+	_, current, err := models.RotateRefreshToken(ctx, stolen)
+	require.NoError(t, err)
+
+	// Другая сессия того же пользователя не затрагивается
+	other, err := models.CreateRefreshToken(ctx, userID)
+	require.NoError(t, err)
+
+	_, _, err = models.RotateRefreshToken(ctx, stolen)
+	assert.ErrorIs(t, err, models.ErrRefreshTokenReused)
+
+	// После повтора отозвана вся цепочка, включая токен законного владельца
+	_, _, err = models.RotateRefreshToken(ctx, current)
+	assert.ErrorIs(t, err, models.ErrRefreshTokenReused)
+
+	_, _, err = models.RotateRefreshToken(ctx, other)
+	assert.NoError(t, err)
+}
+
+func TestRevokeRefreshToken(t *testing.T) {
+	setupTestDB(t)
+	ctx := context.Background()
+	userID := createTestUser(t, "logout_user")
+	otherID := createTestUser(t, "other_user")
+
+	token, err := models.CreateRefreshToken(ctx, userID)
+	require.NoError(t, err)
+
+	// Чужой токен отозвать нельзя
+	assert.ErrorIs(t, models.RevokeRefreshToken(ctx, token, otherID), models.ErrRefreshTokenInvalid)
+
+	require.NoError(t, models.RevokeRefreshToken(ctx, token, userID))
+	_, _, err = models.RotateRefreshToken(ctx, token)
+	assert.Error(t, err)
+}
+
+func TestLogoutAllInvalidatesEarlierAccessTokens(t *testing.T) {
+	setupTestDB(t)
+	ctx := context.Background()
+	userID := createTestUser(t, "logout_all_user")
+	otherID := createTestUser(t, "bystander_user")
+
+	refresh, err := models.CreateRefreshToken(ctx, userID)
+	require.NoError(t, err)
+
+	issuedBefore := time.Now().Add(-time.Second).Truncate(time.Millisecond)
+	revoked, err := models.IsTokenRevoked(ctx, "before", userID, issuedBefore)
+	require.NoError(t, err)
+	require.False(t, revoked)
+
+	require.NoError(t, models.RevokeAllAccessTokens(ctx, userID))
+	require.NoError(t, models.RevokeAllRefreshTokens(ctx, userID))
+
+	revoked, err = models.IsTokenRevoked(ctx, "before", userID, issuedBefore)
+	require.NoError(t, err)
+	assert.True(t, revoked)
+
+	// Токен, выпущенный сразу после отзыва с точностью iat до миллисекунды, действителен
+	issuedAfter := time.Now().Truncate(time.Millisecond)
+	revoked, err = models.IsTokenRevoked(ctx, "after", userID, issuedAfter)
+	require.NoError(t, err)
+	assert.False(t, revoked)
+
+	// Отзыв касается только этого пользователя
+	revoked, err = models.IsTokenRevoked(ctx, "other", otherID, issuedBefore)
+	require.NoError(t, err)
+	assert.False(t, revoked)
+
+	_, _, err = models.RotateRefreshToken(ctx, refresh)
+	assert.Error(t, err)
+}
+
+func TestRevokeAccessToken(t *testing.T) {
+	setupTestDB(t)
+	ctx := context.Background()
+	userID := createTestUser(t, "denylist_user")
+
+	require.NoError(t, models.RevokeAccessToken(ctx, "jti-1", time.Now().Add(time.Minute)))
+
+	revoked, err := models.IsTokenRevoked(ctx, "jti-1", userID, time.Now())
+	require.NoError(t, err)
+	assert.True(t, revoked)
+
+	revoked, err = models.IsTokenRevoked(ctx, "jti-2", userID, time.Now())
+	require.NoError(t, err)
+	assert.False(t, revoked)
+}
@@ -0,0 +1,226 @@
+package models
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"forum/backend/auth/internal/db"
+	"forum/backend/auth/internal/totp"
+	"strings"
+	"time"
+)
+
+// RecoveryCodeCount - сколько кодов восстановления выдается при включении 2FA
+const RecoveryCodeCount = 10
+
+var (
+	// ErrTOTPAlreadyEnabled - двухфакторная аутентификация уже включена
+	ErrTOTPAlreadyEnabled = errors.New("totp already enabled")
+	// ErrTOTPNotEnabled - двухфакторная аутентификация не включена
+	ErrTOTPNotEnabled = errors.New("totp not enabled")
+	// ErrTOTPNotConfigured - подключение 2FA не начато, секрета нет
+	ErrTOTPNotConfigured = errors.New("totp not configured")
+	// ErrSecondFactorInvalid - код TOTP или код восстановления не подошел
+	ErrSecondFactorInvalid = errors.New("invalid second factor code")
+)
+
+// StartTOTPEnrollment сохраняет новый секрет TOTP, пока 2FA не включена.
+// Повторный вызов заменяет секрет незавершенного подключения
+func StartTOTPEnrollment(ctx context.Context, userID int, secret string) error {
+	res, err := db.Db.ExecContext(ctx, `
+		UPDATE users SET totp_secret = $2, totp_last_step = NULL
+		WHERE id = $1 AND totp_enabled_at IS NULL
+	`, userID, secret)
+	if err != nil {
+		return fmt.Errorf("не удалось сохранить секрет TOTP: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrTOTPAlreadyEnabled
+	}
+	return nil
+}
+
+// EnableTOTP включает 2FA после проверки первого кода и возвращает коды восстановления
+func EnableTOTP(ctx context.Context, userID int, code string) ([]string, error) {
+	tx, err := db.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось начать транзакцию: %w", err)
+	}
+	defer tx.Rollback()
+
+	var (
+		secret    sql.NullString
+		enabledAt sql.NullTime
+	)
+	err = tx.QueryRowContext(ctx, `
+		SELECT totp_secret, totp_enabled_at FROM users WHERE id = $1 FOR UPDATE
+	`, userID).Scan(&secret, &enabledAt)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить секрет TOTP: %w", err)
+	}
+	if enabledAt.Valid {
+		return nil, ErrTOTPAlreadyEnabled
+	}
+	if !secret.Valid {
+		return nil, ErrTOTPNotConfigured
+	}
+
+	step, ok := totp.Validate(secret.String, code, time.Now())
+	if !ok {
+		return nil, ErrSecondFactorInvalid
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE users SET totp_enabled_at = CURRENT_TIMESTAMP, totp_last_step = $2 WHERE id = $1
+	`, userID, step)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось включить TOTP: %w", err)
+	}
+
+	codes, err := replaceRecoveryCodes(ctx, tx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("не удалось зафиксировать транзакцию: %w", err)
+	}
+	return codes, nil
+}
+
+// DisableTOTP выключает 2FA и удаляет коды восстановления
+func DisableTOTP(ctx context.Context, userID int) error {
+	tx, err := db.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("не удалось начать транзакцию: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE users SET totp_secret = NULL, totp_enabled_at = NULL, totp_last_step = NULL
+		WHERE id = $1
+	`, userID)
+	if err != nil {
+		return fmt.Errorf("не удалось выключить TOTP: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM recovery_codes WHERE user_id = $1", userID); err != nil {
+		return fmt.Errorf("не удалось удалить коды восстановления: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("не удалось зафиксировать транзакцию: %w", err)
+	}
+	return nil
+}
+
+// RegenerateRecoveryCodes заменяет коды восстановления новыми
+func RegenerateRecoveryCodes(ctx context.Context, userID int) ([]string, error) {
+	tx, err := db.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось начать транзакцию: %w", err)
+	}
+	defer tx.Rollback()
+
+	codes, err := replaceRecoveryCodes(ctx, tx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("не удалось зафиксировать транзакцию: %w", err)
+	}
+	return codes, nil
+}
+
+func replaceRecoveryCodes(ctx context.Context, e execer, userID int) ([]string, error) {
+	if _, err := e.ExecContext(ctx, "DELETE FROM recovery_codes WHERE user_id = $1", userID); err != nil {
+		return nil, fmt.Errorf("не удалось удалить коды восстановления: %w", err)
+	}
+
+	codes := make([]string, RecoveryCodeCount)
+	for i := range codes {
+		codes[i] = newRecoveryCode()
+		_, err := e.ExecContext(ctx, `
+			INSERT INTO recovery_codes (user_id, code_hash) VALUES ($1, $2)
+		`, userID, hashToken(normalizeRecoveryCode(codes[i])))
+		if err != nil {
+			return nil, fmt.Errorf("не удалось сохранить код восстановления: %w", err)
+		}
+	}
+	return codes, nil
+}
+
+// newRecoveryCode возвращает код вида "a1b2c-3d4e5"
+func newRecoveryCode() string {
+	b := make([]byte, 5)
+	rand.Read(b)
+	s := hex.EncodeToString(b)
+	return s[:5] + "-" + s[5:]
+}
+
+// normalizeRecoveryCode приводит введенный код к виду, в котором хранится хеш
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
+
+// VerifySecondFactor проверяет код TOTP или, если он не подошел, код восстановления.
+// Принятый код TOTP и использованный код восстановления повторно не принимаются
+func VerifySecondFactor(ctx context.Context, userID int, code string) error {
+	var (
+		secret    sql.NullString
+		enabledAt sql.NullTime
+	)
+	err := db.Db.QueryRowContext(ctx, `
+		SELECT totp_secret, totp_enabled_at FROM users WHERE id = $1
+	`, userID).Scan(&secret, &enabledAt)
+	if err != nil {
+		return fmt.Errorf("не удалось получить секрет TOTP: %w", err)
+	}
+	if !enabledAt.Valid || !secret.Valid {
+		return ErrTOTPNotEnabled
+	}
+
+	if step, ok := totp.Validate(secret.String, code, time.Now()); ok {
+		// Шаг обновляется только вперед, поэтому перехваченный код нельзя повторить
+		res, err := db.Db.ExecContext(ctx, `
+			UPDATE users SET totp_last_step = $2
+			WHERE id = $1 AND (totp_last_step IS NULL OR totp_last_step < $2)
+		`, userID, step)
+		if err != nil {
+			return fmt.Errorf("не удалось сохранить шаг TOTP: %w", err)
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return ErrSecondFactorInvalid
+		}
+		return nil
+	}
+
+	res, err := db.Db.ExecContext(ctx, `
+		UPDATE recovery_codes SET used_at = CURRENT_TIMESTAMP
+		WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+	`, userID, hashToken(normalizeRecoveryCode(code)))
+	if err != nil {
+		return fmt.Errorf("не удалось использовать код восстановления: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrSecondFactorInvalid
+	}
+	return nil
+}
+
+// RemainingRecoveryCodes возвращает количество неиспользованных кодов восстановления
+func RemainingRecoveryCodes(ctx context.Context, userID int) (int, error) {
+	var n int
+	err := db.Db.QueryRowContext(ctx, `
+		SELECT count(*) FROM recovery_codes WHERE user_id = $1 AND used_at IS NULL
+	`, userID).Scan(&n)
+	if err != nil {
+		return 0, fmt.Errorf("не удалось получить коды восстановления: %w", err)
+	}
+	return n, nil
+}
@@ -0,0 +1,73 @@
+package models_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"forum/backend/auth/internal/models"
+	"forum/backend/auth/internal/totp"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// enableTOTP включает 2FA пользователю и возвращает секрет и коды восстановления
+func enableTOTP(t *testing.T, userID int) (string, []string) {
+	t.Helper()
+	ctx := context.Background()
+
+	secret, err := totp.GenerateSecret()
+	require.NoError(t, err)
+	require.NoError(t, models.StartTOTPEnrollment(ctx, userID, secret))
+
+	// Код предыдущего шага, чтобы код текущего шага еще не был использован
+	code, err := totp.Code(secret, time.Now().Add(-totp.Period*time.Second))
+	require.NoError(t, err)
+	recovery, err := models.EnableTOTP(ctx, userID, code)
+	require.NoError(t, err)
+	return secret, recovery
+}
+
+func TestVerifySecondFactorRejectsReplay(t *testing.T) {
+	setupTestDB(t)
+	ctx := context.Background()
+	userID := createTestUser(t, "totp_user")
+	secret, _ := enableTOTP(t, userID)
+
+	code, err := totp.Code(secret, time.Now())
+	require.NoError(t, err)
+
+	require.NoError(t, models.VerifySecondFactor(ctx, userID, code))
+	assert.ErrorIs(t, models.VerifySecondFactor(ctx, userID, code), models.ErrSecondFactorInvalid)
+
+	// Код более раннего шага тоже не принимается после более позднего
+	earlier, err := totp.Code(secret, time.Now().Add(-totp.Period*time.Second))
+	require.NoError(t, err)
+	if earlier != code {
+		assert.ErrorIs(t, models.VerifySecondFactor(ctx, userID, earlier), models.ErrSecondFactorInvalid)
+	}
+}
+
+func TestVerifySecondFactorRecoveryCodeOnce(t *testing.T) {
+	setupTestDB(t)
+	ctx := context.Background()
+	userID := createTestUser(t, "recovery_user")
+	_, recovery := enableTOTP(t, userID)
+	require.Len(t, recovery, models.RecoveryCodeCount)
+
+	require.NoError(t, models.VerifySecondFactor(ctx, userID, recovery[0]))
+	assert.ErrorIs(t, models.VerifySecondFactor(ctx, userID, recovery[0]), models.ErrSecondFactorInvalid)
+
+	left, err := models.RemainingRecoveryCodes(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, models.RecoveryCodeCount-1, left)
+}
+
+func TestVerifySecondFactorNotEnabled(t *testing.T) {
+	setupTestDB(t)
+	userID := createTestUser(t, "plain_user")
+
+	err := models.VerifySecondFactor(context.Background(), userID, "123456")
+	assert.ErrorIs(t, err, models.ErrTOTPNotEnabled)
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
@@ -8,24 +9,48 @@ import (
 	"log"
 	"time"
 
+	"github.com/lib/pq"
 	"golang.org/x/crypto/bcrypt"
 )
 
 type User struct {
-	ID           int       `json:"id"`
-	Name         string    `json:"name"`
-	Username     string    `json:"username"`
-	Email        string    `json:"email"`
-	PasswordHash string    `json:"password_hash"`
-	IsAdmin      bool      `json:"is_admin"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
+	ID       int    `json:"id"`
+	Name     string `json:"name"`
+	Username string `json:"username"`
+	Email    string `json:"email"`
+	// PasswordHash никогда не отдается клиенту
+	PasswordHash string `json:"-"`
+	IsAdmin      bool   `json:"is_admin"`
+	Role         string `json:"role"`
+	// EmailVerifiedAt равен nil, пока пользователь не подтвердил email
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	// TOTPEnabledAt равен nil, пока не включена двухфакторная аутентификация
+	TOTPEnabledAt *time.Time `json:"totp_enabled_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
 }
 
-func GetAllUsers() []User {
+const userColumns = "id, name, username, email, password_hash, is_admin, role, email_verified_at, totp_enabled_at, created_at, updated_at"
+
+func scanUser(row interface{ Scan(...any) error }, u *User) error {
+	return row.Scan(&u.ID, &u.Name, &u.Username, &u.Email, &u.PasswordHash, &u.IsAdmin,
+		&u.Role, &u.EmailVerifiedAt, &u.TOTPEnabledAt, &u.CreatedAt, &u.UpdatedAt)
+}
+
+// IsEmailVerified проверяет, что пользователь подтвердил email
+func (u *User) IsEmailVerified() bool {
+	return u.EmailVerifiedAt != nil
+}
+
+// IsTOTPEnabled проверяет, что у пользователя включена двухфакторная аутентификация
+func (u *User) IsTOTPEnabled() bool {
+	return u.TOTPEnabledAt != nil
+}
+
+func GetAllUsers(ctx context.Context) []User {
 	var users []User
 
-	rows, err := db.Db.Query("SELECT * FROM users")
+	rows, err := db.Db.QueryContext(ctx, "SELECT "+userColumns+" FROM users WHERE deleted_at IS NULL")
 	if err != nil {
 		log.Fatal("Ошибка при выполнении запроса:", err)
 	}
@@ -34,8 +59,7 @@ func GetAllUsers() []User {
 	for rows.Next() {
 		var u User
 
-		err := rows.Scan(&u.ID, &u.Name, &u.Username,
-			&u.Email, &u.PasswordHash, &u.IsAdmin, &u.CreatedAt, &u.UpdatedAt)
+		err := scanUser(rows, &u)
 		if err != nil {
 			log.Println("Ошибка при сканировании строки:", err)
 			continue
@@ -49,11 +73,9 @@ func GetAllUsers() []User {
 	return users
 }
 
-func GetUserByID(id int) (*User, error) {
+func GetUserByID(ctx context.Context, id int) (*User, error) {
 	var u User
-	err := db.Db.QueryRow("SELECT * FROM users WHERE id = $1", id).
-		Scan(&u.ID, &u.Name, &u.Username,
-			&u.Email, &u.PasswordHash, &u.IsAdmin, &u.CreatedAt, &u.UpdatedAt)
+	err := scanUser(db.Db.QueryRowContext(ctx, "SELECT "+userColumns+" FROM users WHERE id = $1 AND deleted_at IS NULL", id), &u)
 
 	if err != nil {
 		return nil, err
@@ -62,51 +84,99 @@ func GetUserByID(id int) (*User, error) {
 	return &u, nil
 }
 
-func AddUser(u *User) (int, error) {
+// ErrUserExists - имя пользователя или email уже заняты
+var ErrUserExists = errors.New("username or email already taken")
+
+// isUniqueViolation проверяет, что запрос нарушил ограничение уникальности
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+func AddUser(ctx context.Context, u *User) (int, error) {
+	if u.Role == "" {
+		u.Role = RoleUser
+	}
+	// is_admin сохраняется для совместимости и всегда соответствует роли
+	u.IsAdmin = u.Role == RoleAdmin
+
 	var id int
 	query := `
-		INSERT INTO users (name, username, email, password_hash, is_admin)
-		VALUES ($1, $2, $3, $4, $5)
+		INSERT INTO users (name, username, email, password_hash, is_admin, role)
+		VALUES ($1, $2, $3, $4, $5, $6)
 		RETURNING id;
 	`
 
-	err := db.Db.QueryRow(query, &u.Name, &u.Username, &u.Email, &u.PasswordHash, &u.IsAdmin).Scan(&id)
+	err := db.Db.QueryRowContext(ctx, query, &u.Name, &u.Username, &u.Email, &u.PasswordHash, &u.IsAdmin, &u.Role).Scan(&id)
 	if err != nil {
+		if isUniqueViolation(err) {
+			return 0, ErrUserExists
+		}
 		return 0, fmt.Errorf("не удалось добавить пользователя: %w", err)
 	}
 	return id, nil
 }
 
-func DeleteUserByID(id int) error {
-	query := `DELETE FROM users WHERE id = $1`
-	_, err := db.Db.Exec(query, id)
+// PutUser обновляет профиль и роль пользователя. Пароль меняется только через UpdatePassword.
+// При смене роли все токены пользователя отзываются: права записаны в access токене,
+// и без отзыва прежняя роль действовала бы до его истечения
+func PutUser(ctx context.Context, id int, updated User) (User, error) {
+	if updated.Role == "" {
+		updated.Role = RoleUser
+	}
+	updated.IsAdmin = updated.Role == RoleAdmin
+
+	tx, err := db.Db.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("не удалось удалить пользователя: %w", err)
+		return User{}, fmt.Errorf("не удалось начать транзакцию: %w", err)
+	}
+	defer tx.Rollback()
+
+	var oldRole string
+	err = tx.QueryRowContext(ctx, "SELECT role FROM users WHERE id = $1 AND deleted_at IS NULL FOR UPDATE", id).
+		Scan(&oldRole)
+	if err != nil {
+		return User{}, fmt.Errorf("не удалось обновить пользователя: %w", err)
 	}
-	return nil
-}
 
-func PutUser(id int, updated User) (User, error) {
 	query := `
 		UPDATE users 
-		SET name = $1, username = $2, email = $3, password_hash = $4, is_admin = $5
-		WHERE id = $6
-		RETURNING id, name, username, email, password_hash, is_admin, created_at, updated_at
-	`
+		SET name = $1, username = $2, email = $3, is_admin = $4, role = $5,
+			email_verified_at = CASE WHEN email = $3 THEN email_verified_at END,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = $6 AND deleted_at IS NULL
+		RETURNING ` + userColumns
 	var u User
-	err := db.Db.QueryRow(query, updated.Name, updated.Username, updated.Email,
-		updated.PasswordHash, updated.IsAdmin, id).Scan(&u.ID, &u.Name, &u.Username,
-		&u.Email, &u.PasswordHash, &u.IsAdmin, &u.CreatedAt, &u.UpdatedAt)
+	err = scanUser(tx.QueryRowContext(ctx, query, updated.Name, updated.Username, updated.Email,
+		updated.IsAdmin, updated.Role, id), &u)
 	if err != nil {
+		if isUniqueViolation(err) {
+			return User{}, ErrUserExists
+		}
 		return User{}, fmt.Errorf("не удалось обновить пользователя: %w", err)
 	}
+
+	if u.Role != oldRole {
+		if err := revokeUserRefreshTokens(ctx, tx, id); err != nil {
+			return User{}, err
+		}
+		if err := revokeUserAccessTokens(ctx, tx, id); err != nil {
+			return User{}, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return User{}, fmt.Errorf("не удалось зафиксировать транзакцию: %w", err)
+	}
 	return u, nil
 }
 
-func GetUsernameByUserID(userID int) (string, error) {
+// GetUsernameByUserID возвращает имя пользователя. Для удаленного пользователя
+// возвращается DeletedUsername, чтобы его темы и комментарии остались на форуме
+func GetUsernameByUserID(ctx context.Context, userID int) (string, error) {
 	var username string
-	query := "SELECT username FROM users WHERE id = $1"
-	err := db.Db.QueryRow(query, userID).Scan(&username)
+	query := "SELECT " + usernameColumn + " FROM users WHERE id = $1"
+	err := db.Db.QueryRowContext(ctx, query, userID).Scan(&username)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return "", fmt.Errorf("user not found")
@@ -116,6 +186,36 @@ func GetUsernameByUserID(userID int) (string, error) {
 	return username, nil
 }
 
+// usernameColumn подменяет имя удаленного пользователя на DeletedUsername
+const usernameColumn = "CASE WHEN deleted_at IS NULL THEN username ELSE '" + DeletedUsername + "' END"
+
+func GetUsernamesByUserIDs(ctx context.Context, userIDs []int) (map[int]string, error) {
+	usernames := make(map[int]string, len(userIDs))
+	if len(userIDs) == 0 {
+		return usernames, nil
+	}
+
+	rows, err := db.Db.QueryContext(ctx, "SELECT id, "+usernameColumn+" FROM users WHERE id = ANY($1)", pq.Array(userIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		var username string
+		if err := rows.Scan(&id, &username); err != nil {
+			return nil, err
+		}
+		usernames[id] = username
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return usernames, nil
+}
+
 func HashPassword(password string) (string, error) {
 	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 14)
 	return string(bytes), err
@@ -126,24 +226,28 @@ func CheckPasswordHash(password, hash string) bool {
 	return err == nil
 }
 
-func AuthenticateUser(username, password string) (*User, error) {
+// ErrInvalidCredentials - неверное имя пользователя или пароль. Какое именно, намеренно не уточняется
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// dummyPasswordHash сравнивается с паролем, если пользователя нет, чтобы ответ
+// занимал столько же времени, сколько для существующего пользователя
+const dummyPasswordHash = "$2a$14$EyJfkLVxAhc8Ni1kuhBfxuaRyjlR1ZwHqRxfRJCLi34c.qz7Lyd6y"
+
+func AuthenticateUser(ctx context.Context, username, password string) (*User, error) {
 	var user User
-	query := "SELECT * FROM users WHERE username = $1"
-	err := db.Db.QueryRow(query, username).Scan(
-		&user.ID, &user.Name, &user.Username,
-		&user.Email, &user.PasswordHash, &user.IsAdmin,
-		&user.CreatedAt, &user.UpdatedAt,
-	)
+	query := "SELECT " + userColumns + " FROM users WHERE username = $1 AND deleted_at IS NULL"
+	err := scanUser(db.Db.QueryRowContext(ctx, query, username), &user)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, errors.New("user not found")
+			CheckPasswordHash(password, dummyPasswordHash)
+			return nil, ErrInvalidCredentials
 		}
 		return nil, err
 	}
 
 	if !CheckPasswordHash(password, user.PasswordHash) {
-		return nil, errors.New("invalid password")
+		return nil, ErrInvalidCredentials
 	}
 
 	return &user, nil
@@ -0,0 +1,119 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"forum/backend/auth/internal/db"
+	"time"
+)
+
+var (
+	// EmailVerificationTTL - срок действия ссылки подтверждения email
+	EmailVerificationTTL = 24 * time.Hour
+	// VerificationResendInterval - минимальный интервал между письмами подтверждения
+	VerificationResendInterval = time.Minute
+	// VerificationHourlyLimit - сколько писем подтверждения можно отправить за час
+	VerificationHourlyLimit = 5
+)
+
+var (
+	// ErrVerificationTokenInvalid - токен не найден, истек или уже использован
+	ErrVerificationTokenInvalid = errors.New("invalid verification token")
+	// ErrVerificationRateLimited - письмо подтверждения запрошено слишком часто
+	ErrVerificationRateLimited = errors.New("verification email rate limited")
+)
+
+// CreateEmailVerificationToken выпускает токен подтверждения текущего email пользователя
+// и возвращает его в открытом виде. Если лимит писем исчерпан, возвращает
+// ErrVerificationRateLimited и время, через которое можно повторить
+func CreateEmailVerificationToken(ctx context.Context, user *User) (string, time.Duration, error) {
+	wait, err := emailRateLimit(ctx, "email_verification_tokens", user.ID, VerificationResendInterval, VerificationHourlyLimit)
+	if err != nil {
+		return "", 0, err
+	}
+	if wait > 0 {
+		return "", wait, ErrVerificationRateLimited
+	}
+
+	token := randomToken(32)
+	_, err = db.Db.ExecContext(ctx, `
+		INSERT INTO email_verification_tokens (user_id, email, token_hash, expires_at)
+		VALUES ($1, $2, $3, $4)
+	`, user.ID, user.Email, hashToken(token), time.Now().Add(EmailVerificationTTL))
+	if err != nil {
+		return "", 0, fmt.Errorf("не удалось сохранить токен подтверждения: %w", err)
+	}
+	return token, 0, nil
+}
+
+// emailRateLimit ограничивает письма пользователю по времени выпуска токенов в table:
+// не чаще одного за interval и не больше hourlyLimit в час. Возвращает время
+// до следующей попытки или ноль, если письмо можно отправить
+func emailRateLimit(ctx context.Context, table string, userID int, interval time.Duration, hourlyLimit int) (time.Duration, error) {
+	var (
+		sentLastHour int
+		lastSentAt   sql.NullTime
+	)
+	err := db.Db.QueryRowContext(ctx, `
+		SELECT count(*) FILTER (WHERE created_at > now() - interval '1 hour'), max(created_at)
+		FROM `+table+`
+		WHERE user_id = $1
+	`, userID).Scan(&sentLastHour, &lastSentAt)
+	if err != nil {
+		return 0, fmt.Errorf("не удалось проверить лимит писем: %w", err)
+	}
+
+	if lastSentAt.Valid {
+		if wait := interval - time.Since(lastSentAt.Time); wait > 0 {
+			return wait, nil
+		}
+	}
+	if sentLastHour >= hourlyLimit {
+		return time.Hour, nil
+	}
+	return 0, nil
+}
+
+// VerifyEmail помечает email пользователя подтвержденным по токену из письма и возвращает ID пользователя
+func VerifyEmail(ctx context.Context, token string) (int, error) {
+	tx, err := db.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("не удалось начать транзакцию: %w", err)
+	}
+	defer tx.Rollback()
+
+	var (
+		userID int
+		email  string
+	)
+	err = tx.QueryRowContext(ctx, `
+		UPDATE email_verification_tokens SET used_at = now()
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > now()
+		RETURNING user_id, email
+	`, hashToken(token)).Scan(&userID, &email)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrVerificationTokenInvalid
+	}
+	if err != nil {
+		return 0, fmt.Errorf("не удалось проверить токен подтверждения: %w", err)
+	}
+
+	res, err := tx.ExecContext(ctx, `
+		UPDATE users SET email_verified_at = coalesce(email_verified_at, now())
+		WHERE id = $1 AND email = $2
+	`, userID, email)
+	if err != nil {
+		return 0, fmt.Errorf("не удалось подтвердить email: %w", err)
+	}
+	// Email сменился после отправки письма
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return 0, ErrVerificationTokenInvalid
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("не удалось завершить транзакцию: %w", err)
+	}
+	return userID, nil
+}
@@ -0,0 +1,201 @@
+// Package oidc реализует вход через внешних OpenID Connect провайдеров:
+// authorization code flow с PKCE и проверку id_token по ключам провайдера
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HTTPTimeout - таймаут запросов к провайдеру
+var HTTPTimeout = 10 * time.Second
+
+// ErrExchangeFailed возвращается, если провайдер не обменял код на токены
+var ErrExchangeFailed = errors.New("oidc code exchange failed")
+
+// Config - настройки провайдера
+type Config struct {
+	// Name - имя провайдера в адресах /auth/oidc/:provider
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL - адрес callback этого сервиса, зарегистрированный у провайдера
+	RedirectURL string
+	// Scopes дополняют обязательный openid
+	Scopes []string
+}
+
+// metadata - нужная часть документа /.well-known/openid-configuration
+type metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Provider - OIDC провайдер. Метаданные загружаются при первом обращении,
+// чтобы недоступность провайдера не мешала старту сервиса
+type Provider struct {
+	Config
+	client *http.Client
+
+	mu   sync.Mutex
+	meta *metadata
+	keys *keySet
+}
+
+// NewProvider создает провайдера с заданными настройками
+func NewProvider(cfg Config) *Provider {
+	return &Provider{Config: cfg, client: &http.Client{Timeout: HTTPTimeout}}
+}
+
+func (p *Provider) metadata(ctx context.Context) (*metadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.meta != nil {
+		return p.meta, nil
+	}
+
+	wellKnown := strings.TrimSuffix(p.Issuer, "/") + "/.well-known/openid-configuration"
+	var meta metadata
+	if err := p.getJSON(ctx, wellKnown, &meta); err != nil {
+		return nil, fmt.Errorf("не удалось получить метаданные провайдера %s: %w", p.Name, err)
+	}
+	// Издатель в метаданных должен совпадать с настроенным (OpenID Connect Discovery, 4.3)
+	if meta.Issuer != p.Issuer {
+		return nil, fmt.Errorf("провайдер %s вернул издателя %q вместо %q", p.Name, meta.Issuer, p.Issuer)
+	}
+	if meta.AuthorizationEndpoint == "" || meta.TokenEndpoint == "" || meta.JWKSURI == "" {
+		return nil, fmt.Errorf("в метаданных провайдера %s нет обязательных адресов", p.Name)
+	}
+
+	p.meta = &meta
+	p.keys = newKeySet(meta.JWKSURI, p.getJSON)
+	return p.meta, nil
+}
+
+func (p *Provider) getJSON(ctx context.Context, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v)
+}
+
+// AuthCodeURL возвращает адрес страницы входа провайдера
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, codeVerifier string) (string, error) {
+	meta, err := p.metadata(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	params := url.Values{}
+	params.Set("response_type", "code")
+	params.Set("client_id", p.ClientID)
+	params.Set("redirect_uri", p.RedirectURL)
+	params.Set("scope", strings.Join(append([]string{"openid"}, p.Scopes...), " "))
+	params.Set("state", state)
+	params.Set("nonce", nonce)
+	params.Set("code_challenge", CodeChallenge(codeVerifier))
+	params.Set("code_challenge_method", "S256")
+
+	sep := "?"
+	if strings.Contains(meta.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return meta.AuthorizationEndpoint + sep + params.Encode(), nil
+}
+
+// tokenResponse - ответ token endpoint (RFC 6749, 5.1)
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	IDToken     string `json:"id_token"`
+	Error       string `json:"error"`
+	Description string `json:"error_description"`
+}
+
+// Exchange обменивает код авторизации на id_token и проверяет его
+func (p *Provider) Exchange(ctx context.Context, code, codeVerifier, nonce string) (*Claims, error) {
+	meta, err := p.metadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.RedirectURL)
+	form.Set("code_verifier", codeVerifier)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, meta.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	// client_secret_basic: идентификатор и секрет кодируются как form-urlencoded (RFC 6749, 2.3.1)
+	req.SetBasicAuth(url.QueryEscape(p.ClientID), url.QueryEscape(p.ClientSecret))
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrExchangeFailed, err)
+	}
+	defer resp.Body.Close()
+
+	var tokens tokenResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&tokens); err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrExchangeFailed, resp.Status, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: %s: %s %s", ErrExchangeFailed, resp.Status, tokens.Error, tokens.Description)
+	}
+	if tokens.IDToken == "" {
+		return nil, fmt.Errorf("%w: в ответе нет id_token", ErrExchangeFailed)
+	}
+
+	return p.VerifyIDToken(ctx, tokens.IDToken, nonce)
+}
+
+// NewCodeVerifier возвращает случайный code_verifier для PKCE (RFC 7636)
+func NewCodeVerifier() string {
+	return randomString(32)
+}
+
+// CodeChallenge вычисляет code_challenge методом S256
+func CodeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// NewState возвращает случайное значение для параметров state и nonce
+func NewState() string {
+	return randomString(24)
+}
+
+func randomString(size int) string {
+	b := make([]byte, size)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,256 @@
+package oidc_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"forum/backend/auth/internal/oidc"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testClientID     = "forum"
+	testClientSecret = "secret"
+	testRedirectURL  = "http://localhost:8081/auth/oidc/mock/callback"
+)
+
+// mockProvider - минимальный OIDC провайдер: discovery, JWKS, authorize и token endpoint
+type mockProvider struct {
+	*httptest.Server
+	key *rsa.PrivateKey
+	// issuer, который провайдер сообщает в discovery
+	issuer string
+
+	mu    sync.Mutex
+	codes map[string]authRequest
+}
+
+type authRequest struct {
+	challenge string
+	nonce     string
+}
+
+func newMockProvider(t *testing.T) *mockProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	m := &mockProvider{key: key, codes: map[string]authRequest{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", m.discovery)
+	mux.HandleFunc("/jwks", m.jwks)
+	mux.HandleFunc("/authorize", m.authorize)
+	mux.HandleFunc("/token", m.token)
+	m.Server = httptest.NewServer(mux)
+	m.issuer = m.URL
+	t.Cleanup(m.Close)
+	return m
+}
+
+func (m *mockProvider) discovery(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(map[string]string{
+		"issuer":                 m.issuer,
+		"authorization_endpoint": m.URL + "/authorize",
+		"token_endpoint":         m.URL + "/token",
+		"jwks_uri":               m.URL + "/jwks",
+	})
+}
+
+func (m *mockProvider) jwks(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+		"kty": "RSA",
+		"kid": "mock-key",
+		"use": "sig",
+		"n":   base64.RawURLEncoding.EncodeToString(m.key.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(m.key.E)).Bytes()),
+	}}})
+}
+
+// authorize сразу "логинит" пользователя и возвращает его на redirect_uri с кодом
+func (m *mockProvider) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("code_challenge_method") != "S256" || q.Get("client_id") != testClientID {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	code := oidc.NewState()
+	m.mu.Lock()
+	m.codes[code] = authRequest{challenge: q.Get("code_challenge"), nonce: q.Get("nonce")}
+	m.mu.Unlock()
+
+	redirect, _ := url.Parse(q.Get("redirect_uri"))
+	params := url.Values{"code": {code}, "state": {q.Get("state")}}
+	redirect.RawQuery = params.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+func (m *mockProvider) token(w http.ResponseWriter, r *http.Request) {
+	id, secret, ok := r.BasicAuth()
+	if !ok || id != testClientID || secret != testClientSecret {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	m.mu.Lock()
+	req, ok := m.codes[r.PostFormValue("code")]
+	delete(m.codes, r.PostFormValue("code"))
+	m.mu.Unlock()
+
+	if !ok || oidc.CodeChallenge(r.PostFormValue("code_verifier")) != req.challenge {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{
+		"access_token": "access",
+		"token_type":   "Bearer",
+		"id_token":     m.idToken(jwt.MapClaims{"nonce": req.nonce}),
+	})
+}
+
+// idToken подписывает id_token; overrides заменяют стандартные claims
+func (m *mockProvider) idToken(overrides jwt.MapClaims) string {
+	claims := jwt.MapClaims{
+		"iss":            m.issuer,
+		"aud":            testClientID,
+		"sub":            "user-42",
+		"email":          "alice@example.com",
+		"email_verified": true,
+		"name":           "Alice",
+		"exp":            time.Now().Add(time.Hour).Unix(),
+		"iat":            time.Now().Unix(),
+	}
+	for k, v := range overrides {
+		claims[k] = v
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = "mock-key"
+	signed, _ := token.SignedString(m.key)
+	return signed
+}
+
+func newProvider(m *mockProvider) *oidc.Provider {
+	return oidc.NewProvider(oidc.Config{
+		Name:         "mock",
+		Issuer:       m.URL,
+		ClientID:     testClientID,
+		ClientSecret: testClientSecret,
+		RedirectURL:  testRedirectURL,
+		Scopes:       []string{"email", "profile"},
+	})
+}
+
+// authorize проходит страницу входа провайдера и возвращает параметры callback
+func authorize(t *testing.T, p *oidc.Provider, state, nonce, verifier string) url.Values {
+	t.Helper()
+	authURL, err := p.AuthCodeURL(context.Background(), state, nonce, verifier)
+	require.NoError(t, err)
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Get(authURL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+
+	location, err := url.Parse(resp.Header.Get("Location"))
+	require.NoError(t, err)
+	return location.Query()
+}
+
+func TestAuthorizationCodeFlow(t *testing.T) {
+	m := newMockProvider(t)
+	p := newProvider(m)
+
+	state, nonce, verifier := oidc.NewState(), oidc.NewState(), oidc.NewCodeVerifier()
+	callback := authorize(t, p, state, nonce, verifier)
+	assert.Equal(t, state, callback.Get("state"))
+
+	claims, err := p.Exchange(context.Background(), callback.Get("code"), verifier, nonce)
+	require.NoError(t, err)
+	assert.Equal(t, "user-42", claims.Subject)
+	assert.Equal(t, "alice@example.com", claims.Email)
+	assert.True(t, claims.EmailVerified)
+	assert.Equal(t, "Alice", claims.Name)
+
+	// Код одноразовый
+	_, err = p.Exchange(context.Background(), callback.Get("code"), verifier, nonce)
+	assert.ErrorIs(t, err, oidc.ErrExchangeFailed)
+}
+
+func TestExchangeRequiresCodeVerifier(t *testing.T) {
+	m := newMockProvider(t)
+	p := newProvider(m)
+
+	nonce := oidc.NewState()
+	callback := authorize(t, p, oidc.NewState(), nonce, oidc.NewCodeVerifier())
+
+	// Перехваченный код без исходного code_verifier бесполезен
+	_, err := p.Exchange(context.Background(), callback.Get("code"), oidc.NewCodeVerifier(), nonce)
+	assert.ErrorIs(t, err, oidc.ErrExchangeFailed)
+}
+
+func TestVerifyIDToken(t *testing.T) {
+	m := newMockProvider(t)
+	p := newProvider(m)
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	foreign := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss": m.URL, "aud": testClientID, "sub": "user-42", "nonce": "n",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	})
+	foreign.Header["kid"] = "mock-key"
+	foreignToken, err := foreign.SignedString(otherKey)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name  string
+		token string
+		valid bool
+	}{
+		{"Valid", m.idToken(jwt.MapClaims{"nonce": "n"}), true},
+		{"Wrong Nonce", m.idToken(jwt.MapClaims{"nonce": "other"}), false},
+		{"Wrong Audience", m.idToken(jwt.MapClaims{"nonce": "n", "aud": "someone-else"}), false},
+		{"Wrong Issuer", m.idToken(jwt.MapClaims{"nonce": "n", "iss": "https://evil.example.com"}), false},
+		{"Expired", m.idToken(jwt.MapClaims{"nonce": "n", "exp": time.Now().Add(-time.Hour).Unix()}), false},
+		{"Empty Subject", m.idToken(jwt.MapClaims{"nonce": "n", "sub": ""}), false},
+		{"Foreign Signature", foreignToken, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := p.VerifyIDToken(context.Background(), tt.token, "n")
+			if tt.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, oidc.ErrInvalidIDToken)
+			}
+		})
+	}
+}
+
+func TestDiscoveryIssuerMismatch(t *testing.T) {
+	m := newMockProvider(t)
+	m.issuer = "https://evil.example.com"
+	p := newProvider(m)
+
+	_, err := p.AuthCodeURL(context.Background(), "state", "nonce", oidc.NewCodeVerifier())
+	assert.Error(t, err)
+}
@@ -0,0 +1,131 @@
+package totp_test
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"forum/backend/auth/internal/totp"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rfcSecret - ключ "12345678901234567890" из приложений RFC 4226 и RFC 6238 в base32
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// atStep возвращает момент начала шага step
+func atStep(step int64) time.Time {
+	return time.Unix(step*totp.Period, 0)
+}
+
+func TestCodeHOTPVectors(t *testing.T) {
+	// RFC 4226, приложение D: HOTP для счетчиков 0-9
+	want := []string{
+		"755224", "287082", "359152", "969429", "338314",
+		"254676", "287922", "162583", "399871", "520489",
+	}
+	for counter, code := range want {
+		got, err := totp.Code(rfcSecret, atStep(int64(counter)))
+		require.NoError(t, err)
+		assert.Equal(t, code, got, "counter %d", counter)
+	}
+}
+
+func TestCodeTOTPVectors(t *testing.T) {
+	// RFC 6238, приложение B, SHA-1: последние 6 из 8 цифр
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, tt := range tests {
+		got, err := totp.Code(rfcSecret, time.Unix(tt.unix, 0))
+		require.NoError(t, err)
+		assert.Equal(t, tt.code, got, "t=%d", tt.unix)
+	}
+}
+
+func TestValidateSkew(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	current := totp.Step(now)
+
+	for offset := int64(-totp.Skew); offset <= totp.Skew; offset++ {
+		code, err := totp.Code(rfcSecret, atStep(current+offset))
+		require.NoError(t, err)
+
+		step, ok := totp.Validate(rfcSecret, code, now)
+		assert.True(t, ok, "offset %d", offset)
+		assert.Equal(t, current+offset, step, "offset %d", offset)
+	}
+
+	for _, offset := range []int64{-totp.Skew - 1, totp.Skew + 1} {
+		code, err := totp.Code(rfcSecret, atStep(current+offset))
+		require.NoError(t, err)
+
+		_, ok := totp.Validate(rfcSecret, code, now)
+		assert.False(t, ok, "offset %d", offset)
+	}
+}
+
+// Защита от повтора сравнивает шаги: код, принятый в конце своего шага, в начале
+// следующего шага проверяется с тем же номером и отклоняется
+func TestValidateReturnsCodeStep(t *testing.T) {
+	now := time.Unix(1111111109, 0)
+	code, err := totp.Code(rfcSecret, now)
+	require.NoError(t, err)
+
+	first, ok := totp.Validate(rfcSecret, code, now)
+	require.True(t, ok)
+	again, ok := totp.Validate(rfcSecret, code, now.Add(totp.Period*time.Second))
+	require.True(t, ok)
+	assert.Equal(t, first, again)
+	assert.Equal(t, totp.Step(now), first)
+}
+
+func TestValidateRejectsMalformed(t *testing.T) {
+	now := time.Unix(59, 0)
+	for _, code := range []string{"", "28708", "2870820", "abcdef"} {
+		_, ok := totp.Validate(rfcSecret, code, now)
+		assert.False(t, ok, code)
+	}
+
+	// Пробелы, которые добавляют приложения при показе кода, допускаются
+	_, ok := totp.Validate(rfcSecret, "287 082", now)
+	assert.True(t, ok)
+
+	_, ok = totp.Validate("not base32!", "287082", now)
+	assert.False(t, ok)
+}
+
+func TestGenerateSecret(t *testing.T) {
+	a, err := totp.GenerateSecret()
+	require.NoError(t, err)
+	b, err := totp.GenerateSecret()
+	require.NoError(t, err)
+
+	assert.Len(t, a, 32)
+	assert.NotEqual(t, a, b)
+	_, err = totp.Code(a, time.Now())
+	assert.NoError(t, err)
+}
+
+func TestURI(t *testing.T) {
+	u, err := url.Parse(totp.URI("My Forum", "alice@example.com", rfcSecret))
+	require.NoError(t, err)
+
+	assert.Equal(t, "otpauth", u.Scheme)
+	assert.Equal(t, "totp", u.Host)
+	assert.Equal(t, "/My Forum:alice@example.com", u.Path)
+	q := u.Query()
+	assert.Equal(t, rfcSecret, q.Get("secret"))
+	assert.Equal(t, "My Forum", q.Get("issuer"))
+	assert.Equal(t, "6", q.Get("digits"))
+	assert.Equal(t, "30", q.Get("period"))
+}
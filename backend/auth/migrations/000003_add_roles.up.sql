@@ -0,0 +1,28 @@
+CREATE TABLE roles (
+    name VARCHAR(32) PRIMARY KEY
+);
+
+INSERT INTO roles (name) VALUES ('user'), ('moderator'), ('admin');
+
+CREATE TABLE role_permissions (
+    role VARCHAR(32) NOT NULL REFERENCES roles(name) ON DELETE CASCADE,
+    permission VARCHAR(64) NOT NULL,
+    PRIMARY KEY (role, permission)
+);
+
+INSERT INTO role_permissions (role, permission) VALUES
+    ('moderator', 'users:list'),
+    ('moderator', 'topics:moderate'),
+    ('moderator', 'comments:moderate'),
+    ('admin', 'users:list'),
+    ('admin', 'users:create'),
+    ('admin', 'users:update'),
+    ('admin', 'users:delete'),
+    ('admin', 'users:manage_roles'),
+    ('admin', 'topics:moderate'),
+    ('admin', 'comments:moderate'),
+    ('admin', 'retention:manage');
+
+ALTER TABLE users ADD COLUMN role VARCHAR(32) NOT NULL DEFAULT 'user' REFERENCES roles(name);
+
+UPDATE users SET role = 'admin' WHERE is_admin;
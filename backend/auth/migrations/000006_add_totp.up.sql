@@ -0,0 +1,16 @@
+-- Секрет TOTP сохраняется при начале подключения 2FA, а totp_enabled_at - после
+-- подтверждения первым кодом
+ALTER TABLE users ADD COLUMN totp_secret VARCHAR(64);
+ALTER TABLE users ADD COLUMN totp_enabled_at TIMESTAMP WITH TIME ZONE;
+-- Последний принятый шаг TOTP: один код нельзя использовать дважды
+ALTER TABLE users ADD COLUMN totp_last_step BIGINT;
+
+-- Одноразовые коды восстановления, хранятся в виде SHA-256 хеша
+CREATE TABLE recovery_codes (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    code_hash CHAR(64) NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_id, code_hash)
+);
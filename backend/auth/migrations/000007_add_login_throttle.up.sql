@@ -0,0 +1,24 @@
+-- Счетчики неудачных попыток входа по имени пользователя (kind = 'user') и по IP (kind = 'ip')
+CREATE TABLE login_throttle (
+    kind VARCHAR(10) NOT NULL,
+    key VARCHAR(255) NOT NULL,
+    failures INTEGER NOT NULL DEFAULT 0,
+    last_failure_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    -- До этого момента вход блокируется без проверки пароля
+    blocked_until TIMESTAMP WITH TIME ZONE,
+    PRIMARY KEY (kind, key)
+);
+
+-- Журнал событий безопасности: неудачные входы, блокировки, разблокировки
+CREATE TABLE auth_audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    event VARCHAR(50) NOT NULL,
+    user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    username VARCHAR(255),
+    ip VARCHAR(64),
+    detail TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_auth_audit_log_username ON auth_audit_log(username, created_at);
+CREATE INDEX idx_auth_audit_log_ip ON auth_audit_log(ip, created_at);
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_users_anonymization_pending;
+DROP INDEX IF EXISTS idx_users_deletion_pending;
+DROP INDEX IF EXISTS idx_users_deleted_at;
+ALTER TABLE users DROP COLUMN IF EXISTS anonymization_notified_at;
+ALTER TABLE users DROP COLUMN IF EXISTS deletion_notified_at;
+ALTER TABLE users DROP COLUMN IF EXISTS anonymized_at;
+ALTER TABLE users DROP COLUMN IF EXISTS deleted_at;
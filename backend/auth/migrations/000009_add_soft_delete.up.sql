@@ -0,0 +1,14 @@
+-- Время удаления аккаунта. Пока не истек срок восстановления, аккаунт можно вернуть
+ALTER TABLE users ADD COLUMN deleted_at TIMESTAMP WITH TIME ZONE;
+-- Время обезличивания удаленного аккаунта после истечения срока восстановления
+ALTER TABLE users ADD COLUMN anonymized_at TIMESTAMP WITH TIME ZONE;
+-- Время, когда forum-сервис подтвердил удаление и обезличивание. Пока NULL, уведомление
+-- повторяется. deletion_notified_at сбрасывается при каждом удалении
+ALTER TABLE users ADD COLUMN deletion_notified_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE users ADD COLUMN anonymization_notified_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX idx_users_deleted_at ON users(deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX idx_users_deletion_pending ON users(id)
+    WHERE deleted_at IS NOT NULL AND anonymized_at IS NULL AND deletion_notified_at IS NULL;
+CREATE INDEX idx_users_anonymization_pending ON users(id)
+    WHERE anonymized_at IS NOT NULL AND anonymization_notified_at IS NULL;
@@ -0,0 +1,557 @@
+// Package config загружает типизированную конфигурацию сервисов из значений по умолчанию,
+// файла (YAML или TOML), переменных окружения и флагов командной строки.
+//
+// Источники применяются по возрастанию приоритета: значения по умолчанию, файл,
+// переменные окружения, флаги. Поля конфигурации описываются тегами:
+//
+//	config   - ключ в файле, вложенные структуры образуют секции
+//	env      - переменная окружения
+//	flag     - флаг командной строки
+//	default  - значение по умолчанию
+//	usage    - описание флага
+//	required - значение не может быть пустым
+//	secret   - значение скрывается в Redacted и Print
+//
+// Поддерживаются поля типов string, bool, целые числа, time.Duration и []string
+// (в переменных окружения и флагах - через запятую).
+//
+// Поле типа map[string]S, где S - структура, описывает именованные секции, например
+// OIDC провайдеры. В файле записи соответствует секция <ключ>.<имя>, в окружении -
+// переменные <env><ИМЯ>_<env поля S>, где env поля-карты задает общий префикс. Записи
+// создаются для всех имен, встреченных в файле или окружении. Флаги для них не регистрируются.
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// FileEnv - переменная окружения с путем к файлу конфигурации. Флаг -config ее переопределяет
+const FileEnv = "CONFIG_FILE"
+
+// redactedValue подставляется вместо непустых секретов
+const redactedValue = "******"
+
+// Validator реализуют конфигурации и секции с собственными проверками.
+// Validate вызывается после загрузки всех источников
+type Validator interface {
+	Validate() error
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// field - поле конфигурации, которому соответствует одно значение
+type field struct {
+	key   string
+	env   string
+	value reflect.Value
+	tag   reflect.StructTag
+}
+
+// name возвращает имя поля для сообщений об ошибках
+func (f field) name() string {
+	if f.env != "" {
+		return f.key + " (" + f.env + ")"
+	}
+	return f.key
+}
+
+// section - поле-карта именованных секций
+type section struct {
+	key   string
+	env   string
+	value reflect.Value
+}
+
+// entry - запись именованной секции. Заполняется отдельно и переносится в карту после загрузки
+type entry struct {
+	section section
+	name    string
+	value   reflect.Value
+}
+
+// schema - поля, секции и проверки конфигурации
+type schema struct {
+	fields     []field
+	validators []Validator
+	sections   []section
+	entries    []entry
+}
+
+// Load заполняет cfg, указатель на структуру, из всех источников и проверяет результат.
+// Флаги полей и флаг -config регистрируются в fs, после чего разбираются args
+func Load(cfg any, fs *flag.FlagSet, args []string) error {
+	s, err := collect(cfg)
+	if err != nil {
+		return err
+	}
+
+	file := fs.String("config", os.Getenv(FileEnv), "файл конфигурации (YAML или TOML)")
+	type flagValue struct {
+		field field
+		raw   string
+	}
+	var flagValues []flagValue
+	for _, f := range s.fields {
+		name := f.tag.Get("flag")
+		if name == "" {
+			continue
+		}
+		f := f
+		record := func(raw string) error {
+			// Значение проверяется сразу, чтобы ошибка относилась к флагу
+			if err := set(reflect.New(f.value.Type()).Elem(), raw); err != nil {
+				return err
+			}
+			flagValues = append(flagValues, flagValue{field: f, raw: raw})
+			return nil
+		}
+		usage := f.tag.Get("usage")
+		if f.value.Kind() == reflect.Bool {
+			fs.BoolFunc(name, usage, record)
+		} else {
+			fs.Func(name, usage, record)
+		}
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var values map[string]string
+	if *file != "" {
+		if values, err = readFile(*file); err != nil {
+			return err
+		}
+	}
+	// Записи секций создаются до применения источников, чтобы получить их значения по умолчанию
+	if err := s.addEntries(values); err != nil {
+		return err
+	}
+	if err := applyDefaults(s.fields); err != nil {
+		return err
+	}
+	if err := applyFile(*file, values, s.fields); err != nil {
+		return err
+	}
+	if err := applyEnv(s.fields); err != nil {
+		return err
+	}
+	for _, fv := range flagValues {
+		if err := set(fv.field.value, fv.raw); err != nil {
+			return fmt.Errorf("%s: %w", fv.field.name(), err)
+		}
+	}
+	return s.finish()
+}
+
+// LoadEnv заполняет cfg только значениями по умолчанию и переменными окружения
+func LoadEnv(cfg any) error {
+	s, err := collect(cfg)
+	if err != nil {
+		return err
+	}
+	if err := s.addEntries(nil); err != nil {
+		return err
+	}
+	if err := applyDefaults(s.fields); err != nil {
+		return err
+	}
+	if err := applyEnv(s.fields); err != nil {
+		return err
+	}
+	return s.finish()
+}
+
+// collect возвращает поля конфигурации, именованные секции и секции, реализующие Validator
+func collect(cfg any) (*schema, error) {
+	v := reflect.ValueOf(cfg)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("config: ожидается указатель на структуру, получен %T", cfg)
+	}
+
+	s := &schema{}
+	if err := s.walk(v.Elem(), "", "", true); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// walk добавляет поля структуры v. Ключи получают префикс prefix, переменные окружения -
+// envPrefix. Именованные секции допускаются только вне записей других секций
+func (s *schema) walk(v reflect.Value, prefix, envPrefix string, allowSections bool) error {
+	if val, ok := v.Addr().Interface().(Validator); ok {
+		s.validators = append(s.validators, val)
+	}
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		key := sf.Tag.Get("config")
+		if key == "-" {
+			continue
+		}
+		if key == "" {
+			key = strings.ToLower(sf.Name)
+		}
+		key = prefix + key
+
+		fv := v.Field(i)
+		env := sf.Tag.Get("env")
+		if env != "" {
+			env = envPrefix + env
+		}
+		switch {
+		case fv.Kind() == reflect.Struct:
+			if err := s.walk(fv, key+".", envPrefix, allowSections); err != nil {
+				return err
+			}
+		case isSection(fv.Type()):
+			if !allowSections {
+				return fmt.Errorf("config: вложенные именованные секции %s не поддерживаются", key)
+			}
+			s.sections = append(s.sections, section{key: key, env: env, value: fv})
+		case supported(fv.Type()):
+			s.fields = append(s.fields, field{key: key, env: env, value: fv, tag: sf.Tag})
+		default:
+			return fmt.Errorf("config: поле %s имеет неподдерживаемый тип %s", key, fv.Type())
+		}
+	}
+	return nil
+}
+
+func isSection(t reflect.Type) bool {
+	return t.Kind() == reflect.Map && t.Key().Kind() == reflect.String && t.Elem().Kind() == reflect.Struct
+}
+
+// addEntries создает записи секций для имен из файла и окружения.
+// Записи, уже присутствующие в карте, дополняются
+func (s *schema) addEntries(values map[string]string) error {
+	for _, sec := range s.sections {
+		names := map[string]bool{}
+		for key := range values {
+			if rest, ok := strings.CutPrefix(key, sec.key+"."); ok {
+				name, _, _ := strings.Cut(rest, ".")
+				names[name] = true
+			}
+		}
+		envNames, err := sectionEnvNames(sec)
+		if err != nil {
+			return err
+		}
+		for _, name := range envNames {
+			names[name] = true
+		}
+		for name := range names {
+			if err := s.addEntry(sec, name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *schema) addEntry(sec section, name string) error {
+	v := reflect.New(sec.value.Type().Elem()).Elem()
+	if existing := sec.value.MapIndex(reflect.ValueOf(name)); existing.IsValid() {
+		v.Set(existing)
+	}
+	envPrefix := ""
+	if sec.env != "" {
+		envPrefix = sec.env + strings.ToUpper(name) + "_"
+	}
+	if err := s.walk(v, sec.key+"."+name+".", envPrefix, false); err != nil {
+		return err
+	}
+	s.entries = append(s.entries, entry{section: sec, name: name, value: v})
+	return nil
+}
+
+// sectionEnvNames находит имена записей секции по непустым переменным вида
+// <env><ИМЯ>_<env поля>. Если окончание подходит к нескольким полям, берется самое длинное
+func sectionEnvNames(sec section) ([]string, error) {
+	if sec.env == "" {
+		return nil, nil
+	}
+	probe := &schema{}
+	if err := probe.walk(reflect.New(sec.value.Type().Elem()).Elem(), "", "", false); err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, kv := range os.Environ() {
+		key, value, _ := strings.Cut(kv, "=")
+		rest, ok := strings.CutPrefix(key, sec.env)
+		if !ok || value == "" {
+			continue
+		}
+		suffix := ""
+		for _, f := range probe.fields {
+			if f.env != "" && len(f.env) > len(suffix) && len(rest) > len(f.env)+1 &&
+				strings.HasSuffix(rest, "_"+f.env) {
+				suffix = f.env
+			}
+		}
+		if suffix != "" {
+			names = append(names, strings.ToLower(rest[:len(rest)-len(suffix)-1]))
+		}
+	}
+	return names, nil
+}
+
+// finish переносит записи секций в карты и проверяет результат
+func (s *schema) finish() error {
+	for _, e := range s.entries {
+		if e.section.value.IsNil() {
+			e.section.value.Set(reflect.MakeMap(e.section.value.Type()))
+		}
+		e.section.value.SetMapIndex(reflect.ValueOf(e.name), e.value)
+	}
+	return validate(s.fields, s.validators)
+}
+
+func supported(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return true
+	case reflect.Slice:
+		return t.Elem().Kind() == reflect.String
+	}
+	return false
+}
+
+// set разбирает строковое значение в соответствии с типом поля
+func set(v reflect.Value, raw string) error {
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("неверная длительность %q", raw)
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("неверное логическое значение %q", raw)
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("неверное целое число %q", raw)
+		}
+		v.SetInt(n)
+	case reflect.Slice:
+		v.Set(reflect.ValueOf(SplitList(raw)))
+	}
+	return nil
+}
+
+// SplitList разбирает список значений, разделенных запятыми
+func SplitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func applyDefaults(fields []field) error {
+	for _, f := range fields {
+		def, ok := f.tag.Lookup("default")
+		if !ok {
+			continue
+		}
+		if err := set(f.value, def); err != nil {
+			return fmt.Errorf("config: значение по умолчанию %s: %w", f.name(), err)
+		}
+	}
+	return nil
+}
+
+// applyEnv применяет переменные окружения. Пустая переменная считается не заданной
+func applyEnv(fields []field) error {
+	for _, f := range fields {
+		if f.env == "" {
+			continue
+		}
+		raw := os.Getenv(f.env)
+		if raw == "" {
+			continue
+		}
+		if err := set(f.value, raw); err != nil {
+			return fmt.Errorf("%s: %w", f.name(), err)
+		}
+	}
+	return nil
+}
+
+// readFile читает файл конфигурации в ключи вида database.host. Формат определяется по расширению
+func readFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось прочитать файл конфигурации: %w", err)
+	}
+
+	doc := map[string]any{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &doc)
+	case ".toml":
+		err = toml.Unmarshal(data, &doc)
+	default:
+		return nil, fmt.Errorf("неизвестный формат файла конфигурации %q, ожидается .yaml или .toml", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("не удалось разобрать файл конфигурации %s: %w", path, err)
+	}
+
+	values := map[string]string{}
+	flatten(doc, "", values)
+	return values, nil
+}
+
+// applyFile применяет значения из файла path.
+// Неизвестные ключи считаются ошибкой, чтобы опечатка не проходила незамеченной
+func applyFile(path string, values map[string]string, fields []field) error {
+	byKey := make(map[string]field, len(fields))
+	for _, f := range fields {
+		byKey[f.key] = f
+	}
+	var errs []error
+	for key, raw := range values {
+		f, ok := byKey[key]
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: неизвестный ключ %q", path, key))
+			continue
+		}
+		if err := set(f.value, raw); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s: %w", path, key, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// flatten превращает вложенные секции в ключи вида database.host
+func flatten(doc map[string]any, prefix string, out map[string]string) {
+	for key, value := range doc {
+		switch v := value.(type) {
+		case map[string]any:
+			flatten(v, prefix+key+".", out)
+		case []any:
+			items := make([]string, len(v))
+			for i, item := range v {
+				items[i] = fmt.Sprint(item)
+			}
+			out[prefix+key] = strings.Join(items, ",")
+		default:
+			out[prefix+key] = fmt.Sprint(v)
+		}
+	}
+}
+
+func validate(fields []field, validators []Validator) error {
+	var errs []error
+	for _, f := range fields {
+		if _, ok := f.tag.Lookup("required"); ok && f.value.IsZero() {
+			errs = append(errs, fmt.Errorf("%s: значение обязательно", f.name()))
+		}
+	}
+	for _, v := range validators {
+		if err := v.Validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Redacted возвращает действующую конфигурацию в виде вложенных секций,
+// непустые секреты заменяются на "******"
+func Redacted(cfg any) map[string]any {
+	s, err := collect(cfg)
+	if err != nil {
+		return nil
+	}
+	for _, sec := range s.sections {
+		for _, name := range sec.value.MapKeys() {
+			if err := s.addEntry(sec, name.String()); err != nil {
+				return nil
+			}
+		}
+	}
+
+	out := map[string]any{}
+	for _, f := range s.fields {
+		section := out
+		parts := strings.Split(f.key, ".")
+		for _, part := range parts[:len(parts)-1] {
+			next, ok := section[part].(map[string]any)
+			if !ok {
+				next = map[string]any{}
+				section[part] = next
+			}
+			section = next
+		}
+
+		var value any
+		switch {
+		case isSecret(f) && !f.value.IsZero():
+			value = redactedValue
+		case f.value.Type() == durationType:
+			value = time.Duration(f.value.Int()).String()
+		default:
+			value = f.value.Interface()
+		}
+		section[parts[len(parts)-1]] = value
+	}
+	return out
+}
+
+func isSecret(f field) bool {
+	_, ok := f.tag.Lookup("secret")
+	return ok
+}
+
+// Print выводит действующую конфигурацию в формате YAML со скрытыми секретами
+func Print(w io.Writer, cfg any) error {
+	data, err := yaml.Marshal(Redacted(cfg))
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// CheckAddr проверяет адрес вида host:port или :port. name попадает в текст ошибки
+func CheckAddr(name, addr string) error {
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("%s: неверный адрес %q", name, addr)
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 0 || n > 65535 {
+		return fmt.Errorf("%s: неверный порт в адресе %q", name, addr)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Database - параметры подключения к PostgreSQL, общие для всех сервисов
+type Database struct {
+	Host     string `config:"host" env:"DB_HOST" flag:"db-host" default:"localhost" usage:"хост PostgreSQL"`
+	Port     int    `config:"port" env:"DB_PORT" flag:"db-port" default:"5432" usage:"порт PostgreSQL"`
+	User     string `config:"user" env:"DB_USER" flag:"db-user" default:"postgres" usage:"пользователь PostgreSQL"`
+	Password string `config:"password" env:"DB_PASSWORD" flag:"db-password" secret:"true" usage:"пароль PostgreSQL"`
+	Name     string `config:"name" env:"DB_NAME" flag:"db-name" default:"forumDB" required:"true" usage:"имя базы данных"`
+	SSLMode  string `config:"sslmode" env:"DB_SSLMODE" flag:"db-sslmode" default:"disable" usage:"режим SSL: disable, require, verify-ca, verify-full"`
+}
+
+// Validate проверяет порт и режим SSL
+func (d *Database) Validate() error {
+	var errs []error
+	if d.Port < 1 || d.Port > 65535 {
+		errs = append(errs, fmt.Errorf("database.port (DB_PORT): неверный порт %d", d.Port))
+	}
+	switch d.SSLMode {
+	case "disable", "allow", "prefer", "require", "verify-ca", "verify-full":
+	default:
+		errs = append(errs, fmt.Errorf("database.sslmode (DB_SSLMODE): неизвестный режим %q", d.SSLMode))
+	}
+	return errors.Join(errs...)
+}
+
+// DSN возвращает строку подключения в формате key=value для lib/pq
+func (d Database) DSN() string {
+	params := []string{
+		"host=" + dsnValue(d.Host),
+		"port=" + strconv.Itoa(d.Port),
+		"user=" + dsnValue(d.User),
+		"dbname=" + dsnValue(d.Name),
+		"sslmode=" + dsnValue(d.SSLMode),
+	}
+	if d.Password != "" {
+		params = append(params, "password="+dsnValue(d.Password))
+	}
+	return strings.Join(params, " ")
+}
+
+// dsnValue экранирует значение, содержащее пробелы, кавычки или обратную косую черту
+func dsnValue(s string) string {
+	if s != "" && !strings.ContainsAny(s, ` '\`) {
+		return s
+	}
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
+
+// URL возвращает строку подключения в формате postgres://, например для golang-migrate
+func (d Database) URL() string {
+	u := url.URL{
+		Scheme:   "postgres",
+		Host:     net.JoinHostPort(d.Host, strconv.Itoa(d.Port)),
+		Path:     "/" + d.Name,
+		RawQuery: url.Values{"sslmode": {d.SSLMode}}.Encode(),
+	}
+	if d.Password != "" {
+		u.User = url.UserPassword(d.User, d.Password)
+	} else {
+		u.User = url.User(d.User)
+	}
+	return u.String()
+}
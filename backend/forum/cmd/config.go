@@ -0,0 +1,74 @@
+package main
+
+import (
+	"errors"
+	"forum/backend/config"
+	"forum/backend/tracing"
+	"time"
+)
+
+// Config - настройки forum-сервиса. Загружается через config.Load
+type Config struct {
+	HTTPAddr string `config:"http_addr" env:"HTTP_ADDR" flag:"http-addr" default:":8080" usage:"адрес HTTP сервера"`
+	GRPCAddr string `config:"grpc_addr" env:"GRPC_ADDR" flag:"grpc-addr" default:":50052" usage:"адрес gRPC сервера"`
+	// AuthGRPCAddr - адрес gRPC сервера auth-сервиса
+	AuthGRPCAddr string `config:"auth_grpc_addr" env:"AUTH_GRPC_ADDR" flag:"auth-grpc-addr" default:"localhost:50051" usage:"адрес gRPC auth-сервиса"`
+
+	// TrustedProxies - адреса и подсети обратных прокси, которым доверяются заголовки
+	// X-Forwarded-For и X-Real-IP. Если список пуст, IP клиента берется из соединения
+	TrustedProxies []string `config:"trusted_proxies" env:"TRUSTED_PROXIES" flag:"trusted-proxies" usage:"адреса и подсети доверенных прокси через запятую"`
+
+	// ShutdownTimeout - сколько ждать завершения текущих запросов при остановке
+	ShutdownTimeout time.Duration `config:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT" default:"15s"`
+
+	Database config.Database `config:"database"`
+
+	Health struct {
+		// MigrationsTable - таблица версий golang-migrate, по которой проверяется схема БД.
+		// Совпадает с таблицей, которую backend/migrate использует для сервиса forum. В существующей
+		// БД migrate при первом запуске переносит в нее версию из общей schema_migrations
+		MigrationsTable string        `config:"migrations_table" env:"MIGRATIONS_TABLE" default:"forum_schema_migrations"`
+		Timeout         time.Duration `config:"timeout" env:"HEALTH_TIMEOUT" default:"2s"`
+		// Interval - как часто обновляется статус grpc.health.v1
+		Interval time.Duration `config:"interval" env:"HEALTH_INTERVAL" default:"10s"`
+	} `config:"health"`
+
+	Tracing tracing.Config `config:"tracing"`
+
+	WebSocket struct {
+		// Broker=postgres включает рассылку между экземплярами через LISTEN/NOTIFY
+		Broker         string   `config:"broker" env:"WS_BROKER" default:"memory" usage:"брокер WebSocket: memory или postgres"`
+		AllowedOrigins []string `config:"allowed_origins" env:"WS_ALLOWED_ORIGINS"`
+	} `config:"websocket"`
+
+	Retention struct {
+		Interval time.Duration `config:"interval" env:"RETENTION_INTERVAL" default:"1h"`
+		// DryRun только логирует комментарии, которые были бы архивированы или удалены
+		DryRun bool `config:"dry_run" env:"RETENTION_DRY_RUN" flag:"retention-dry-run"`
+	} `config:"retention"`
+
+	// RequireVerifiedEmail запрещает создавать топики и комментарии без подтвержденного email
+	RequireVerifiedEmail bool `config:"require_verified_email" env:"REQUIRE_VERIFIED_EMAIL" flag:"require-verified-email"`
+}
+
+// Validate проверяет адреса, брокер и период хранения
+func (c *Config) Validate() error {
+	errs := []error{
+		config.CheckAddr("http_addr (HTTP_ADDR)", c.HTTPAddr),
+		config.CheckAddr("grpc_addr (GRPC_ADDR)", c.GRPCAddr),
+		config.CheckAddr("auth_grpc_addr (AUTH_GRPC_ADDR)", c.AuthGRPCAddr),
+	}
+	if c.WebSocket.Broker != "memory" && c.WebSocket.Broker != "postgres" {
+		errs = append(errs, errors.New("websocket.broker (WS_BROKER): ожидается memory или postgres"))
+	}
+	if c.Retention.Interval <= 0 {
+		errs = append(errs, errors.New("retention.interval (RETENTION_INTERVAL): должен быть больше нуля"))
+	}
+	if c.Health.Timeout <= 0 || c.Health.Interval <= 0 {
+		errs = append(errs, errors.New("health: timeout (HEALTH_TIMEOUT) и interval (HEALTH_INTERVAL) должны быть больше нуля"))
+	}
+	if c.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("shutdown_timeout (SHUTDOWN_TIMEOUT): должен быть больше нуля"))
+	}
+	return errors.Join(errs...)
+}
@@ -1,12 +1,28 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"forum/backend/config"
 	"forum/backend/forum/internal/db"
+	"forum/backend/forum/internal/external"
 	"forum/backend/forum/internal/grpc"
 	"forum/backend/forum/internal/logger"
+	"forum/backend/forum/internal/retention"
+	"forum/backend/forum/internal/websocket"
+	"forum/backend/health"
+	"forum/backend/lifecycle"
+	"forum/backend/metrics"
+	userpb "forum/backend/protos/go"
+	"forum/backend/tracing"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/gin-gonic/gin"
 	"github.com/rs/zerolog/log"
+	grpchealth "google.golang.org/grpc/health"
 )
 
 var router *gin.Engine
@@ -15,18 +31,85 @@ func main() {
 	logger.InitLogger()
 	log.Info().Msg("Starting forum service")
 
+	var cfg Config
+	printConfig := flag.Bool("print-config", false, "вывести действующую конфигурацию и выйти")
+	if err := config.Load(&cfg, flag.CommandLine, os.Args[1:]); err != nil {
+		log.Fatal().Err(err).Msg("Invalid configuration")
+	}
+	if *printConfig {
+		if err := config.Print(os.Stdout, &cfg); err != nil {
+			log.Fatal().Err(err).Msg("Failed to print configuration")
+		}
+		return
+	}
+	log.Info().Interface("config", config.Redacted(&cfg)).Msg("Configuration loaded")
+
+	shutdownTracing, err := tracing.Setup(context.Background(), "forum", cfg.Tracing)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to set up tracing")
+	}
+
 	log.Info().Msg("Setting up database connection")
-	db.SetupDB()
+	if err := db.Open(cfg.Database.DSN()); err != nil {
+		log.Fatal().Err(err).Msg("Failed to connect to database")
+	}
+	if err := metrics.RegisterDB(db.Db, cfg.Database.Name); err != nil {
+		log.Fatal().Err(err).Msg("Failed to register database metrics")
+	}
+
+	if err := external.ConnectAuthService(cfg.AuthGRPCAddr); err != nil {
+		log.Fatal().Err(err).Msg("Failed to create auth service client")
+	}
+
+	if cfg.WebSocket.Broker == "postgres" {
+		log.Info().Msg("Starting PostgreSQL WebSocket broker")
+		broker, err := websocket.NewPostgresBroker(cfg.Database.DSN(), db.Db)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to start WebSocket broker")
+		}
+		if err := websocket.SetBroker(broker); err != nil {
+			log.Fatal().Err(err).Msg("Failed to subscribe to WebSocket broker")
+		}
+	}
+
+	checker := health.New(cfg.Health.Timeout)
+	checker.Add("database", health.Database(db.Db))
+	checker.Add("migrations", health.Migrations(db.Db, cfg.Health.MigrationsTable, db.SchemaVersion))
+	checker.Add("auth_grpc", external.PingAuthService)
+	healthServer := grpchealth.NewServer()
 
 	log.Info().Msg("Initializing router")
 	router = gin.Default()
-	initializeRoutes()
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Fatal().Err(err).Msg("Invalid trusted proxies")
+	}
+	initializeRoutes(&cfg, checker)
 
-	log.Info().Msg("Starting gRPC server")
-	go grpc.StartGRPCServer()
+	runner := lifecycle.New(cfg.ShutdownTimeout)
+	worker := retention.NewWorker(cfg.Retention.Interval, cfg.Retention.DryRun)
+	runner.Add(lifecycle.Worker("retention", worker.Run))
+	runner.Add(lifecycle.GRPCServer("grpc", grpc.NewGRPCServer(healthServer), cfg.GRPCAddr))
+	// WebSocket соединения не отслеживаются HTTP сервером после upgrade,
+	// поэтому закрываются отдельно, сразу после остановки приема запросов
+	runner.Add(lifecycle.Component{Name: "websocket", Stop: websocket.Shutdown})
+	runner.Add(lifecycle.HTTPServer("http", &http.Server{Addr: cfg.HTTPAddr, Handler: router}))
+	// Статус gRPC зависит только от БД, чтобы недоступность auth-сервиса не выводила
+	// forum из балансировки. Останавливается первым и переводит сервер в NOT_SERVING
+	runner.Add(lifecycle.Worker("health", func(ctx context.Context) {
+		checker.WatchGRPC(ctx, healthServer, cfg.Health.Interval,
+			[]string{userpb.BackendService_ServiceDesc.ServiceName}, "database", "migrations")
+	}))
+	runner.OnShutdown("database", db.Db.Close)
+	runner.OnShutdown("tracing", func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+		defer cancel()
+		return shutdownTracing(ctx)
+	})
 
-	log.Info().Msg("Starting HTTP server on :8080")
-	if err := router.Run(":8080"); err != nil {
-		log.Fatal().Err(err).Msg("Failed to start HTTP server")
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := runner.Run(ctx); err != nil {
+		log.Fatal().Err(err).Msg("Forum service failed")
 	}
+	log.Info().Msg("Forum service stopped")
 }
@@ -4,35 +4,64 @@ import (
 	"forum/backend/forum/internal/handlers"
 	"forum/backend/forum/internal/middleware"
 	"forum/backend/forum/internal/websocket"
+	"forum/backend/health"
+	"forum/backend/metrics"
+	"forum/backend/tracing"
 	"log"
 
 	"github.com/gin-gonic/gin"
 )
 
-func initializeRoutes() {
+func initializeRoutes(cfg *Config, checker *health.Checker) {
 	log.Println("Initializing routes")
+	router.Use(tracing.HTTPMiddleware("forum"))
+	router.Use(metrics.HTTPMiddleware())
 	router.Use(middleware.CorsMiddleware())
 
+	router.GET("/healthz", health.Liveness)
+	router.GET("/readyz", checker.Readiness)
+	router.GET("/metrics", metrics.Handler())
+
 	// WebSocket endpoint
+	websocket.SetAllowedOrigins(cfg.WebSocket.AllowedOrigins)
 	router.GET("/ws", func(c *gin.Context) {
 		websocket.HandleConnections(c.Writer, c.Request)
 	})
 
+	router.GET("/search", handlers.Search)
+
+	authRequired := middleware.AuthMiddleware()
+	websocket.SetRequireVerifiedEmail(cfg.RequireVerifiedEmail)
+	verifiedEmail := middleware.RequireVerifiedEmail(cfg.RequireVerifiedEmail)
+
 	topicRoutes := router.Group("/topics")
 	{
 		topicRoutes.GET("", handlers.GetAllTopicsWithUsername)
 		topicRoutes.GET("/:topic_id", handlers.GetTopicWithData)
-		topicRoutes.POST("", handlers.PostNewTopic)
-		topicRoutes.DELETE("/:topic_id", handlers.DeleteTopic)
-		topicRoutes.PUT("/:topic_id", handlers.PutTopic)
+		topicRoutes.POST("", authRequired, verifiedEmail, handlers.PostNewTopic)
+		topicRoutes.DELETE("/:topic_id", authRequired, handlers.DeleteTopic)
+		topicRoutes.PUT("/:topic_id", authRequired, handlers.PutTopic)
+		topicRoutes.PUT("/:topic_id/retention", authRequired, handlers.PutRetentionPolicy)
+		topicRoutes.DELETE("/:topic_id/retention", authRequired, handlers.DeleteRetentionPolicy)
 	}
 
 	commentRoutes := router.Group("/comments")
 	{
 		commentRoutes.GET("", handlers.GetAllComments)
 		commentRoutes.GET("/:comment_id", handlers.GetComment)
-		commentRoutes.POST("", handlers.PostNewComment)
-		commentRoutes.DELETE("/:comment_id", handlers.DeleteComment)
-		commentRoutes.PUT("/:comment_id", handlers.PutComment)
+		commentRoutes.GET("/:comment_id/replies", handlers.GetCommentReplies)
+		commentRoutes.POST("/:comment_id/replies", authRequired, verifiedEmail, handlers.PostReply)
+		commentRoutes.POST("", authRequired, verifiedEmail, handlers.PostNewComment)
+		commentRoutes.DELETE("/:comment_id", authRequired, handlers.DeleteComment)
+		commentRoutes.PUT("/:comment_id", authRequired, handlers.PutComment)
+	}
+
+	// Глобальная политика хранения и ручной запуск, требуют права retention:manage
+	retentionRoutes := router.Group("/retention", authRequired)
+	{
+		retentionRoutes.GET("", handlers.GetRetentionPolicies)
+		retentionRoutes.PUT("", handlers.PutRetentionPolicy)
+		retentionRoutes.DELETE("", handlers.DeleteRetentionPolicy)
+		retentionRoutes.POST("/run", handlers.RunRetention)
 	}
 }
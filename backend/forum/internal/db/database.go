@@ -3,24 +3,36 @@ package db
 import (
 	"database/sql"
 	"fmt"
-	"os"
 
+	"github.com/HedgeHogSE/forum/backend/config"
+	"github.com/HedgeHogSE/forum/backend/tracing"
 	_ "github.com/lib/pq"
 )
 
 var Db *sql.DB
 
+// SchemaVersion - номер последней миграции forum-сервиса, с которой совместим код.
+// Обновляется вместе с добавлением миграции
+const SchemaVersion = 9
+
+// ConnString собирает строку подключения к БД из переменных окружения DB_*
+func ConnString() string {
+	var cfg config.Database
+	// Ошибки проверки не мешают собрать строку, их вернет подключение
+	config.LoadEnv(&cfg)
+	return cfg.DSN()
+}
+
+// SetupDB подключается к БД по переменным окружения DB_*
 func SetupDB() error {
+	return Open(ConnString())
+}
+
+// Open подключается к БД по строке подключения dsn и проверяет соединение.
+// Запросы с контекстом трассировки записываются как спаны
+func Open(dsn string) error {
 	var err error
-	connStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
-		os.Getenv("DB_HOST"),
-		os.Getenv("DB_PORT"),
-		os.Getenv("DB_USER"),
-		os.Getenv("DB_PASSWORD"),
-		os.Getenv("DB_NAME"),
-		os.Getenv("DB_SSLMODE"))
-
-	Db, err = sql.Open("postgres", connStr)
+	Db, err = tracing.OpenDB("postgres", dsn)
 	if err != nil {
 		return fmt.Errorf("ошибка подключения к БД: %w", err)
 	}
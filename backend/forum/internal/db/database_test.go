@@ -2,6 +2,9 @@ package db_test
 
 import (
 	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
 	"testing"
 
 	"github.com/HedgeHogSE/forum/backend/forum/internal/db"
@@ -110,3 +113,26 @@ func TestSetupDB_InvalidHost(t *testing.T) {
 		t.Error("SetupDB не вернул ошибку при неверном хосте")
 	}
 }
+
+func TestSchemaVersionMatchesMigrations(t *testing.T) {
+	files, err := filepath.Glob("../../migrations/*.up.sql")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("Не удалось найти миграции: %v", err)
+	}
+
+	re := regexp.MustCompile(`^(\d+)_`)
+	latest := 0
+	for _, file := range files {
+		m := re.FindStringSubmatch(filepath.Base(file))
+		if m == nil {
+			t.Fatalf("Неверное имя миграции: %s", file)
+		}
+		if v, _ := strconv.Atoi(m[1]); v > latest {
+			latest = v
+		}
+	}
+
+	if latest != db.SchemaVersion {
+		t.Errorf("db.SchemaVersion = %d, последняя миграция %d", db.SchemaVersion, latest)
+	}
+}
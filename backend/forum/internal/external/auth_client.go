@@ -2,32 +2,216 @@ package external
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
+	"sync"
+	"time"
 
+	"github.com/HedgeHogSE/forum/backend/health"
+	"github.com/HedgeHogSE/forum/backend/metrics"
 	userpb "github.com/HedgeHogSE/forum/backend/protos/go"
+	"github.com/HedgeHogSE/forum/backend/tracing"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
 )
 
+const (
+	defaultAuthServiceAddr = "localhost:50051"
+	authCallTimeout        = 3 * time.Second
+)
+
+var (
+	authConn   *grpc.ClientConn
+	authConnMu sync.Mutex
+)
+
+// ConnectAuthService устанавливает общее соединение с auth-сервисом по адресу addr.
+// Предыдущее соединение, если оно было, закрывается
+func ConnectAuthService(addr string) error {
+	conn, err := grpc.NewClient(addr, dialOptions()...)
+	if err != nil {
+		return err
+	}
+
+	authConnMu.Lock()
+	old := authConn
+	authConn = conn
+	authConnMu.Unlock()
+
+	if old != nil {
+		old.Close()
+	}
+	return nil
+}
+
+// dialOptions - параметры соединения с auth-сервисом
+func dialOptions() []grpc.DialOption {
+	return []grpc.DialOption{
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUnaryInterceptor(metrics.UnaryClientInterceptor()),
+		tracing.DialOption(),
+	}
+}
+
+// authConnection возвращает общее долгоживущее соединение с auth-сервисом.
+// Если соединение еще не установлено, оно создается с адресом по умолчанию
+func authConnection() (*grpc.ClientConn, error) {
+	authConnMu.Lock()
+	defer authConnMu.Unlock()
+
+	if authConn == nil {
+		conn, err := grpc.NewClient(defaultAuthServiceAddr, dialOptions()...)
+		if err != nil {
+			return nil, err
+		}
+		authConn = conn
+	}
+	return authConn, nil
+}
+
+// authClient возвращает клиент auth-сервиса поверх общего соединения
+func authClient() (userpb.AuthServiceClient, error) {
+	conn, err := authConnection()
+	if err != nil {
+		return nil, err
+	}
+	return userpb.NewAuthServiceClient(conn), nil
+}
+
+// PingAuthService проверяет, что gRPC сервер auth-сервиса доступен
+func PingAuthService(ctx context.Context) error {
+	conn, err := authConnection()
+	if err != nil {
+		return err
+	}
+	return health.PingGRPC(ctx, conn)
+}
+
 // GetUsernameByUserIDFunc - тип функции для получения имени пользователя
-type GetUsernameByUserIDFunc func(userID int) (string, error)
+type GetUsernameByUserIDFunc func(ctx context.Context, userID int) (string, error)
+
+// GetUsernamesByUserIDsFunc - тип функции для получения имен нескольких пользователей
+type GetUsernamesByUserIDsFunc func(ctx context.Context, userIDs []int) (map[int]string, error)
 
 // GetUsernameByUserID - функция для получения имени пользователя по ID
-var GetUsernameByUserID GetUsernameByUserIDFunc = func(userID int) (string, error) {
-	conn, err := grpc.Dial("localhost:50051", grpc.WithInsecure())
+var GetUsernameByUserID GetUsernameByUserIDFunc = func(ctx context.Context, userID int) (string, error) {
+	names, err := GetUsernamesByUserIDs(ctx, []int{userID})
 	if err != nil {
-		log.Printf("Failed to connect to auth service: %v", err)
 		return "", err
 	}
-	defer conn.Close()
 
-	client := userpb.NewAuthServiceClient(conn)
+	username, ok := names[userID]
+	if !ok {
+		return "", fmt.Errorf("user not found")
+	}
+	return username, nil
+}
+
+// GetUsernamesByUserIDs - функция для получения имен пользователей по списку ID.
+// Имена берутся из кеша, недостающие запрашиваются у auth-сервиса одним вызовом.
+// Пользователи, которых нет в auth-сервисе, в результат не попадают.
+// Вызов auth-сервиса продолжает трассировку из ctx
+var GetUsernamesByUserIDs GetUsernamesByUserIDsFunc = func(ctx context.Context, userIDs []int) (map[int]string, error) {
+	result := make(map[int]string, len(userIDs))
+	missing := make([]int32, 0)
+	seen := make(map[int]bool, len(userIDs))
+
+	for _, id := range userIDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		if username, ok := usernames.get(id); ok {
+			result[id] = username
+			continue
+		}
+		missing = append(missing, int32(id))
+	}
+
+	if len(missing) == 0 {
+		return result, nil
+	}
 
-	resp, err := client.GetUserName(context.Background(), &userpb.UserRequest{UserId: int32(userID)})
+	client, err := authClient()
 	if err != nil {
-		log.Printf("Error calling GetUserName: %v", err)
-		return "", err
+		log.Printf("Failed to connect to auth service: %v", err)
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, authCallTimeout)
+	defer cancel()
+
+	resp, err := client.GetUserNames(ctx, &userpb.UserNamesRequest{UserIds: missing})
+	if err != nil {
+		log.Printf("Error calling GetUserNames: %v", err)
+		return nil, err
+	}
+
+	for id, username := range resp.GetUserNames() {
+		usernames.set(int(id), username)
+		result[int(id)] = username
+	}
+	return result, nil
+}
+
+// ErrInvalidToken возвращается, если auth-сервис отклонил токен
+var ErrInvalidToken = errors.New("invalid token")
+
+// Identity - владелец проверенного токена
+type Identity struct {
+	UserID        int
+	Username      string
+	IsAdmin       bool
+	Role          string
+	Permissions   []string
+	EmailVerified bool
+}
+
+// HasPermission проверяет, что у роли пользователя есть право permission
+func (i *Identity) HasPermission(permission string) bool {
+	for _, p := range i.Permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateTokenFunc - тип функции для проверки JWT
+type ValidateTokenFunc func(ctx context.Context, token string) (*Identity, error)
+
+// ValidateToken - функция для проверки JWT через auth-сервис.
+// Для отклоненного токена возвращает ErrInvalidToken
+var ValidateToken ValidateTokenFunc = func(ctx context.Context, token string) (*Identity, error) {
+	client, err := authClient()
+	if err != nil {
+		log.Printf("Failed to connect to auth service: %v", err)
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, authCallTimeout)
+	defer cancel()
+
+	resp, err := client.ValidateToken(ctx, &userpb.ValidateTokenRequest{Token: token})
+	if err != nil {
+		if status.Code(err) == codes.Unauthenticated {
+			return nil, ErrInvalidToken
+		}
+		log.Printf("Error calling ValidateToken: %v", err)
+		return nil, err
 	}
 
-	return resp.GetUserName(), nil
+	return &Identity{
+		UserID:        int(resp.GetUserId()),
+		Username:      resp.GetUserName(),
+		IsAdmin:       resp.GetIsAdmin(),
+		Role:          resp.GetRole(),
+		Permissions:   resp.GetPermissions(),
+		EmailVerified: resp.GetEmailVerified(),
+	}, nil
 }
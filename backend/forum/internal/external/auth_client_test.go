@@ -4,7 +4,9 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/HedgeHogSE/forum/backend/forum/internal/external"
 	userpb "github.com/HedgeHogSE/forum/backend/protos/go"
@@ -18,6 +20,8 @@ type MockAuthServer struct {
 	userpb.UnimplementedAuthServiceServer
 	username string
 	err      error
+	// Количество вызовов GetUserNames
+	batchCalls atomic.Int32
 }
 
 func (m *MockAuthServer) GetUserName(ctx context.Context, req *userpb.UserRequest) (*userpb.UserResponse, error) {
@@ -27,6 +31,21 @@ func (m *MockAuthServer) GetUserName(ctx context.Context, req *userpb.UserReques
 	return &userpb.UserResponse{UserName: m.username}, nil
 }
 
+func (m *MockAuthServer) GetUserNames(ctx context.Context, req *userpb.UserNamesRequest) (*userpb.UserNamesResponse, error) {
+	m.batchCalls.Add(1)
+	if m.err != nil {
+		return nil, m.err
+	}
+	names := make(map[int32]string)
+	for _, id := range req.GetUserIds() {
+		// Пользователей с отрицательным ID "нет в базе"
+		if id > 0 {
+			names[id] = fmt.Sprintf("%s_%d", m.username, id)
+		}
+	}
+	return &userpb.UserNamesResponse{UserNames: names}, nil
+}
+
 func setupTestServer(t *testing.T, mockServer *MockAuthServer) (*grpc.Server, string, func()) {
 	// Создаем тестовый сервер на случайном порту
 	lis, err := net.Listen("tcp", ":0") // Используем порт 0 для получения случайного порта
@@ -68,7 +87,7 @@ func TestGetUsernameByUserID(t *testing.T) {
 	defer func() { external.GetUsernameByUserID = originalFunc }()
 
 	// Подменяем функцию на тестовую версию
-	external.GetUsernameByUserID = func(userID int) (string, error) {
+	external.GetUsernameByUserID = func(ctx context.Context, userID int) (string, error) {
 		// Создаем соединение с тестовым сервером
 		conn, err := grpc.Dial(addr, grpc.WithInsecure())
 		if err != nil {
@@ -88,7 +107,7 @@ func TestGetUsernameByUserID(t *testing.T) {
 	}
 
 	// Тестируем получение имени пользователя
-	username, err := external.GetUsernameByUserID(1)
+	username, err := external.GetUsernameByUserID(context.Background(), 1)
 	require.NoError(t, err)
 	assert.Equal(t, "testuser", username)
 }
@@ -108,7 +127,7 @@ func TestGetUsernameByUserID_Error(t *testing.T) {
 	defer func() { external.GetUsernameByUserID = originalFunc }()
 
 	// Подменяем функцию на тестовую версию
-	external.GetUsernameByUserID = func(userID int) (string, error) {
+	external.GetUsernameByUserID = func(ctx context.Context, userID int) (string, error) {
 		// Создаем соединение с тестовым сервером
 		conn, err := grpc.Dial(addr, grpc.WithInsecure())
 		if err != nil {
@@ -128,12 +147,104 @@ func TestGetUsernameByUserID_Error(t *testing.T) {
 	}
 
 	// Тестируем получение имени пользователя с ошибкой
-	_, err := external.GetUsernameByUserID(1)
+	_, err := external.GetUsernameByUserID(context.Background(), 1)
 	assert.Error(t, err)
 }
 
 func TestGetUsernameByUserID_ConnectionError(t *testing.T) {
 	// Тестируем ошибку подключения к серверу
-	_, err := external.GetUsernameByUserID(1)
+	_, err := external.GetUsernameByUserID(context.Background(), 1)
+	assert.Error(t, err)
+}
+
+func TestGetUsernamesByUserIDs(t *testing.T) {
+	mockServer := &MockAuthServer{username: "user"}
+	_, addr, cleanup := setupTestServer(t, mockServer)
+	defer cleanup()
+
+	require.NoError(t, external.ConnectAuthService(addr))
+	external.ResetUsernameCache()
+	defer external.ResetUsernameCache()
+
+	// Повторяющиеся ID запрашиваются одним вызовом, неизвестные пропускаются
+	names, err := external.GetUsernamesByUserIDs(context.Background(), []int{1, 2, 1, -1})
+	require.NoError(t, err)
+	assert.Equal(t, map[int]string{1: "user_1", 2: "user_2"}, names)
+	assert.Equal(t, int32(1), mockServer.batchCalls.Load())
+
+	// Повторный запрос обслуживается из кеша
+	names, err = external.GetUsernamesByUserIDs(context.Background(), []int{2, 1})
+	require.NoError(t, err)
+	assert.Len(t, names, 2)
+	assert.Equal(t, int32(1), mockServer.batchCalls.Load())
+
+	// После инвалидации имя запрашивается заново
+	external.InvalidateUsername(1)
+	username, err := external.GetUsernameByUserID(context.Background(), 1)
+	require.NoError(t, err)
+	assert.Equal(t, "user_1", username)
+	assert.Equal(t, int32(2), mockServer.batchCalls.Load())
+
+	// Неизвестный пользователь - ошибка для одиночного запроса
+	_, err = external.GetUsernameByUserID(context.Background(), -1)
+	assert.Error(t, err)
+}
+
+func TestGetUsernamesByUserIDs_CacheTTL(t *testing.T) {
+	mockServer := &MockAuthServer{username: "user"}
+	_, addr, cleanup := setupTestServer(t, mockServer)
+	defer cleanup()
+
+	require.NoError(t, external.ConnectAuthService(addr))
+	external.ResetUsernameCache()
+	defer external.ResetUsernameCache()
+
+	originalTTL := external.UsernameCacheTTL
+	defer func() { external.UsernameCacheTTL = originalTTL }()
+	external.UsernameCacheTTL = time.Nanosecond
+
+	_, err := external.GetUsernamesByUserIDs(context.Background(), []int{1})
+	require.NoError(t, err)
+	time.Sleep(time.Millisecond)
+	_, err = external.GetUsernamesByUserIDs(context.Background(), []int{1})
+	require.NoError(t, err)
+
+	// Просроченная запись не используется
+	assert.Equal(t, int32(2), mockServer.batchCalls.Load())
+}
+
+func TestMarkUserDeleted(t *testing.T) {
+	mockServer := &MockAuthServer{username: "user"}
+	_, addr, cleanup := setupTestServer(t, mockServer)
+	defer cleanup()
+
+	require.NoError(t, external.ConnectAuthService(addr))
+	external.ResetUsernameCache()
+	defer external.ResetUsernameCache()
+
+	originalTTL := external.UsernameCacheTTL
+	defer func() { external.UsernameCacheTTL = originalTTL }()
+	external.UsernameCacheTTL = time.Nanosecond
+
+	external.MarkUserDeleted(1, false)
+	external.MarkUserDeleted(2, true)
+	time.Sleep(time.Millisecond)
+
+	// Пометка удаленного пользователя истекает, обезличенного - нет
+	names, err := external.GetUsernamesByUserIDs(context.Background(), []int{1, 2})
+	require.NoError(t, err)
+	assert.Equal(t, map[int]string{1: "user_1", 2: external.DeletedUsername}, names)
+	assert.Equal(t, int32(1), mockServer.batchCalls.Load())
+}
+
+func TestGetUsernamesByUserIDs_Error(t *testing.T) {
+	mockServer := &MockAuthServer{err: assert.AnError}
+	_, addr, cleanup := setupTestServer(t, mockServer)
+	defer cleanup()
+
+	require.NoError(t, external.ConnectAuthService(addr))
+	external.ResetUsernameCache()
+
+	_, err := external.GetUsernamesByUserIDs(context.Background(), []int{1})
 	assert.Error(t, err)
 }
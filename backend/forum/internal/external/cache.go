@@ -0,0 +1,94 @@
+package external
+
+import (
+	"sync"
+	"time"
+)
+
+// UsernameCacheTTL - время жизни имени пользователя в кеше
+var UsernameCacheTTL = 5 * time.Minute
+
+type cachedUsername struct {
+	username  string
+	expiresAt time.Time
+	// permanent - запись не истекает, например имя обезличенного пользователя
+	permanent bool
+}
+
+// usernameCache - потокобезопасный кеш имен пользователей с ограниченным временем жизни
+type usernameCache struct {
+	mu      sync.RWMutex
+	entries map[int]cachedUsername
+	now     func() time.Time
+}
+
+var usernames = newUsernameCache()
+
+func newUsernameCache() *usernameCache {
+	return &usernameCache{
+		entries: make(map[int]cachedUsername),
+		now:     time.Now,
+	}
+}
+
+func (c *usernameCache) get(userID int) (string, bool) {
+	c.mu.RLock()
+	entry, ok := c.entries[userID]
+	c.mu.RUnlock()
+
+	if !ok || (!entry.permanent && !c.now().Before(entry.expiresAt)) {
+		return "", false
+	}
+	return entry.username, true
+}
+
+func (c *usernameCache) set(userID int, username string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.entries[userID].permanent {
+		return
+	}
+	c.entries[userID] = cachedUsername{
+		username:  username,
+		expiresAt: c.now().Add(UsernameCacheTTL),
+	}
+}
+
+func (c *usernameCache) setPermanent(userID int, username string) {
+	c.mu.Lock()
+	c.entries[userID] = cachedUsername{username: username, permanent: true}
+	c.mu.Unlock()
+}
+
+func (c *usernameCache) invalidate(userID int) {
+	c.mu.Lock()
+	delete(c.entries, userID)
+	c.mu.Unlock()
+}
+
+// InvalidateUsername удаляет имя пользователя из кеша, например после переименования
+func InvalidateUsername(userID int) {
+	usernames.invalidate(userID)
+}
+
+// DeletedUsername показывается вместо имени удаленного пользователя
+const DeletedUsername = "[deleted user]"
+
+// MarkUserDeleted подменяет имя удаленного пользователя на DeletedUsername, не дожидаясь
+// истечения кеша. Удаленного пользователя можно восстановить, поэтому запись истекает как
+// обычно. Обезличенного (anonymized) восстановить нельзя, и его запись не истекает
+func MarkUserDeleted(userID int, anonymized bool) {
+	if anonymized {
+		usernames.setPermanent(userID, DeletedUsername)
+		return
+	}
+	usernames.set(userID, DeletedUsername)
+}
+
+// ResetUsernameCache полностью очищает кеш имен пользователей
+func ResetUsernameCache() {
+	usernames.mu.Lock()
+	usernames.entries = make(map[int]cachedUsername)
+	usernames.mu.Unlock()
+}
@@ -2,19 +2,22 @@ package grpc
 
 import (
 	"context"
-	"log"
-	"net"
 	"time"
 
 	"github.com/HedgeHogSE/forum/backend/forum/internal/models"
+	"github.com/HedgeHogSE/forum/backend/forum/internal/websocket"
+	"github.com/HedgeHogSE/forum/backend/metrics"
 	userpb "github.com/HedgeHogSE/forum/backend/protos/go"
+	"github.com/HedgeHogSE/forum/backend/tracing"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 )
 
 // CommentService определяет интерфейс для работы с комментариями
 type CommentService interface {
-	GetCommentsByAuthorID(authorID int) ([]models.Comment, error)
+	GetCommentsByAuthorID(ctx context.Context, authorID int) ([]models.Comment, error)
 }
 
 type BackendServer struct {
@@ -30,7 +33,7 @@ func NewBackendServer(commentService CommentService) *BackendServer {
 }
 
 func (s *BackendServer) GetUserComments(ctx context.Context, req *userpb.UserCommentsRequest) (*userpb.UserCommentsResponse, error) {
-	comments, err := s.commentService.GetCommentsByAuthorID(int(req.UserId))
+	comments, err := s.commentService.GetCommentsByAuthorID(ctx, int(req.UserId))
 	if err != nil {
 		return nil, err
 	}
@@ -50,17 +53,29 @@ func (s *BackendServer) GetUserComments(ctx context.Context, req *userpb.UserCom
 	}, nil
 }
 
-func StartGRPCServer() {
-	lis, err := net.Listen("tcp", ":50052")
-	if err != nil {
-		log.Fatalf("failed to listen: %v", err)
-	}
+// InvalidateUserName сбрасывает закешированное имя пользователя после переименования в auth-сервисе.
+// auth-сервис вызывает один экземпляр, остальные получают команду через брокер WebSocket
+func (s *BackendServer) InvalidateUserName(ctx context.Context, req *userpb.UserRequest) (*userpb.InvalidateUserNameResponse, error) {
+	websocket.NotifyUsernameChanged(int(req.GetUserId()))
+	return &userpb.InvalidateUserNameResponse{}, nil
+}
 
-	s := grpc.NewServer()
-	userpb.RegisterBackendServiceServer(s, NewBackendServer(models.NewCommentService()))
+// UserDeleted скрывает имя удаленного пользователя в его темах и комментариях
+// и закрывает его WebSocket подключения на всех экземплярах. Имя обезличенного
+// пользователя скрывается навсегда
+func (s *BackendServer) UserDeleted(ctx context.Context, req *userpb.UserDeletedRequest) (*userpb.UserDeletedResponse, error) {
+	websocket.NotifyUserDeleted(int(req.GetUserId()), req.GetAnonymized())
+	return &userpb.UserDeletedResponse{}, nil
+}
 
-	log.Printf("Backend gRPC server listening at %v", lis.Addr())
-	if err := s.Serve(lis); err != nil {
-		log.Fatalf("failed to serve: %v", err)
-	}
+// NewGRPCServer создает gRPC сервер forum-сервиса. Статус grpc.health.v1
+// берется из healthServer
+func NewGRPCServer(healthServer *health.Server) *grpc.Server {
+	s := grpc.NewServer(
+		grpc.UnaryInterceptor(metrics.UnaryServerInterceptor()),
+		tracing.ServerOption(),
+	)
+	userpb.RegisterBackendServiceServer(s, NewBackendServer(models.NewCommentService()))
+	healthpb.RegisterHealthServer(s, healthServer)
+	return s
 }
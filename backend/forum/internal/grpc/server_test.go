@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/HedgeHogSE/forum/backend/forum/internal/db"
+	"github.com/HedgeHogSE/forum/backend/forum/internal/external"
 	grpcserver "github.com/HedgeHogSE/forum/backend/forum/internal/grpc"
 	"github.com/HedgeHogSE/forum/backend/forum/internal/models"
 	userpb "github.com/HedgeHogSE/forum/backend/protos/go"
@@ -41,7 +42,7 @@ type MockCommentService struct {
 	err      error
 }
 
-func (m *MockCommentService) GetCommentsByAuthorID(authorID int) ([]models.Comment, error) {
+func (m *MockCommentService) GetCommentsByAuthorID(ctx context.Context, authorID int) ([]models.Comment, error) {
 	if m.err != nil {
 		return nil, m.err
 	}
@@ -186,3 +187,31 @@ func TestGetUserComments_InvalidUser(t *testing.T) {
 	// Проверяем результаты
 	assert.Empty(t, resp.Comments)
 }
+
+func TestInvalidateUserName(t *testing.T) {
+	_, client, cleanup := setupTestServer(t, &MockCommentService{})
+	defer cleanup()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	_, err := (*client).InvalidateUserName(ctx, &userpb.UserRequest{UserId: 1})
+	assert.NoError(t, err)
+}
+
+func TestUserDeleted(t *testing.T) {
+	_, client, cleanup := setupTestServer(t, &MockCommentService{})
+	defer cleanup()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	_, err := (*client).UserDeleted(ctx, &userpb.UserDeletedRequest{UserId: 1})
+	require.NoError(t, err)
+
+	// Имя берется из кеша без обращения к auth-сервису
+	username, err := external.GetUsernameByUserID(context.Background(), 1)
+	require.NoError(t, err)
+	assert.Equal(t, external.DeletedUsername, username)
+	external.ResetUsernameCache()
+}
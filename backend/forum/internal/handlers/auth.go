@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+// currentUserID возвращает ID пользователя, установленный AuthMiddleware
+func currentUserID(c *gin.Context) int {
+	return c.GetInt("user_id")
+}
+
+// Права, которые auth-сервис выдает ролям moderator и admin
+const (
+	permTopicsModerate   = "topics:moderate"
+	permCommentsModerate = "comments:moderate"
+	permRetentionManage  = "retention:manage"
+)
+
+// hasPermission проверяет право текущего пользователя, установленное AuthMiddleware
+func hasPermission(c *gin.Context, permission string) bool {
+	for _, p := range c.GetStringSlice("permissions") {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// canModify проверяет, что текущий пользователь - автор записи или у него есть право модерации
+func canModify(c *gin.Context, authorID int, moderatePermission string) bool {
+	return currentUserID(c) == authorID || hasPermission(c, moderatePermission)
+}
@@ -1,8 +1,11 @@
 package handlers
 
 import (
+	"errors"
 	"github.com/HedgeHogSE/forum/backend/forum/internal/logger"
+	"github.com/HedgeHogSE/forum/backend/forum/internal/metrics"
 	"github.com/HedgeHogSE/forum/backend/forum/internal/models"
+	"github.com/HedgeHogSE/forum/backend/forum/internal/websocket"
 	"net/http"
 	"strconv"
 
@@ -17,10 +20,41 @@ func init() {
 }
 
 func GetAllComments(c *gin.Context) {
-	log.Info().Msg("Getting all comments")
-	comments := models.GetAllComments()
-	log.Info().Int("comments_count", len(comments)).Msg("Successfully retrieved all comments")
-	c.JSON(http.StatusOK, comments)
+	limit, cursor, err := parsePageParams(c)
+	if err != nil {
+		log.Error().
+			Err(err).
+			Msg("Invalid pagination parameters")
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	log.Info().Int("limit", limit).Msg("Getting comments page")
+	comments, err := models.ListComments(c.Request.Context(), cursor, limit+1)
+	if err != nil {
+		log.Error().
+			Err(err).
+			Msg("Failed to get comments page")
+		if errors.Is(err, models.ErrInvalidCursor) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cursor"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get comments"})
+		return
+	}
+
+	hasMore := len(comments) > limit
+	if hasMore {
+		comments = comments[:limit]
+	}
+
+	var next *string
+	if len(comments) > 0 {
+		next = nextCursor(hasMore, models.Cursor{ID: comments[len(comments)-1].ID})
+	}
+
+	log.Info().Int("comments_count", len(comments)).Msg("Successfully retrieved comments page")
+	c.JSON(http.StatusOK, Page{Items: comments, NextCursor: next})
 }
 
 func GetComment(c *gin.Context) {
@@ -35,7 +69,7 @@ func GetComment(c *gin.Context) {
 	}
 
 	log.Info().Int("comment_id", commentID).Msg("Getting comment")
-	comment, err := models.GetCommentByID(commentID)
+	comment, err := models.GetCommentByID(c.Request.Context(), commentID)
 	if err != nil {
 		log.Error().
 			Err(err).
@@ -60,23 +94,147 @@ func PostNewComment(c *gin.Context) {
 		return
 	}
 
+	authorID := currentUserID(c)
 	log.Info().
 		Int("topic_id", newComment.TopicId).
-		Int("author_id", newComment.AuthorId).
+		Int("author_id", authorID).
 		Msg("Creating new comment")
 
 	comment := &models.Comment{
 		Content:  newComment.Content,
-		AuthorId: newComment.AuthorId,
+		AuthorId: authorID,
 		TopicId:  newComment.TopicId,
+		ParentId: newComment.ParentId,
+	}
+
+	id, err := models.AddComment(c.Request.Context(), comment)
+	if err != nil {
+		log.Error().
+			Err(err).
+			Int("topic_id", comment.TopicId).
+			Msg("Failed to create comment")
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
+	comment.ID = id
+	metrics.CommentsCreated.WithLabelValues("http").Inc()
 
-	models.AddComment(comment)
 	log.Info().
 		Int("comment_id", comment.ID).
 		Int("topic_id", comment.TopicId).
 		Msg("Successfully created new comment")
-	c.JSON(http.StatusCreated, newComment)
+	websocket.NotifyCommentCreated(c.Request.Context(), comment.ID)
+	c.JSON(http.StatusCreated, comment)
+}
+
+func GetCommentReplies(c *gin.Context) {
+	commentID, err := strconv.Atoi(c.Param("comment_id"))
+	if err != nil {
+		log.Error().
+			Err(err).
+			Str("comment_id", c.Param("comment_id")).
+			Msg("Invalid comment ID format")
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	log.Info().Int("comment_id", commentID).Msg("Getting comment replies")
+	if _, err := models.GetCommentByID(c.Request.Context(), commentID); err != nil {
+		log.Error().
+			Err(err).
+			Int("comment_id", commentID).
+			Msg("Failed to get comment")
+		c.AbortWithError(http.StatusNotFound, err)
+		return
+	}
+
+	replies, err := models.GetRepliesByCommentID(c.Request.Context(), commentID)
+	if err != nil {
+		log.Error().
+			Err(err).
+			Int("comment_id", commentID).
+			Msg("Failed to get comment replies")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get replies"})
+		return
+	}
+
+	log.Info().
+		Int("comment_id", commentID).
+		Int("replies_count", len(replies)).
+		Msg("Successfully retrieved comment replies")
+	if c.Query("view") == "tree" {
+		c.JSON(http.StatusOK, models.BuildCommentTree(replies))
+		return
+	}
+	c.JSON(http.StatusOK, replies)
+}
+
+func PostReply(c *gin.Context) {
+	type CreateReplyInput struct {
+		Content string `json:"content"`
+	}
+
+	parentID, err := strconv.Atoi(c.Param("comment_id"))
+	if err != nil {
+		log.Error().
+			Err(err).
+			Str("comment_id", c.Param("comment_id")).
+			Msg("Invalid comment ID format")
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	var input CreateReplyInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		log.Error().
+			Err(err).
+			Interface("input", input).
+			Msg("Invalid reply creation input")
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	parent, err := models.GetCommentByID(c.Request.Context(), parentID)
+	if err != nil {
+		log.Error().
+			Err(err).
+			Int("comment_id", parentID).
+			Msg("Failed to get parent comment")
+		c.AbortWithError(http.StatusNotFound, err)
+		return
+	}
+
+	authorID := currentUserID(c)
+	log.Info().
+		Int("parent_id", parentID).
+		Int("author_id", authorID).
+		Msg("Creating reply")
+
+	reply := &models.Comment{
+		Content:  input.Content,
+		AuthorId: authorID,
+		TopicId:  parent.TopicId,
+		ParentId: &parent.ID,
+	}
+
+	id, err := models.AddComment(c.Request.Context(), reply)
+	if err != nil {
+		log.Error().
+			Err(err).
+			Int("parent_id", parentID).
+			Msg("Failed to create reply")
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	reply.ID = id
+	metrics.CommentsCreated.WithLabelValues("http").Inc()
+
+	log.Info().
+		Int("comment_id", reply.ID).
+		Int("parent_id", parentID).
+		Msg("Successfully created reply")
+	websocket.NotifyCommentCreated(c.Request.Context(), reply.ID)
+	c.JSON(http.StatusCreated, reply)
 }
 
 func DeleteComment(c *gin.Context) {
@@ -90,9 +248,40 @@ func DeleteComment(c *gin.Context) {
 		return
 	}
 
+	comment, err := models.GetCommentByID(c.Request.Context(), commentID)
+	if err != nil {
+		log.Error().
+			Err(err).
+			Int("comment_id", commentID).
+			Msg("Failed to get comment")
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	if !canModify(c, comment.AuthorId, permCommentsModerate) {
+		log.Warn().
+			Int("comment_id", commentID).
+			Int("user_id", currentUserID(c)).
+			Msg("Forbidden comment deletion")
+		c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+		return
+	}
+
 	log.Info().Int("comment_id", commentID).Msg("Deleting comment")
-	models.DeleteCommentByID(commentID)
+	replyIDs, err := models.DeleteCommentByID(c.Request.Context(), commentID)
+	if err != nil {
+		log.Error().
+			Err(err).
+			Int("comment_id", commentID).
+			Msg("Failed to delete comment")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete comment"})
+		return
+	}
 	log.Info().Int("comment_id", commentID).Msg("Successfully deleted comment")
+	websocket.NotifyCommentDeleted(comment.TopicId, commentID)
+	// Ответы на удаленный комментарий стали корневыми
+	for _, replyID := range replyIDs {
+		websocket.NotifyCommentUpdated(c.Request.Context(), replyID)
+	}
 	c.JSON(http.StatusNoContent, gin.H{
 		"message": "Comment deleted",
 	})
@@ -119,12 +308,34 @@ func PutComment(c *gin.Context) {
 		return
 	}
 
+	existing, err := models.GetCommentByID(c.Request.Context(), id)
+	if err != nil {
+		log.Error().
+			Err(err).
+			Int("comment_id", id).
+			Msg("Failed to get comment")
+		c.JSON(http.StatusNotFound, gin.H{"error": "comment not found"})
+		return
+	}
+	if !canModify(c, existing.AuthorId, permCommentsModerate) {
+		log.Warn().
+			Int("comment_id", id).
+			Int("user_id", currentUserID(c)).
+			Msg("Forbidden comment update")
+		c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+		return
+	}
+
+	// Автор и топик комментария при редактировании не меняются
+	newComment.AuthorId = existing.AuthorId
+	newComment.TopicId = existing.TopicId
+
 	log.Info().
 		Int("comment_id", id).
 		Int("topic_id", newComment.TopicId).
 		Msg("Updating comment")
 
-	updated, err := models.PutComment(id, newComment)
+	updated, err := models.PutComment(c.Request.Context(), id, newComment)
 	if err != nil {
 		log.Error().
 			Err(err).
@@ -137,5 +348,6 @@ func PutComment(c *gin.Context) {
 	log.Info().
 		Int("comment_id", id).
 		Msg("Successfully updated comment")
+	websocket.NotifyCommentUpdated(c.Request.Context(), updated.ID)
 	c.JSON(http.StatusOK, updated)
 }
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/HedgeHogSE/forum/backend/forum/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultPageLimit = 20
+	maxPageLimit     = 100
+)
+
+// Page - конверт для постраничной выдачи
+type Page struct {
+	Items      interface{} `json:"items"`
+	NextCursor *string     `json:"next_cursor"`
+}
+
+// parsePageParams разбирает параметры limit и cursor из запроса
+func parsePageParams(c *gin.Context) (int, *models.Cursor, error) {
+	limit := defaultPageLimit
+	if s := c.Query("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			return 0, nil, fmt.Errorf("invalid limit")
+		}
+		limit = min(n, maxPageLimit)
+	}
+
+	var cursor *models.Cursor
+	if s := c.Query("cursor"); s != "" {
+		var err error
+		cursor, err = models.DecodeCursor(s)
+		if err != nil {
+			return 0, nil, fmt.Errorf("invalid cursor")
+		}
+	}
+	return limit, cursor, nil
+}
+
+// nextCursor возвращает курсор следующей страницы или nil, если страница последняя
+func nextCursor(hasMore bool, last models.Cursor) *string {
+	if !hasMore {
+		return nil
+	}
+	s := last.Encode()
+	return &s
+}
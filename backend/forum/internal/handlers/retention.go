@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/HedgeHogSE/forum/backend/forum/internal/logger"
+	"github.com/HedgeHogSE/forum/backend/forum/internal/models"
+	"github.com/HedgeHogSE/forum/backend/forum/internal/retention"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+)
+
+var log3 zerolog.Logger
+
+func init() {
+	log3 = logger.GetLogger("retention_handler")
+}
+
+// policyTopicID возвращает ID топика из пути или nil для глобальной политики
+func policyTopicID(c *gin.Context) (*int, bool) {
+	param := c.Param("topic_id")
+	if param == "" {
+		return nil, true
+	}
+	id, err := strconv.Atoi(param)
+	if err != nil {
+		log3.Error().
+			Err(err).
+			Str("topic_id", param).
+			Msg("Invalid topic ID format")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid topic id"})
+		return nil, false
+	}
+	return &id, true
+}
+
+// requireRetentionManage отвечает 403, если у пользователя нет права retention:manage
+func requireRetentionManage(c *gin.Context) bool {
+	if hasPermission(c, permRetentionManage) {
+		return true
+	}
+	log3.Warn().
+		Int("user_id", currentUserID(c)).
+		Str("path", c.FullPath()).
+		Msg("Forbidden retention management")
+	c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+	return false
+}
+
+func GetRetentionPolicies(c *gin.Context) {
+	if !requireRetentionManage(c) {
+		return
+	}
+
+	policies, err := models.GetRetentionPolicies(c.Request.Context())
+	if err != nil {
+		log3.Error().Err(err).Msg("Failed to get retention policies")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get retention policies"})
+		return
+	}
+	if policies == nil {
+		policies = []models.RetentionPolicy{}
+	}
+	c.JSON(http.StatusOK, policies)
+}
+
+func PutRetentionPolicy(c *gin.Context) {
+	type RetentionPolicyInput struct {
+		Action    models.RetentionAction `json:"action" binding:"required"`
+		AfterDays int                    `json:"after_days"`
+	}
+
+	if !requireRetentionManage(c) {
+		return
+	}
+	topicID, ok := policyTopicID(c)
+	if !ok {
+		return
+	}
+
+	var input RetentionPolicyInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		log3.Error().
+			Err(err).
+			Interface("input", input).
+			Msg("Invalid retention policy input")
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if topicID != nil {
+		if _, err := models.GetTopicByID(c.Request.Context(), *topicID); err != nil {
+			log3.Error().
+				Err(err).
+				Int("topic_id", *topicID).
+				Msg("Failed to get topic")
+			c.JSON(http.StatusNotFound, gin.H{"error": "topic not found"})
+			return
+		}
+	}
+
+	policy, err := models.SetRetentionPolicy(c.Request.Context(), models.RetentionPolicy{
+		TopicId:   topicID,
+		Action:    input.Action,
+		AfterDays: input.AfterDays,
+	})
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidPolicy) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		log3.Error().Err(err).Msg("Failed to save retention policy")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save retention policy"})
+		return
+	}
+
+	log3.Info().
+		Int("policy_id", policy.ID).
+		Str("action", string(policy.Action)).
+		Int("after_days", policy.AfterDays).
+		Msg("Retention policy saved")
+	c.JSON(http.StatusOK, policy)
+}
+
+func DeleteRetentionPolicy(c *gin.Context) {
+	if !requireRetentionManage(c) {
+		return
+	}
+	topicID, ok := policyTopicID(c)
+	if !ok {
+		return
+	}
+
+	if err := models.DeleteRetentionPolicy(c.Request.Context(), topicID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "retention policy not found"})
+			return
+		}
+		log3.Error().Err(err).Msg("Failed to delete retention policy")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete retention policy"})
+		return
+	}
+
+	log3.Info().Msg("Retention policy deleted")
+	c.Status(http.StatusNoContent)
+}
+
+// RunRetention запускает применение политик вне расписания. По умолчанию в режиме dry-run
+func RunRetention(c *gin.Context) {
+	if !requireRetentionManage(c) {
+		return
+	}
+
+	dryRun := c.DefaultQuery("dry_run", "true") != "false"
+	report, err := retention.NewWorker(0, dryRun).RunOnce(c.Request.Context())
+	if err != nil {
+		log3.Error().Err(err).Msg("Retention run failed")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "retention run failed"})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
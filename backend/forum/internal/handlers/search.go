@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/HedgeHogSE/forum/backend/forum/internal/external"
+	"github.com/HedgeHogSE/forum/backend/forum/internal/logger"
+	"github.com/HedgeHogSE/forum/backend/forum/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+)
+
+var log2 zerolog.Logger
+
+func init() {
+	log2 = logger.GetLogger("search_handler")
+}
+
+func Search(c *gin.Context) {
+	type SearchResultWithUser struct {
+		models.SearchResult
+		Username string `json:"username"`
+	}
+
+	params := models.SearchParams{
+		Query: c.Query("q"),
+		Type:  c.Query("type"),
+	}
+	if params.Query == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "query parameter q is required"})
+		return
+	}
+	if params.Type != "" && params.Type != models.SearchTypeTopic && params.Type != models.SearchTypeComment {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid type"})
+		return
+	}
+
+	var err error
+	if s := c.Query("author_id"); s != "" {
+		if params.AuthorId, err = strconv.Atoi(s); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid author_id"})
+			return
+		}
+	}
+	if params.From, err = parseSearchDate(c.Query("from"), false); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid from"})
+		return
+	}
+	if params.To, err = parseSearchDate(c.Query("to"), true); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid to"})
+		return
+	}
+
+	limit, cursor, err := parsePageParams(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	params.After = cursor
+
+	log2.Info().
+		Str("query", params.Query).
+		Int("author_id", params.AuthorId).
+		Msg("Searching topics and comments")
+
+	params.Limit = limit + 1
+	results, err := models.Search(c.Request.Context(), params)
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidCursor) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cursor"})
+			return
+		}
+		log2.Error().
+			Err(err).
+			Str("query", params.Query).
+			Msg("Search failed")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "search failed"})
+		return
+	}
+
+	hasMore := len(results) > limit
+	if hasMore {
+		results = results[:limit]
+	}
+
+	authorIDs := make([]int, len(results))
+	for i, r := range results {
+		authorIDs[i] = r.AuthorId
+	}
+
+	names, err := external.GetUsernamesByUserIDs(c.Request.Context(), authorIDs)
+	if err != nil {
+		log2.Error().
+			Err(err).
+			Msg("Failed to get usernames from auth service")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "auth service unavailable"})
+		return
+	}
+
+	items := make([]SearchResultWithUser, 0, len(results))
+	for _, r := range results {
+		items = append(items, SearchResultWithUser{SearchResult: r, Username: names[r.AuthorId]})
+	}
+
+	var next *string
+	if len(results) > 0 {
+		next = nextCursor(hasMore, results[len(results)-1].Cursor())
+	}
+
+	log2.Info().
+		Str("query", params.Query).
+		Int("results_count", len(items)).
+		Msg("Search completed")
+	c.JSON(http.StatusOK, Page{Items: items, NextCursor: next})
+}
+
+// parseSearchDate принимает дату в формате RFC3339 или YYYY-MM-DD.
+// Для верхней границы дата без времени включает весь день целиком
+func parseSearchDate(s string, upper bool) (*time.Time, error) {
+	if s == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return &t, nil
+	}
+	t, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		return nil, err
+	}
+	if upper {
+		t = t.AddDate(0, 0, 1)
+	}
+	return &t, nil
+}
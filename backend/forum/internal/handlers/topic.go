@@ -2,12 +2,14 @@ package handlers
 
 import (
 	"database/sql"
+	"errors"
 	"net/http"
 	"strconv"
 	"time"
 
 	"github.com/HedgeHogSE/forum/backend/forum/internal/external"
 	"github.com/HedgeHogSE/forum/backend/forum/internal/logger"
+	"github.com/HedgeHogSE/forum/backend/forum/internal/metrics"
 	"github.com/HedgeHogSE/forum/backend/forum/internal/models"
 
 	"github.com/gin-gonic/gin"
@@ -22,46 +24,101 @@ func init() {
 
 func GetAllTopicsWithUsername(c *gin.Context) {
 	type TopicWithUser struct {
-		ID          int            `json:"id"`
-		Title       string         `json:"title"`
-		Description sql.NullString `json:"description"`
-		Name        string         `json:"name"`
+		ID             int            `json:"id"`
+		Title          string         `json:"title"`
+		Description    sql.NullString `json:"description"`
+		Name           string         `json:"name"`
+		CreatedAt      time.Time      `json:"created_at"`
+		CommentsCount  int            `json:"comments_count"`
+		LastActivityAt time.Time      `json:"last_activity_at"`
 	}
 
-	log1.Info().Msg("Getting all topics with usernames")
-	topics := make([]TopicWithUser, 0)
-	topics1 := models.GetAllTopics()
+	sort, err := models.ParseTopicSort(c.Query("sort"))
+	if err != nil {
+		log1.Error().
+			Err(err).
+			Str("sort", c.Query("sort")).
+			Msg("Invalid topic sort mode")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid sort"})
+		return
+	}
 
-	for i := 0; i < len(topics1); i++ {
-		name, err := external.GetUsernameByUserID(topics1[i].AuthorId)
-		if err != nil {
-			log1.Error().
-				Err(err).
-				Int("author_id", topics1[i].AuthorId).
-				Msg("Failed to get username from auth service")
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "auth service unavailable"})
+	limit, cursor, err := parsePageParams(c)
+	if err != nil {
+		log1.Error().
+			Err(err).
+			Msg("Invalid pagination parameters")
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	log1.Info().
+		Str("sort", string(sort)).
+		Int("limit", limit).
+		Msg("Getting topics page with usernames")
+	topics1, err := models.ListTopics(c.Request.Context(), sort, cursor, limit+1)
+	if err != nil {
+		log1.Error().
+			Err(err).
+			Msg("Failed to get topics page")
+		if errors.Is(err, models.ErrInvalidCursor) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cursor"})
 			return
 		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get topics"})
+		return
+	}
+
+	hasMore := len(topics1) > limit
+	if hasMore {
+		topics1 = topics1[:limit]
+	}
+
+	authorIDs := make([]int, len(topics1))
+	for i := range topics1 {
+		authorIDs[i] = topics1[i].AuthorId
+	}
+
+	names, err := external.GetUsernamesByUserIDs(c.Request.Context(), authorIDs)
+	if err != nil {
+		log1.Error().
+			Err(err).
+			Msg("Failed to get usernames from auth service")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "auth service unavailable"})
+		return
+	}
+
+	topics := make([]TopicWithUser, 0, len(topics1))
+	for i := 0; i < len(topics1); i++ {
 		topics = append(topics, TopicWithUser{
-			ID:          topics1[i].ID,
-			Title:       topics1[i].Title,
-			Description: topics1[i].Description,
-			Name:        name,
+			ID:             topics1[i].ID,
+			Title:          topics1[i].Title,
+			Description:    topics1[i].Description,
+			Name:           names[topics1[i].AuthorId],
+			CreatedAt:      topics1[i].CreatedAt,
+			CommentsCount:  topics1[i].CommentsCount,
+			LastActivityAt: topics1[i].LastActivityAt,
 		})
 	}
-	log1.Info().Int("topics_count", len(topics)).Msg("Successfully retrieved all topics")
-	c.JSON(http.StatusOK, topics)
+
+	var next *string
+	if len(topics1) > 0 {
+		next = nextCursor(hasMore, topics1[len(topics1)-1].Cursor(sort))
+	}
+
+	log1.Info().Int("topics_count", len(topics)).Msg("Successfully retrieved topics page")
+	c.JSON(http.StatusOK, Page{Items: topics, NextCursor: next})
 }
 
 func GetTopicWithData(c *gin.Context) {
 	type TopicWithData struct {
-		ID          int                          `json:"id"`
-		Title       string                       `json:"title"`
-		Description sql.NullString               `json:"description"`
-		CreatedAt   time.Time                    `json:"created_at"`
-		Username    string                       `json:"username"`
-		Comments    []models.CommentWithUsername `json:"comments"`
-		AuthorID    int                          `json:"author_id"`
+		ID          int            `json:"id"`
+		Title       string         `json:"title"`
+		Description sql.NullString `json:"description"`
+		CreatedAt   time.Time      `json:"created_at"`
+		Username    string         `json:"username"`
+		Comments    interface{}    `json:"comments"`
+		AuthorID    int            `json:"author_id"`
 	}
 
 	topicID, err := strconv.Atoi(c.Param("topic_id"))
@@ -75,7 +132,7 @@ func GetTopicWithData(c *gin.Context) {
 	}
 
 	log1.Info().Int("topic_id", topicID).Msg("Getting topic with data")
-	topic, err := models.GetTopicByID(topicID)
+	topic, err := models.GetTopicByID(c.Request.Context(), topicID)
 	if err != nil {
 		log1.Error().
 			Err(err).
@@ -85,7 +142,7 @@ func GetTopicWithData(c *gin.Context) {
 		return
 	}
 
-	username, err := external.GetUsernameByUserID(topic.AuthorId)
+	username, err := external.GetUsernameByUserID(c.Request.Context(), topic.AuthorId)
 	if err != nil {
 		log1.Error().
 			Err(err).
@@ -95,7 +152,7 @@ func GetTopicWithData(c *gin.Context) {
 		return
 	}
 
-	comments, err := models.GetCommentsByTopicID(topicID)
+	comments, err := models.GetCommentsByTopicID(c.Request.Context(), topicID)
 	if err != nil {
 		log1.Error().
 			Err(err).
@@ -113,6 +170,9 @@ func GetTopicWithData(c *gin.Context) {
 		Comments:    comments,
 		AuthorID:    topic.AuthorId,
 	}
+	if c.Query("view") == "tree" {
+		res.Comments = models.BuildCommentTree(comments)
+	}
 
 	log1.Info().
 		Int("topic_id", topicID).
@@ -123,7 +183,7 @@ func GetTopicWithData(c *gin.Context) {
 
 func GetTopic(c *gin.Context) {
 	if topicID, err := strconv.Atoi(c.Param("topic_id")); err == nil {
-		if topic, err := models.GetTopicByID(topicID); err == nil {
+		if topic, err := models.GetTopicByID(c.Request.Context(), topicID); err == nil {
 			c.JSON(http.StatusOK, topic)
 
 		} else {
@@ -147,7 +207,6 @@ func PostNewTopic(c *gin.Context) {
 	type CreateTopicInput struct {
 		Title       string `json:"title"`
 		Description string `json:"description"`
-		AuthorId    int    `json:"author_id"`
 	}
 
 	var newTopic CreateTopicInput
@@ -160,9 +219,10 @@ func PostNewTopic(c *gin.Context) {
 		return
 	}
 
+	authorID := currentUserID(c)
 	log1.Info().
 		Str("title", newTopic.Title).
-		Int("author_id", newTopic.AuthorId).
+		Int("author_id", authorID).
 		Msg("Creating new topic")
 
 	topic := &models.Topic{
@@ -171,14 +231,25 @@ func PostNewTopic(c *gin.Context) {
 			String: newTopic.Description,
 			Valid:  newTopic.Description != "",
 		},
-		AuthorId: newTopic.AuthorId,
+		AuthorId: authorID,
+	}
+
+	id, err := models.AddTopic(c.Request.Context(), topic)
+	if err != nil {
+		log1.Error().
+			Err(err).
+			Int("author_id", authorID).
+			Msg("Failed to create topic")
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
+	topic.ID = id
+	metrics.TopicsCreated.Inc()
 
-	models.AddTopic(topic)
 	log1.Info().
 		Int("topic_id", topic.ID).
 		Msg("Successfully created new topic")
-	c.JSON(http.StatusCreated, newTopic)
+	c.JSON(http.StatusCreated, topic)
 }
 
 func DeleteTopic(c *gin.Context) {
@@ -192,8 +263,33 @@ func DeleteTopic(c *gin.Context) {
 		return
 	}
 
+	topic, err := models.GetTopicByID(c.Request.Context(), topicID)
+	if err != nil {
+		log1.Error().
+			Err(err).
+			Int("topic_id", topicID).
+			Msg("Failed to get topic")
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	if !canModify(c, topic.AuthorId, permTopicsModerate) {
+		log1.Warn().
+			Int("topic_id", topicID).
+			Int("user_id", currentUserID(c)).
+			Msg("Forbidden topic deletion")
+		c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+		return
+	}
+
 	log1.Info().Int("topic_id", topicID).Msg("Deleting topic")
-	models.DeleteTopicByID(topicID)
+	if err := models.DeleteTopicByID(c.Request.Context(), topicID); err != nil {
+		log1.Error().
+			Err(err).
+			Int("topic_id", topicID).
+			Msg("Failed to delete topic")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete topic"})
+		return
+	}
 	log1.Info().Int("topic_id", topicID).Msg("Successfully deleted topic")
 	c.JSON(http.StatusNoContent, gin.H{
 		"message": "Topic deleted",
@@ -226,12 +322,30 @@ func PutTopic(c *gin.Context) {
 		return
 	}
 
+	topic, err := models.GetTopicByID(c.Request.Context(), id)
+	if err != nil {
+		log1.Error().
+			Err(err).
+			Int("topic_id", id).
+			Msg("Failed to get topic")
+		c.JSON(http.StatusNotFound, gin.H{"error": "topic not found"})
+		return
+	}
+	if !canModify(c, topic.AuthorId, permTopicsModerate) {
+		log1.Warn().
+			Int("topic_id", id).
+			Int("user_id", currentUserID(c)).
+			Msg("Forbidden topic update")
+		c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+		return
+	}
+
 	log1.Info().
 		Int("topic_id", id).
 		Str("title", newTopic.Title).
 		Msg("Updating topic")
 
-	updated, err := models.PutTopic(id, &models.Topic{
+	updated, err := models.PutTopic(c.Request.Context(), id, &models.Topic{
 		Title: newTopic.Title,
 		Description: sql.NullString{
 			String: newTopic.Description,
@@ -0,0 +1,21 @@
+// Package metrics содержит метрики предметной области forum-сервиса.
+// Метрики HTTP, gRPC и БД собирает общий пакет backend/metrics
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	TopicsCreated = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "forum_topics_created_total",
+		Help: "Количество созданных топиков",
+	})
+
+	// CommentsCreated считает комментарии и ответы по источнику: http или websocket
+	CommentsCreated = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "forum_comments_created_total",
+		Help: "Количество созданных комментариев",
+	}, []string{"source"})
+)
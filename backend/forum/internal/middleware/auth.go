@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/HedgeHogSE/forum/backend/forum/internal/external"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AuthMiddleware проверяет JWT из заголовка Authorization через auth-сервис
+// и кладет данные пользователя в контекст запроса
+func AuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authHeader := c.GetHeader("Authorization")
+		if authHeader == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header is required"})
+			c.Abort()
+			return
+		}
+
+		parts := strings.Split(authHeader, " ")
+		if len(parts) != 2 || parts[0] != "Bearer" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authorization header format"})
+			c.Abort()
+			return
+		}
+
+		identity, err := external.ValidateToken(c.Request.Context(), parts[1])
+		if err != nil {
+			if errors.Is(err, external.ErrInvalidToken) {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			} else {
+				c.JSON(http.StatusServiceUnavailable, gin.H{"error": "auth service unavailable"})
+			}
+			c.Abort()
+			return
+		}
+
+		c.Set("user_id", identity.UserID)
+		c.Set("username", identity.Username)
+		c.Set("is_admin", identity.IsAdmin)
+		c.Set("role", identity.Role)
+		c.Set("permissions", identity.Permissions)
+		c.Set("email_verified", identity.EmailVerified)
+		c.Next()
+	}
+}
+
+// RequireVerifiedEmail запрещает запрос пользователям с неподтвержденным email.
+// Должен стоять после AuthMiddleware; при required=false ничего не проверяет
+func RequireVerifiedEmail(required bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if required && !c.GetBool("email_verified") {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Email is not verified"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,125 @@
+package middleware_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/HedgeHogSE/forum/backend/forum/internal/external"
+	"github.com/HedgeHogSE/forum/backend/forum/internal/middleware"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupAuthRouter(t *testing.T) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	// Подменяем проверку токена: валиден только "good-token"
+	originalFunc := external.ValidateToken
+	t.Cleanup(func() { external.ValidateToken = originalFunc })
+	external.ValidateToken = func(_ context.Context, token string) (*external.Identity, error) {
+		switch token {
+		case "good-token":
+			return &external.Identity{
+				UserID:        7,
+				Username:      "alice",
+				IsAdmin:       true,
+				Role:          "admin",
+				Permissions:   []string{"comments:moderate"},
+				EmailVerified: true,
+			}, nil
+		case "unverified-token":
+			return &external.Identity{UserID: 8, Username: "bob", Role: "user"}, nil
+		case "down-token":
+			return nil, assert.AnError
+		}
+		return nil, external.ErrInvalidToken
+	}
+
+	router := gin.New()
+	router.GET("/protected", middleware.AuthMiddleware(), func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{
+			"user_id":        c.GetInt("user_id"),
+			"username":       c.GetString("username"),
+			"is_admin":       c.GetBool("is_admin"),
+			"role":           c.GetString("role"),
+			"permissions":    c.GetStringSlice("permissions"),
+			"email_verified": c.GetBool("email_verified"),
+		})
+	})
+	router.POST("/posts", middleware.AuthMiddleware(), middleware.RequireVerifiedEmail(true), func(c *gin.Context) {
+		c.Status(http.StatusCreated)
+	})
+	router.POST("/open", middleware.AuthMiddleware(), middleware.RequireVerifiedEmail(false), func(c *gin.Context) {
+		c.Status(http.StatusCreated)
+	})
+	return router
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	router := setupAuthRouter(t)
+
+	tests := []struct {
+		name   string
+		header string
+		status int
+	}{
+		{"Missing Header", "", http.StatusUnauthorized},
+		{"Invalid Format", "Token good-token", http.StatusUnauthorized},
+		{"Invalid Token", "Bearer bad-token", http.StatusUnauthorized},
+		{"Auth Service Unavailable", "Bearer down-token", http.StatusServiceUnavailable},
+		{"Valid Token", "Bearer good-token", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/protected", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.status, w.Code)
+		})
+	}
+}
+
+func TestAuthMiddleware_SetsIdentity(t *testing.T) {
+	router := setupAuthRouter(t)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/protected", nil)
+	req.Header.Set("Authorization", "Bearer good-token")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"user_id":7,"username":"alice","is_admin":true,"role":"admin","permissions":["comments:moderate"],"email_verified":true}`, w.Body.String())
+}
+
+func TestRequireVerifiedEmail(t *testing.T) {
+	router := setupAuthRouter(t)
+
+	tests := []struct {
+		name   string
+		path   string
+		token  string
+		status int
+	}{
+		{"Verified", "/posts", "good-token", http.StatusCreated},
+		{"Unverified", "/posts", "unverified-token", http.StatusForbidden},
+		{"Check Disabled", "/open", "unverified-token", http.StatusCreated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", tt.path, nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.status, w.Code)
+		})
+	}
+}
@@ -1,8 +1,12 @@
 package models
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/HedgeHogSE/forum/backend/forum/internal/db"
@@ -14,6 +18,7 @@ type Comment struct {
 	Content   string    `json:"content"`
 	AuthorId  int       `json:"author_id"`
 	TopicId   int       `json:"topic_id"`
+	ParentId  *int      `json:"parent_id"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
@@ -23,11 +28,19 @@ type CommentWithUsername struct {
 	Content   string    `json:"content"`
 	AuthorId  int       `json:"author_id"`
 	TopicId   int       `json:"topic_id"`
+	ParentId  *int      `json:"parent_id"`
+	Depth     int       `json:"depth"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 	Username  string    `json:"username"`
 }
 
+// CommentNode - комментарий вместе с вложенными ответами
+type CommentNode struct {
+	CommentWithUsername
+	Replies []*CommentNode `json:"replies"`
+}
+
 // CommentService реализует интерфейс grpc.CommentService
 type CommentService struct{}
 
@@ -36,10 +49,12 @@ func NewCommentService() *CommentService {
 	return &CommentService{}
 }
 
-func GetAllComments() []Comment {
+func GetAllComments(ctx context.Context) []Comment {
 	var comments []Comment
 
-	rows, err := db.Db.Query("SELECT * FROM comments")
+	rows, err := db.Db.QueryContext(ctx, `
+		SELECT id, content, author_id, topic_id, created_at, updated_at, parent_id
+		FROM comments`)
 	if err != nil {
 		log.Fatal("Ошибка при выполнении запроса:", err)
 	}
@@ -48,7 +63,7 @@ func GetAllComments() []Comment {
 	for rows.Next() {
 		var c Comment
 
-		err := rows.Scan(&c.ID, &c.Content, &c.AuthorId, &c.TopicId, &c.CreatedAt, &c.UpdatedAt)
+		err := rows.Scan(&c.ID, &c.Content, &c.AuthorId, &c.TopicId, &c.CreatedAt, &c.UpdatedAt, &c.ParentId)
 		if err != nil {
 			log.Println("Ошибка при сканировании строки:", err)
 			continue
@@ -62,10 +77,12 @@ func GetAllComments() []Comment {
 	return comments
 }
 
-func GetCommentByID(id int) (*Comment, error) {
+func GetCommentByID(ctx context.Context, id int) (*Comment, error) {
 	var c Comment
-	err := db.Db.QueryRow("SELECT * FROM comments WHERE id = $1", id).
-		Scan(&c.ID, &c.Content, &c.AuthorId, &c.TopicId, &c.CreatedAt, &c.UpdatedAt)
+	err := db.Db.QueryRowContext(ctx, `
+		SELECT id, content, author_id, topic_id, created_at, updated_at, parent_id
+		FROM comments WHERE id = $1`, id).
+		Scan(&c.ID, &c.Content, &c.AuthorId, &c.TopicId, &c.CreatedAt, &c.UpdatedAt, &c.ParentId)
 
 	if err != nil {
 		return nil, err
@@ -75,9 +92,11 @@ func GetCommentByID(id int) (*Comment, error) {
 }
 
 // GetCommentsByAuthorID получает комментарии по ID автора
-func GetCommentsByAuthorID(authorID int) ([]Comment, error) {
+func GetCommentsByAuthorID(ctx context.Context, authorID int) ([]Comment, error) {
 	var comments []Comment
-	rows, err := db.Db.Query("SELECT * FROM comments WHERE author_id = $1", authorID)
+	rows, err := db.Db.QueryContext(ctx, `
+		SELECT id, content, author_id, topic_id, created_at, updated_at, parent_id
+		FROM comments WHERE author_id = $1`, authorID)
 	if err != nil {
 		return nil, err
 	}
@@ -85,7 +104,7 @@ func GetCommentsByAuthorID(authorID int) ([]Comment, error) {
 
 	for rows.Next() {
 		var c Comment
-		err := rows.Scan(&c.ID, &c.Content, &c.AuthorId, &c.TopicId, &c.CreatedAt, &c.UpdatedAt)
+		err := rows.Scan(&c.ID, &c.Content, &c.AuthorId, &c.TopicId, &c.CreatedAt, &c.UpdatedAt, &c.ParentId)
 		if err != nil {
 			log.Println("Ошибка при сканировании строки:", err)
 			continue
@@ -100,88 +119,289 @@ func GetCommentsByAuthorID(authorID int) ([]Comment, error) {
 }
 
 // GetCommentsByAuthorID получает комментарии по ID автора
-func (s *CommentService) GetCommentsByAuthorID(authorID int) ([]Comment, error) {
-	return GetCommentsByAuthorID(authorID)
+func (s *CommentService) GetCommentsByAuthorID(ctx context.Context, authorID int) ([]Comment, error) {
+	return GetCommentsByAuthorID(ctx, authorID)
+}
+
+// GetCommentsByTopicID возвращает комментарии топика плоским списком в порядке
+// ветки: каждый ответ идёт сразу после своего родителя, Depth - уровень вложенности
+func GetCommentsByTopicID(ctx context.Context, id int) ([]CommentWithUsername, error) {
+	query := `
+		WITH RECURSIVE thread AS (
+			SELECT id, content, author_id, topic_id, created_at, updated_at, parent_id,
+				0 AS depth, ARRAY[id] AS path
+			FROM comments
+			WHERE topic_id = $1 AND parent_id IS NULL
+			UNION ALL
+			SELECT c.id, c.content, c.author_id, c.topic_id, c.created_at, c.updated_at, c.parent_id,
+				t.depth + 1, t.path || c.id
+			FROM comments c
+			JOIN thread t ON c.parent_id = t.id
+		)
+		SELECT id, content, author_id, topic_id, created_at, updated_at, parent_id, depth
+		FROM thread
+		ORDER BY path
+	`
+	return queryThread(ctx, query, id)
 }
 
-func GetCommentsByTopicID(id int) ([]CommentWithUsername, error) {
-	var comments []CommentWithUsername
-	rows, err := db.Db.Query("SELECT * FROM comments WHERE topic_id = $1 ORDER BY created_at", id)
+// GetCommentTreeByTopicID возвращает комментарии топика в виде дерева
+func GetCommentTreeByTopicID(ctx context.Context, id int) ([]*CommentNode, error) {
+	comments, err := GetCommentsByTopicID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return BuildCommentTree(comments), nil
+}
+
+// GetRepliesByCommentID возвращает все ответы на комментарий в порядке ветки.
+// Depth считается относительно исходного комментария: прямые ответы имеют Depth = 1
+func GetRepliesByCommentID(ctx context.Context, id int) ([]CommentWithUsername, error) {
+	query := `
+		WITH RECURSIVE thread AS (
+			SELECT id, content, author_id, topic_id, created_at, updated_at, parent_id,
+				1 AS depth, ARRAY[id] AS path
+			FROM comments
+			WHERE parent_id = $1
+			UNION ALL
+			SELECT c.id, c.content, c.author_id, c.topic_id, c.created_at, c.updated_at, c.parent_id,
+				t.depth + 1, t.path || c.id
+			FROM comments c
+			JOIN thread t ON c.parent_id = t.id
+		)
+		SELECT id, content, author_id, topic_id, created_at, updated_at, parent_id, depth
+		FROM thread
+		ORDER BY path
+	`
+	return queryThread(ctx, query, id)
+}
+
+// GetCommentDepth возвращает уровень вложенности комментария в ветке (0 - корневой)
+func GetCommentDepth(ctx context.Context, id int) (int, error) {
+	query := `
+		WITH RECURSIVE ancestors AS (
+			SELECT id, parent_id, 0 AS depth FROM comments WHERE id = $1
+			UNION ALL
+			SELECT c.id, c.parent_id, a.depth + 1
+			FROM comments c
+			JOIN ancestors a ON c.id = a.parent_id
+		)
+		SELECT MAX(depth) FROM ancestors
+	`
+	var depth sql.NullInt64
+	if err := db.Db.QueryRowContext(ctx, query, id).Scan(&depth); err != nil {
+		return 0, err
+	}
+	if !depth.Valid {
+		return 0, fmt.Errorf("комментарий с id %d не найден", id)
+	}
+	return int(depth.Int64), nil
+}
+
+// BuildCommentTree собирает дерево из плоского списка в порядке ветки.
+// Комментарии, родитель которых не входит в список, становятся корнями
+func BuildCommentTree(comments []CommentWithUsername) []*CommentNode {
+	roots := make([]*CommentNode, 0)
+	nodes := make(map[int]*CommentNode, len(comments))
+
+	for _, c := range comments {
+		node := &CommentNode{CommentWithUsername: c, Replies: make([]*CommentNode, 0)}
+		nodes[c.ID] = node
+
+		if c.ParentId != nil {
+			if parent, ok := nodes[*c.ParentId]; ok {
+				parent.Replies = append(parent.Replies, node)
+				continue
+			}
+		}
+		roots = append(roots, node)
+	}
+	return roots
+}
+
+func queryThread(ctx context.Context, query string, args ...interface{}) ([]CommentWithUsername, error) {
+	comments := make([]CommentWithUsername, 0)
+	rows, err := db.Db.QueryContext(ctx, query, args...)
 	if err != nil {
-		log.Println(rows)
-		log.Println(err)
 		return nil, err
 	}
 	defer rows.Close()
+
 	for rows.Next() {
 		var c CommentWithUsername
-		err := rows.Scan(&c.ID, &c.Content, &c.AuthorId, &c.TopicId, &c.CreatedAt, &c.UpdatedAt)
-		username, err := external.GetUsernameByUserID(c.AuthorId)
+		err := rows.Scan(&c.ID, &c.Content, &c.AuthorId, &c.TopicId,
+			&c.CreatedAt, &c.UpdatedAt, &c.ParentId, &c.Depth)
 		if err != nil {
 			log.Println("Ошибка при сканировании строки:", err)
 			continue
 		}
-		c.Username = username
 		comments = append(comments, c)
 	}
 
-	if err != nil {
-		log.Println("Ошибка при получении имени пользователя:", err)
+	if err := rows.Err(); err != nil {
+		log.Println("Ошибка после итерации по строкам:", err)
 		return nil, err
 	}
 
-	if err := rows.Err(); err != nil {
-		log.Println("Ошибка после итерации по строкам:", err)
+	if err := fillUsernames(ctx, comments); err != nil {
+		log.Println("Ошибка при получении имени пользователя:", err)
+		return nil, err
 	}
 	return comments, nil
 }
 
-func AddComment(c *Comment) (int, error) {
+// fillUsernames подставляет имена авторов, запрашивая их у auth-сервиса одним вызовом
+func fillUsernames(ctx context.Context, comments []CommentWithUsername) error {
+	if len(comments) == 0 {
+		return nil
+	}
+
+	authorIDs := make([]int, len(comments))
+	for i, c := range comments {
+		authorIDs[i] = c.AuthorId
+	}
+
+	names, err := external.GetUsernamesByUserIDs(ctx, authorIDs)
+	if err != nil {
+		return err
+	}
+
+	for i := range comments {
+		comments[i].Username = names[comments[i].AuthorId]
+	}
+	return nil
+}
+
+// AddComment сохраняет комментарий или ответ. Пустой текст, в том числе из одних
+// пробелов, не принимается
+func AddComment(ctx context.Context, c *Comment) (int, error) {
+	if strings.TrimSpace(c.Content) == "" {
+		return 0, fmt.Errorf("текст комментария не может быть пустым")
+	}
+	if c.ParentId != nil {
+		parent, err := GetCommentByID(ctx, *c.ParentId)
+		if err != nil {
+			return 0, fmt.Errorf("родительский комментарий с id %d не найден: %w", *c.ParentId, err)
+		}
+		if parent.TopicId != c.TopicId {
+			return 0, fmt.Errorf("родительский комментарий относится к другому топику")
+		}
+	}
+
 	var id int
 	query := `
-		INSERT INTO comments (content, author_id, topic_id)
-		VALUES ($1, $2, $3)
+		INSERT INTO comments (content, author_id, topic_id, parent_id)
+		VALUES ($1, $2, $3, $4)
 		RETURNING id;
 	`
 
-	err := db.Db.QueryRow(query, c.Content, c.AuthorId, c.TopicId).Scan(&id)
+	err := db.Db.QueryRowContext(ctx, query, c.Content, c.AuthorId, c.TopicId, c.ParentId).Scan(&id)
 	if err != nil {
 		return 0, fmt.Errorf("не удалось добавить комментарий: %w", err)
 	}
 	return id, nil
 }
 
-func DeleteCommentByID(id int) error {
-	query := `DELETE FROM comments WHERE id = $1`
-	result, err := db.Db.Exec(query, id)
+// DeleteCommentByID удаляет комментарий. Ответы на него остаются и становятся корневыми
+// (parent_id = NULL), их id возвращаются, чтобы подписчики топика обновили ветку
+func DeleteCommentByID(ctx context.Context, id int) ([]int, error) {
+	tx, err := db.Db.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("не удалось удалить комментарий: %w", err)
+		return nil, fmt.Errorf("не удалось начать транзакцию: %w", err)
 	}
+	defer tx.Rollback()
 
-	rowsAffected, err := result.RowsAffected()
+	// Блокировка комментария не дает добавить к нему ответ до удаления
+	var locked int
+	err = tx.QueryRowContext(ctx, `SELECT id FROM comments WHERE id = $1 FOR UPDATE`, id).Scan(&locked)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("комментарий с id %d не найден", id)
+	}
 	if err != nil {
-		return fmt.Errorf("ошибка при получении количества удаленных строк: %w", err)
+		return nil, fmt.Errorf("не удалось удалить комментарий: %w", err)
 	}
 
-	if rowsAffected == 0 {
-		return fmt.Errorf("комментарий с id %d не найден", id)
+	rows, err := tx.QueryContext(ctx, `SELECT id FROM comments WHERE parent_id = $1 ORDER BY id`, id)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить ответы на комментарий: %w", err)
+	}
+	replyIDs := make([]int, 0)
+	for rows.Next() {
+		var replyID int
+		if err := rows.Scan(&replyID); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("не удалось получить ответы на комментарий: %w", err)
+		}
+		replyIDs = append(replyIDs, replyID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("не удалось получить ответы на комментарий: %w", err)
 	}
 
-	return nil
+	if _, err := tx.ExecContext(ctx, `DELETE FROM comments WHERE id = $1`, id); err != nil {
+		return nil, fmt.Errorf("не удалось удалить комментарий: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("не удалось завершить транзакцию: %w", err)
+	}
+	return replyIDs, nil
 }
 
-func PutComment(id int, updated Comment) (Comment, error) {
+func PutComment(ctx context.Context, id int, updated Comment) (Comment, error) {
+	if strings.TrimSpace(updated.Content) == "" {
+		return Comment{}, fmt.Errorf("текст комментария не может быть пустым")
+	}
+
 	query := `
 		UPDATE comments 
 		SET content = $1, author_id = $2, topic_id = $3
 		WHERE id = $4
-		RETURNING id, content, author_id, topic_id, created_at, updated_at
+		RETURNING id, content, author_id, topic_id, created_at, updated_at, parent_id
 	`
 	var c Comment
-	err := db.Db.QueryRow(query, updated.Content, updated.AuthorId, updated.TopicId, id).Scan(
-		&c.ID, &c.Content, &c.AuthorId, &c.TopicId, &c.CreatedAt, &c.UpdatedAt)
+	err := db.Db.QueryRowContext(ctx, query, updated.Content, updated.AuthorId, updated.TopicId, id).Scan(
+		&c.ID, &c.Content, &c.AuthorId, &c.TopicId, &c.CreatedAt, &c.UpdatedAt, &c.ParentId)
 	if err != nil {
 		return Comment{}, fmt.Errorf("не удалось обновить комментарий: %w", err)
 	}
 	return c, nil
 }
+
+// ListComments возвращает страницу комментариев в порядке создания, начиная после курсора after.
+// Если after равен nil, возвращается первая страница
+func ListComments(ctx context.Context, after *Cursor, limit int) ([]Comment, error) {
+	afterID := 0
+	if after != nil {
+		if after.Sort != "" {
+			return nil, fmt.Errorf("%w: курсор не соответствует списку комментариев", ErrInvalidCursor)
+		}
+		afterID = after.ID
+	}
+
+	rows, err := db.Db.QueryContext(ctx, `
+		SELECT id, content, author_id, topic_id, created_at, updated_at, parent_id
+		FROM comments
+		WHERE id > $1
+		ORDER BY id
+		LIMIT $2`, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить список комментариев: %w", err)
+	}
+	defer rows.Close()
+
+	comments := make([]Comment, 0, limit)
+	for rows.Next() {
+		var c Comment
+		err := rows.Scan(&c.ID, &c.Content, &c.AuthorId, &c.TopicId, &c.CreatedAt, &c.UpdatedAt, &c.ParentId)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка при сканировании строки: %w", err)
+		}
+		comments = append(comments, c)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return comments, nil
+}
@@ -1,6 +1,7 @@
 package models_test
 
 import (
+	"context"
 	"database/sql"
 	"testing"
 
@@ -22,7 +23,7 @@ func TestCommentCRUD(t *testing.T) {
 		Description: sql.NullString{String: "Test Description", Valid: true},
 		AuthorId:    testUserID,
 	}
-	topicID, err := models.AddTopic(topic)
+	topicID, err := models.AddTopic(context.Background(), topic)
 	assert.NoError(t, err)
 
 	// Создаем тестовый комментарий
@@ -34,7 +35,7 @@ func TestCommentCRUD(t *testing.T) {
 
 	// Тест AddComment
 	t.Run("AddComment", func(t *testing.T) {
-		id, err := models.AddComment(comment)
+		id, err := models.AddComment(context.Background(), comment)
 		assert.NoError(t, err)
 		assert.Greater(t, id, 0)
 		comment.ID = id
@@ -42,7 +43,7 @@ func TestCommentCRUD(t *testing.T) {
 
 	// Тест GetCommentByID
 	t.Run("GetCommentByID", func(t *testing.T) {
-		retrievedComment, err := models.GetCommentByID(comment.ID)
+		retrievedComment, err := models.GetCommentByID(context.Background(), comment.ID)
 		assert.NoError(t, err)
 		assert.Equal(t, comment.Content, retrievedComment.Content)
 		assert.Equal(t, comment.AuthorId, retrievedComment.AuthorId)
@@ -51,7 +52,7 @@ func TestCommentCRUD(t *testing.T) {
 
 	// Тест GetCommentsByTopicID
 	t.Run("GetCommentsByTopicID", func(t *testing.T) {
-		comments, err := models.GetCommentsByTopicID(topicID)
+		comments, err := models.GetCommentsByTopicID(context.Background(), topicID)
 		assert.NoError(t, err)
 		assert.Len(t, comments, 1)
 		assert.Equal(t, comment.Content, comments[0].Content)
@@ -61,7 +62,7 @@ func TestCommentCRUD(t *testing.T) {
 
 	// Тест GetCommentsByAuthorID
 	t.Run("GetCommentsByAuthorID", func(t *testing.T) {
-		comments, err := models.GetCommentsByAuthorID(comment.AuthorId)
+		comments, err := models.GetCommentsByAuthorID(context.Background(), comment.AuthorId)
 		assert.NoError(t, err)
 		assert.Len(t, comments, 1)
 		assert.Equal(t, comment.Content, comments[0].Content)
@@ -77,7 +78,7 @@ func TestCommentCRUD(t *testing.T) {
 			TopicId:  comment.TopicId,
 		}
 
-		result, err := models.PutComment(comment.ID, updatedComment)
+		result, err := models.PutComment(context.Background(), comment.ID, updatedComment)
 		assert.NoError(t, err)
 		assert.Equal(t, updatedComment.Content, result.Content)
 		assert.Equal(t, updatedComment.AuthorId, result.AuthorId)
@@ -86,11 +87,12 @@ func TestCommentCRUD(t *testing.T) {
 
 	// Тест DeleteCommentByID
 	t.Run("DeleteCommentByID", func(t *testing.T) {
-		err := models.DeleteCommentByID(comment.ID)
+		replyIDs, err := models.DeleteCommentByID(context.Background(), comment.ID)
 		assert.NoError(t, err)
+		assert.Empty(t, replyIDs)
 
 		// Проверяем, что комментарий удален
-		_, err = models.GetCommentByID(comment.ID)
+		_, err = models.GetCommentByID(context.Background(), comment.ID)
 		assert.Error(t, err)
 	})
 }
@@ -101,7 +103,7 @@ func TestGetCommentByID_NotFound(t *testing.T) {
 	clearTestDB(t)
 
 	// Пытаемся получить несуществующий комментарий
-	_, err := models.GetCommentByID(999)
+	_, err := models.GetCommentByID(context.Background(), 999)
 	assert.Error(t, err)
 }
 
@@ -111,7 +113,7 @@ func TestGetCommentsByTopicID_Empty(t *testing.T) {
 	clearTestDB(t)
 
 	// Пытаемся получить комментарии для несуществующего топика
-	comments, err := models.GetCommentsByTopicID(999)
+	comments, err := models.GetCommentsByTopicID(context.Background(), 999)
 	assert.NoError(t, err)
 	assert.Empty(t, comments)
 }
@@ -122,7 +124,7 @@ func TestGetCommentsByAuthorID_Empty(t *testing.T) {
 	clearTestDB(t)
 
 	// Пытаемся получить комментарии для несуществующего автора
-	comments, err := models.GetCommentsByAuthorID(999)
+	comments, err := models.GetCommentsByAuthorID(context.Background(), 999)
 	assert.NoError(t, err)
 	assert.Empty(t, comments)
 }
@@ -139,7 +141,7 @@ func TestPutComment_NotFound(t *testing.T) {
 		TopicId:  1,
 	}
 
-	_, err := models.PutComment(999, updatedComment)
+	_, err := models.PutComment(context.Background(), 999, updatedComment)
 	assert.Error(t, err)
 }
 
@@ -149,7 +151,7 @@ func TestDeleteCommentByID_NotFound(t *testing.T) {
 	clearTestDB(t)
 
 	// Пытаемся удалить несуществующий комментарий
-	err := models.DeleteCommentByID(999)
+	_, err := models.DeleteCommentByID(context.Background(), 999)
 	assert.Error(t, err)
 }
 
@@ -163,18 +165,31 @@ func TestAddComment_InvalidData(t *testing.T) {
 		AuthorId: testUserID,
 		TopicId:  1,
 	}
-	_, err := models.AddComment(comment)
+	_, err := models.AddComment(context.Background(), comment)
 	if err == nil {
 		t.Error("AddComment не вернул ошибку для пустого содержимого")
 	}
 
+	// Ответ из одних пробелов тоже отклоняется
+	parentID := 1
+	comment = &models.Comment{
+		Content:  " \n\t ",
+		AuthorId: testUserID,
+		TopicId:  1,
+		ParentId: &parentID,
+	}
+	_, err = models.AddComment(context.Background(), comment)
+	if err == nil {
+		t.Error("AddComment не вернул ошибку для ответа из пробелов")
+	}
+
 	// Тест с несуществующим автором
 	comment = &models.Comment{
 		Content:  "Test comment",
 		AuthorId: 999,
 		TopicId:  1,
 	}
-	_, err = models.AddComment(comment)
+	_, err = models.AddComment(context.Background(), comment)
 	if err == nil {
 		t.Error("AddComment не вернул ошибку для несуществующего автора")
 	}
@@ -185,7 +200,7 @@ func TestAddComment_InvalidData(t *testing.T) {
 		AuthorId: testUserID,
 		TopicId:  999,
 	}
-	_, err = models.AddComment(comment)
+	_, err = models.AddComment(context.Background(), comment)
 	if err == nil {
 		t.Error("AddComment не вернул ошибку для несуществующего топика")
 	}
@@ -201,7 +216,7 @@ func TestPutComment_InvalidData(t *testing.T) {
 		AuthorId: testUserID,
 		TopicId:  1,
 	}
-	id, _ := models.AddComment(comment)
+	id, _ := models.AddComment(context.Background(), comment)
 
 	// Тест с пустым содержимым
 	updated := models.Comment{
@@ -209,7 +224,7 @@ func TestPutComment_InvalidData(t *testing.T) {
 		AuthorId: testUserID,
 		TopicId:  1,
 	}
-	_, err := models.PutComment(id, updated)
+	_, err := models.PutComment(context.Background(), id, updated)
 	if err == nil {
 		t.Error("PutComment не вернул ошибку для пустого содержимого")
 	}
@@ -220,7 +235,7 @@ func TestPutComment_InvalidData(t *testing.T) {
 		AuthorId: 999,
 		TopicId:  1,
 	}
-	_, err = models.PutComment(id, updated)
+	_, err = models.PutComment(context.Background(), id, updated)
 	if err == nil {
 		t.Error("PutComment не вернул ошибку для несуществующего автора")
 	}
@@ -231,8 +246,117 @@ func TestPutComment_InvalidData(t *testing.T) {
 		AuthorId: testUserID,
 		TopicId:  999,
 	}
-	_, err = models.PutComment(id, updated)
+	_, err = models.PutComment(context.Background(), id, updated)
 	if err == nil {
 		t.Error("PutComment не вернул ошибку для несуществующего топика")
 	}
 }
+
+func TestCommentReplies(t *testing.T) {
+	setupTestDB(t)
+	defer teardownTestDB(t)
+	clearTestDB(t)
+
+	topicID, err := models.AddTopic(context.Background(), &models.Topic{Title: "Thread Topic", AuthorId: testUserID})
+	assert.NoError(t, err)
+
+	rootID, err := models.AddComment(context.Background(), &models.Comment{Content: "root", AuthorId: testUserID, TopicId: topicID})
+	assert.NoError(t, err)
+	replyID, err := models.AddComment(context.Background(), &models.Comment{Content: "reply", AuthorId: testUserID, TopicId: topicID, ParentId: &rootID})
+	assert.NoError(t, err)
+	_, err = models.AddComment(context.Background(), &models.Comment{Content: "nested", AuthorId: testUserID, TopicId: topicID, ParentId: &replyID})
+	assert.NoError(t, err)
+	_, err = models.AddComment(context.Background(), &models.Comment{Content: "second root", AuthorId: testUserID, TopicId: topicID})
+	assert.NoError(t, err)
+
+	// Плоский список в порядке ветки
+	t.Run("GetCommentsByTopicID", func(t *testing.T) {
+		comments, err := models.GetCommentsByTopicID(context.Background(), topicID)
+		assert.NoError(t, err)
+		assert.Len(t, comments, 4)
+
+		var contents []string
+		var depths []int
+		for _, c := range comments {
+			contents = append(contents, c.Content)
+			depths = append(depths, c.Depth)
+		}
+		assert.Equal(t, []string{"root", "reply", "nested", "second root"}, contents)
+		assert.Equal(t, []int{0, 1, 2, 0}, depths)
+	})
+
+	// Дерево комментариев
+	t.Run("GetCommentTreeByTopicID", func(t *testing.T) {
+		tree, err := models.GetCommentTreeByTopicID(context.Background(), topicID)
+		assert.NoError(t, err)
+		assert.Len(t, tree, 2)
+		assert.Len(t, tree[0].Replies, 1)
+		assert.Len(t, tree[0].Replies[0].Replies, 1)
+		assert.Empty(t, tree[1].Replies)
+	})
+
+	// Ответы на комментарий
+	t.Run("GetRepliesByCommentID", func(t *testing.T) {
+		replies, err := models.GetRepliesByCommentID(context.Background(), rootID)
+		assert.NoError(t, err)
+		assert.Len(t, replies, 2)
+		assert.Equal(t, 1, replies[0].Depth)
+		assert.Equal(t, 2, replies[1].Depth)
+	})
+
+	t.Run("GetCommentDepth", func(t *testing.T) {
+		depth, err := models.GetCommentDepth(context.Background(), replyID)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, depth)
+	})
+
+	// Ответ должен быть в том же топике, что и родитель
+	t.Run("AddComment_ParentInOtherTopic", func(t *testing.T) {
+		otherTopicID, err := models.AddTopic(context.Background(), &models.Topic{Title: "Other Topic", AuthorId: testUserID})
+		assert.NoError(t, err)
+
+		_, err = models.AddComment(context.Background(), &models.Comment{Content: "wrong", AuthorId: testUserID, TopicId: otherTopicID, ParentId: &rootID})
+		assert.Error(t, err)
+	})
+	// Удаление комментария не удаляет ответы: прямые ответы становятся корневыми
+	t.Run("DeleteKeepsReplies", func(t *testing.T) {
+		replyIDs, err := models.DeleteCommentByID(context.Background(), rootID)
+		assert.NoError(t, err)
+		assert.Equal(t, []int{replyID}, replyIDs)
+
+		comments, err := models.GetCommentsByTopicID(context.Background(), topicID)
+		assert.NoError(t, err)
+		var contents []string
+		for _, c := range comments {
+			contents = append(contents, c.Content)
+		}
+		assert.ElementsMatch(t, []string{"reply", "nested", "second root"}, contents)
+
+		reply, err := models.GetCommentByID(context.Background(), replyID)
+		assert.NoError(t, err)
+		assert.Nil(t, reply.ParentId)
+	})
+}
+
+func TestBuildCommentTree(t *testing.T) {
+	parent := func(id int) *int { return &id }
+
+	comments := []models.CommentWithUsername{
+		{ID: 1, Content: "root"},
+		{ID: 2, Content: "reply", ParentId: parent(1), Depth: 1},
+		{ID: 3, Content: "nested", ParentId: parent(2), Depth: 2},
+		{ID: 4, Content: "orphan", ParentId: parent(100), Depth: 1},
+	}
+
+	tree := models.BuildCommentTree(comments)
+
+	// Комментарий без родителя в списке становится корнем
+	assert.Len(t, tree, 2)
+	assert.Equal(t, 1, tree[0].ID)
+	assert.Equal(t, 4, tree[1].ID)
+	assert.Len(t, tree[0].Replies, 1)
+	assert.Equal(t, 2, tree[0].Replies[0].ID)
+	assert.Equal(t, 3, tree[0].Replies[0].Replies[0].ID)
+	assert.NotNil(t, tree[1].Replies)
+	assert.Empty(t, models.BuildCommentTree(nil))
+}
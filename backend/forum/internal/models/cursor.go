@@ -0,0 +1,47 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrInvalidCursor возвращается, если курсор поврежден или не подходит к запрошенной выдаче
+var ErrInvalidCursor = errors.New("некорректный курсор")
+
+// Cursor - позиция в выдаче для keyset-пагинации.
+// Клиенту передается в виде непрозрачной строки
+type Cursor struct {
+	Sort  string     `json:"s,omitempty"`
+	ID    int        `json:"id"`
+	Time  *time.Time `json:"t,omitempty"`
+	Count int        `json:"n,omitempty"`
+	// Rank и Type - позиция в результатах поиска, где ID топика и комментария могут совпасть
+	Rank *float64 `json:"r,omitempty"`
+	Type string   `json:"k,omitempty"`
+}
+
+// Encode кодирует курсор в строку для ответа клиенту
+func (c Cursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor разбирает строку курсора, полученную от клиента
+func DecodeCursor(s string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+
+	var c Cursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	if c.ID <= 0 {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
@@ -0,0 +1,35 @@
+package models_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/HedgeHogSE/forum/backend/forum/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCursorEncodeDecode(t *testing.T) {
+	ts := time.Date(2025, 5, 1, 12, 30, 0, 123456000, time.UTC)
+	cursor := models.Cursor{Sort: "active", ID: 42, Time: &ts}
+
+	decoded, err := models.DecodeCursor(cursor.Encode())
+	assert.NoError(t, err)
+	assert.Equal(t, "active", decoded.Sort)
+	assert.Equal(t, 42, decoded.ID)
+	assert.True(t, ts.Equal(*decoded.Time))
+
+	// Курсор по количеству комментариев
+	cursor = models.Cursor{Sort: "comments", ID: 7, Count: 15}
+	decoded, err = models.DecodeCursor(cursor.Encode())
+	assert.NoError(t, err)
+	assert.Equal(t, 15, decoded.Count)
+	assert.Nil(t, decoded.Time)
+}
+
+func TestDecodeCursor_Invalid(t *testing.T) {
+	for _, s := range []string{"not base64!", "bm90IGpzb24", "e30"} {
+		_, err := models.DecodeCursor(s)
+		assert.True(t, errors.Is(err, models.ErrInvalidCursor), s)
+	}
+}
@@ -0,0 +1,230 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/HedgeHogSE/forum/backend/forum/internal/db"
+	"github.com/lib/pq"
+)
+
+// RetentionAction - что делать с комментариями старше срока политики
+type RetentionAction string
+
+const (
+	RetentionKeep    RetentionAction = "keep"
+	RetentionArchive RetentionAction = "archive"
+	RetentionDelete  RetentionAction = "delete"
+)
+
+// ErrInvalidPolicy возвращается при некорректных параметрах политики хранения
+var ErrInvalidPolicy = errors.New("некорректная политика хранения")
+
+// RetentionPolicy - политика хранения комментариев топика.
+// Политика без TopicId глобальная и действует для топиков без собственной политики
+type RetentionPolicy struct {
+	ID        int             `json:"id"`
+	TopicId   *int            `json:"topic_id"`
+	Action    RetentionAction `json:"action"`
+	AfterDays int             `json:"after_days"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+// Validate проверяет действие и срок политики
+func (p RetentionPolicy) Validate() error {
+	switch p.Action {
+	case RetentionKeep:
+		return nil
+	case RetentionArchive, RetentionDelete:
+		if p.AfterDays <= 0 {
+			return fmt.Errorf("%w: срок должен быть больше нуля", ErrInvalidPolicy)
+		}
+		return nil
+	default:
+		return fmt.Errorf("%w: неизвестное действие %q", ErrInvalidPolicy, p.Action)
+	}
+}
+
+// Cutoff возвращает момент, раньше которого комментарии подпадают под политику
+func (p RetentionPolicy) Cutoff(now time.Time) time.Time {
+	return now.AddDate(0, 0, -p.AfterDays)
+}
+
+const retentionColumns = "id, topic_id, action, after_days, created_at, updated_at"
+
+func scanRetentionPolicy(row interface{ Scan(...any) error }) (RetentionPolicy, error) {
+	var p RetentionPolicy
+	var topicID sql.NullInt64
+	err := row.Scan(&p.ID, &topicID, &p.Action, &p.AfterDays, &p.CreatedAt, &p.UpdatedAt)
+	if topicID.Valid {
+		id := int(topicID.Int64)
+		p.TopicId = &id
+	}
+	return p, err
+}
+
+// GetRetentionPolicies возвращает все политики хранения, глобальная - первой
+func GetRetentionPolicies(ctx context.Context) ([]RetentionPolicy, error) {
+	rows, err := db.Db.QueryContext(ctx, `SELECT `+retentionColumns+` FROM retention_policies ORDER BY topic_id NULLS FIRST`)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить политики хранения: %w", err)
+	}
+	defer rows.Close()
+
+	var policies []RetentionPolicy
+	for rows.Next() {
+		p, err := scanRetentionPolicy(rows)
+		if err != nil {
+			return nil, fmt.Errorf("не удалось прочитать политику хранения: %w", err)
+		}
+		policies = append(policies, p)
+	}
+	return policies, rows.Err()
+}
+
+// GetRetentionPolicy возвращает политику топика или глобальную, если topicID равен nil.
+// Если политика не задана, возвращается sql.ErrNoRows
+func GetRetentionPolicy(ctx context.Context, topicID *int) (RetentionPolicy, error) {
+	row := db.Db.QueryRowContext(ctx, `SELECT `+retentionColumns+` FROM retention_policies WHERE topic_id IS NOT DISTINCT FROM $1`, topicID)
+	p, err := scanRetentionPolicy(row)
+	if err != nil {
+		return RetentionPolicy{}, fmt.Errorf("не удалось получить политику хранения: %w", err)
+	}
+	return p, nil
+}
+
+// SetRetentionPolicy создает или заменяет политику топика (или глобальную)
+func SetRetentionPolicy(ctx context.Context, p RetentionPolicy) (RetentionPolicy, error) {
+	if err := p.Validate(); err != nil {
+		return RetentionPolicy{}, err
+	}
+
+	// ON CONFLICT не срабатывает на NULL, поэтому сначала пробуем обновить
+	row := db.Db.QueryRowContext(ctx, `
+		UPDATE retention_policies
+		SET action = $2, after_days = $3, updated_at = CURRENT_TIMESTAMP
+		WHERE topic_id IS NOT DISTINCT FROM $1
+		RETURNING `+retentionColumns, p.TopicId, p.Action, p.AfterDays)
+	saved, err := scanRetentionPolicy(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		row = db.Db.QueryRowContext(ctx, `
+			INSERT INTO retention_policies (topic_id, action, after_days)
+			VALUES ($1, $2, $3)
+			RETURNING `+retentionColumns, p.TopicId, p.Action, p.AfterDays)
+		saved, err = scanRetentionPolicy(row)
+	}
+	if err != nil {
+		return RetentionPolicy{}, fmt.Errorf("не удалось сохранить политику хранения: %w", err)
+	}
+	return saved, nil
+}
+
+// DeleteRetentionPolicy удаляет политику топика (или глобальную)
+func DeleteRetentionPolicy(ctx context.Context, topicID *int) error {
+	res, err := db.Db.ExecContext(ctx, `DELETE FROM retention_policies WHERE topic_id IS NOT DISTINCT FROM $1`, topicID)
+	if err != nil {
+		return fmt.Errorf("не удалось удалить политику хранения: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("не удалось удалить политику хранения: %w", err)
+	}
+	if n == 0 {
+		return fmt.Errorf("политика хранения не найдена: %w", sql.ErrNoRows)
+	}
+	return nil
+}
+
+// GetExpiredCommentIDs возвращает ID комментариев, подпадающих под политику на момент cutoff.
+// Для глобальной политики (topicID равен nil) учитываются топики без собственной политики.
+// Комментарий выбирается, только если вся его ветка ответов старше cutoff:
+// иначе каскадное удаление затронуло бы свежие ответы
+func GetExpiredCommentIDs(ctx context.Context, topicID *int, cutoff time.Time) ([]int, error) {
+	rows, err := db.Db.QueryContext(ctx, `
+		WITH RECURSIVE scoped AS (
+			SELECT id, parent_id, created_at
+			FROM comments
+			WHERE ($1::int IS NOT NULL AND topic_id = $1)
+			   OR ($1::int IS NULL AND topic_id NOT IN (
+					SELECT topic_id FROM retention_policies WHERE topic_id IS NOT NULL))
+		), subtree AS (
+			SELECT id AS root_id, id, created_at
+			FROM scoped
+			WHERE created_at < $2
+			UNION ALL
+			SELECT s.root_id, c.id, c.created_at
+			FROM scoped c
+			JOIN subtree s ON c.parent_id = s.id
+		)
+		SELECT root_id
+		FROM subtree
+		GROUP BY root_id
+		HAVING max(created_at) < $2
+		ORDER BY root_id
+	`, topicID, cutoff)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось выбрать устаревшие комментарии: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("не удалось прочитать ID комментария: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// ArchiveComments переносит комментарии в comments_archive одной транзакцией
+func ArchiveComments(ctx context.Context, ids []int) (int64, error) {
+	tx, err := db.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("не удалось начать транзакцию: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO comments_archive (id, content, author_id, topic_id, parent_id, created_at, updated_at)
+		SELECT id, content, author_id, topic_id, parent_id, created_at, updated_at
+		FROM comments
+		WHERE id = ANY($1)
+		ON CONFLICT (id) DO NOTHING
+	`, pq.Array(ids))
+	if err != nil {
+		return 0, fmt.Errorf("не удалось архивировать комментарии: %w", err)
+	}
+
+	res, err := tx.ExecContext(ctx, `DELETE FROM comments WHERE id = ANY($1)`, pq.Array(ids))
+	if err != nil {
+		return 0, fmt.Errorf("не удалось удалить архивированные комментарии: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("не удалось удалить архивированные комментарии: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("не удалось завершить транзакцию: %w", err)
+	}
+	return n, nil
+}
+
+// DeleteComments удаляет комментарии без архивации
+func DeleteComments(ctx context.Context, ids []int) (int64, error) {
+	res, err := db.Db.ExecContext(ctx, `DELETE FROM comments WHERE id = ANY($1)`, pq.Array(ids))
+	if err != nil {
+		return 0, fmt.Errorf("не удалось удалить комментарии: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("не удалось удалить комментарии: %w", err)
+	}
+	return n, nil
+}
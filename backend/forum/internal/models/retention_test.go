@@ -0,0 +1,115 @@
+package models_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/HedgeHogSE/forum/backend/forum/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetentionPolicyValidate(t *testing.T) {
+	assert.NoError(t, models.RetentionPolicy{Action: models.RetentionKeep}.Validate())
+	assert.NoError(t, models.RetentionPolicy{Action: models.RetentionArchive, AfterDays: 30}.Validate())
+
+	// Для архивации и удаления срок обязателен
+	assert.ErrorIs(t, models.RetentionPolicy{Action: models.RetentionDelete}.Validate(), models.ErrInvalidPolicy)
+	assert.ErrorIs(t, models.RetentionPolicy{Action: "purge", AfterDays: 1}.Validate(), models.ErrInvalidPolicy)
+}
+
+// addCommentAt создает комментарий с заданным временем создания
+func addCommentAt(t *testing.T, topicID int, parentID *int, createdAt time.Time) int {
+	id, err := models.AddComment(context.Background(), &models.Comment{
+		Content:  "comment",
+		TopicId:  topicID,
+		AuthorId: testUserID,
+		ParentId: parentID,
+	})
+	require.NoError(t, err)
+	_, err = testDB.Exec("UPDATE comments SET created_at = $1 WHERE id = $2", createdAt, id)
+	require.NoError(t, err)
+	return id
+}
+
+func TestRetention(t *testing.T) {
+	setupTestDB(t)
+	defer teardownTestDB(t)
+
+	clearTestDB(t)
+	_, err := testDB.Exec("TRUNCATE TABLE comments_archive, retention_policies")
+	require.NoError(t, err)
+
+	topicID, err := models.AddTopic(context.Background(), &models.Topic{Title: "Topic", AuthorId: testUserID})
+	require.NoError(t, err)
+	otherTopicID, err := models.AddTopic(context.Background(), &models.Topic{Title: "Other", AuthorId: testUserID})
+	require.NoError(t, err)
+
+	now := time.Now()
+	old := now.AddDate(0, 0, -40)
+
+	// Старая ветка целиком, старый комментарий со свежим ответом и свежий комментарий
+	oldRoot := addCommentAt(t, topicID, nil, old)
+	oldReply := addCommentAt(t, topicID, &oldRoot, old)
+	keptRoot := addCommentAt(t, topicID, nil, old)
+	addCommentAt(t, topicID, &keptRoot, now)
+	addCommentAt(t, topicID, nil, now)
+	otherOld := addCommentAt(t, otherTopicID, nil, old)
+
+	t.Run("Policies", func(t *testing.T) {
+		_, err := models.SetRetentionPolicy(context.Background(), models.RetentionPolicy{Action: models.RetentionDelete, AfterDays: 30})
+		require.NoError(t, err)
+		policy, err := models.SetRetentionPolicy(context.Background(), models.RetentionPolicy{TopicId: &topicID, Action: models.RetentionKeep})
+		require.NoError(t, err)
+		assert.Equal(t, topicID, *policy.TopicId)
+
+		// Повторное сохранение заменяет политику
+		policy, err = models.SetRetentionPolicy(context.Background(), models.RetentionPolicy{TopicId: &topicID, Action: models.RetentionArchive, AfterDays: 30})
+		require.NoError(t, err)
+		assert.Equal(t, models.RetentionArchive, policy.Action)
+
+		policies, err := models.GetRetentionPolicies(context.Background())
+		require.NoError(t, err)
+		require.Len(t, policies, 2)
+		assert.Nil(t, policies[0].TopicId)
+
+		_, err = models.SetRetentionPolicy(context.Background(), models.RetentionPolicy{Action: models.RetentionDelete})
+		assert.ErrorIs(t, err, models.ErrInvalidPolicy)
+	})
+
+	t.Run("GetExpiredCommentIDs", func(t *testing.T) {
+		cutoff := now.AddDate(0, 0, -30)
+
+		// Комментарий со свежим ответом не выбирается
+		ids, err := models.GetExpiredCommentIDs(context.Background(), &topicID, cutoff)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []int{oldRoot, oldReply}, ids)
+
+		// Глобальная политика не затрагивает топики с собственной политикой
+		ids, err = models.GetExpiredCommentIDs(context.Background(), nil, cutoff)
+		require.NoError(t, err)
+		assert.Equal(t, []int{otherOld}, ids)
+	})
+
+	t.Run("ArchiveComments", func(t *testing.T) {
+		n, err := models.ArchiveComments(context.Background(), []int{oldRoot, oldReply})
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), n)
+
+		_, err = models.GetCommentByID(context.Background(), oldRoot)
+		assert.Error(t, err)
+
+		var archived int
+		require.NoError(t, testDB.QueryRow("SELECT count(*) FROM comments_archive WHERE topic_id = $1", topicID).Scan(&archived))
+		assert.Equal(t, 2, archived)
+	})
+
+	t.Run("DeleteRetentionPolicy", func(t *testing.T) {
+		require.NoError(t, models.DeleteRetentionPolicy(context.Background(), &topicID))
+
+		err := models.DeleteRetentionPolicy(context.Background(), &topicID)
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+	})
+}
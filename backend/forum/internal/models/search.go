@@ -0,0 +1,133 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/HedgeHogSE/forum/backend/forum/internal/db"
+)
+
+const (
+	// SearchTypeTopic - результат поиска по топику
+	SearchTypeTopic = "topic"
+	// SearchTypeComment - результат поиска по комментарию
+	SearchTypeComment = "comment"
+)
+
+// SearchParams - параметры полнотекстового поиска
+type SearchParams struct {
+	Query    string
+	Type     string
+	AuthorId int
+	From     *time.Time
+	To       *time.Time
+	Limit    int
+	// After - курсор последнего результата предыдущей страницы, nil для первой страницы
+	After *Cursor
+}
+
+// SearchResult - найденный топик или комментарий.
+// Snippet экранирован для HTML, совпадения выделены тегом <mark>
+type SearchResult struct {
+	Type      string    `json:"type"`
+	ID        int       `json:"id"`
+	TopicId   int       `json:"topic_id"`
+	AuthorId  int       `json:"author_id"`
+	Title     string    `json:"title"`
+	Snippet   string    `json:"snippet"`
+	Rank      float64   `json:"rank"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// searchCursorSort - режим сортировки в курсоре поиска
+const searchCursorSort = "rank"
+
+// Cursor возвращает курсор, указывающий на этот результат в выдаче поиска
+func (r SearchResult) Cursor() Cursor {
+	return Cursor{Sort: searchCursorSort, ID: r.ID, Time: &r.CreatedAt, Rank: &r.Rank, Type: r.Type}
+}
+
+// Search ищет по заголовкам и описаниям топиков и по тексту комментариев.
+// Результаты отсортированы по релевантности, страница начинается после курсора p.After
+func Search(ctx context.Context, p SearchParams) ([]SearchResult, error) {
+	if p.Query == "" {
+		return nil, fmt.Errorf("пустой поисковый запрос")
+	}
+	if p.Type != "" && p.Type != SearchTypeTopic && p.Type != SearchTypeComment {
+		return nil, fmt.Errorf("неизвестный тип результата: %s", p.Type)
+	}
+
+	var afterRank, afterTime, afterID, afterType interface{}
+	if a := p.After; a != nil {
+		if a.Sort != searchCursorSort || a.Rank == nil || a.Time == nil ||
+			(a.Type != SearchTypeTopic && a.Type != SearchTypeComment) {
+			return nil, fmt.Errorf("%w: курсор не относится к результатам поиска", ErrInvalidCursor)
+		}
+		afterRank, afterTime, afterID, afterType = *a.Rank, *a.Time, a.ID, a.Type
+	}
+
+	// Сниппет строится только для уже отобранной страницы:
+	// ts_headline заметно дороже, чем ранжирование по индексу
+	query := `
+		WITH q AS (
+			SELECT websearch_to_tsquery('simple', $1) AS query
+		),
+		matches AS (
+			SELECT 'topic' AS type, t.id, t.id AS topic_id, t.author_id, t.title,
+				coalesce(t.title, '') || ' ' || coalesce(t.description, '') AS body,
+				ts_rank(t.search_vector, q.query) AS rank, t.created_at
+			FROM topics t, q
+			WHERE t.search_vector @@ q.query
+			UNION ALL
+			SELECT 'comment' AS type, c.id, c.topic_id, c.author_id, t.title,
+				c.content AS body,
+				ts_rank(c.search_vector, q.query) AS rank, c.created_at
+			FROM comments c
+			JOIN topics t ON t.id = c.topic_id, q
+			WHERE c.search_vector @@ q.query
+		),
+		page AS (
+			SELECT * FROM matches
+			WHERE ($2 = '' OR type = $2)
+				AND ($3 = 0 OR author_id = $3)
+				AND ($4::timestamptz IS NULL OR created_at >= $4)
+				AND ($5::timestamptz IS NULL OR created_at < $5)
+				AND ($7::real IS NULL OR
+					(rank, created_at, id, type) < ($7::real, $8::timestamptz, $9::integer, $10::text))
+			ORDER BY rank DESC, created_at DESC, id DESC, type DESC
+			LIMIT $6
+		)
+		SELECT page.type, page.id, page.topic_id, page.author_id, page.title,
+			ts_headline('simple',
+				replace(replace(replace(page.body, '&', '&amp;'), '<', '&lt;'), '>', '&gt;'),
+				q.query,
+				'StartSel=<mark>, StopSel=</mark>, MaxWords=35, MinWords=15, MaxFragments=2'),
+			page.rank, page.created_at
+		FROM page, q
+		ORDER BY page.rank DESC, page.created_at DESC, page.id DESC, page.type DESC
+	`
+
+	rows, err := db.Db.QueryContext(ctx, query, p.Query, p.Type, p.AuthorId, p.From, p.To, p.Limit,
+		afterRank, afterTime, afterID, afterType)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось выполнить поиск: %w", err)
+	}
+	defer rows.Close()
+
+	results := make([]SearchResult, 0, p.Limit)
+	for rows.Next() {
+		var r SearchResult
+		err := rows.Scan(&r.Type, &r.ID, &r.TopicId, &r.AuthorId, &r.Title,
+			&r.Snippet, &r.Rank, &r.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка при сканировании строки: %w", err)
+		}
+		results = append(results, r)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
@@ -0,0 +1,83 @@
+package models_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/HedgeHogSE/forum/backend/forum/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearch(t *testing.T) {
+	setupTestDB(t)
+	defer teardownTestDB(t)
+	clearTestDB(t)
+
+	topicID, err := models.AddTopic(context.Background(), &models.Topic{
+		Title:       "Postgres indexes",
+		Description: sql.NullString{String: "How do GIN indexes work?", Valid: true},
+		AuthorId:    testUserID,
+	})
+	assert.NoError(t, err)
+	_, err = models.AddComment(context.Background(), &models.Comment{Content: "GIN is great for <b>tsvector</b> columns", AuthorId: testUserID, TopicId: topicID})
+	assert.NoError(t, err)
+	_, err = models.AddComment(context.Background(), &models.Comment{Content: "Unrelated comment", AuthorId: testUserID, TopicId: topicID})
+	assert.NoError(t, err)
+
+	// Топик и комментарий находятся, сниппет экранирован и подсвечен
+	t.Run("TopicsAndComments", func(t *testing.T) {
+		results, err := models.Search(context.Background(), models.SearchParams{Query: "gin", Limit: 10})
+		assert.NoError(t, err)
+		assert.Len(t, results, 2)
+
+		for _, r := range results {
+			assert.Equal(t, topicID, r.TopicId)
+			assert.Contains(t, r.Snippet, "<mark>")
+			assert.NotContains(t, r.Snippet, "<b>")
+		}
+	})
+
+	t.Run("TypeFilter", func(t *testing.T) {
+		results, err := models.Search(context.Background(), models.SearchParams{Query: "gin", Type: models.SearchTypeComment, Limit: 10})
+		assert.NoError(t, err)
+		assert.Len(t, results, 1)
+		assert.Equal(t, models.SearchTypeComment, results[0].Type)
+	})
+
+	t.Run("AuthorFilter", func(t *testing.T) {
+		results, err := models.Search(context.Background(), models.SearchParams{Query: "gin", AuthorId: testUserID + 1000, Limit: 10})
+		assert.NoError(t, err)
+		assert.Empty(t, results)
+	})
+
+	t.Run("DateRange", func(t *testing.T) {
+		from := time.Now().Add(time.Hour)
+		results, err := models.Search(context.Background(), models.SearchParams{Query: "gin", From: &from, Limit: 10})
+		assert.NoError(t, err)
+		assert.Empty(t, results)
+	})
+
+	// Страницы по курсору не пересекаются и вместе дают полную выдачу
+	t.Run("Cursor", func(t *testing.T) {
+		all, err := models.Search(context.Background(), models.SearchParams{Query: "gin", Limit: 10})
+		assert.NoError(t, err)
+
+		first, err := models.Search(context.Background(), models.SearchParams{Query: "gin", Limit: 1})
+		assert.NoError(t, err)
+		assert.Len(t, first, 1)
+		after := first[0].Cursor()
+		second, err := models.Search(context.Background(), models.SearchParams{Query: "gin", Limit: 10, After: &after})
+		assert.NoError(t, err)
+		assert.Equal(t, all, append(first, second...))
+
+		_, err = models.Search(context.Background(), models.SearchParams{Query: "gin", Limit: 10, After: &models.Cursor{Sort: "active", ID: 1}})
+		assert.ErrorIs(t, err, models.ErrInvalidCursor)
+	})
+
+	t.Run("EmptyQuery", func(t *testing.T) {
+		_, err := models.Search(context.Background(), models.SearchParams{Limit: 10})
+		assert.Error(t, err)
+	})
+}
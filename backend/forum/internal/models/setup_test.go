@@ -1,6 +1,7 @@
 package models_test
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"os"
@@ -17,13 +18,23 @@ var testUserID int
 // Мок для внешнего сервиса
 type mockExternalService struct{}
 
-func (m *mockExternalService) GetUsernameByUserID(userID int) (string, error) {
+func (m *mockExternalService) GetUsernameByUserID(ctx context.Context, userID int) (string, error) {
 	if userID == testUserID {
 		return "test_user", nil
 	}
 	return "", fmt.Errorf("user not found")
 }
 
+func (m *mockExternalService) GetUsernamesByUserIDs(ctx context.Context, userIDs []int) (map[int]string, error) {
+	names := make(map[int]string)
+	for _, id := range userIDs {
+		if id == testUserID {
+			names[id] = "test_user"
+		}
+	}
+	return names, nil
+}
+
 func setupTestDB(t *testing.T) {
 	// Сохраняем оригинальные значения переменных окружения
 	originalEnv := make(map[string]string)
@@ -76,6 +87,7 @@ func setupTestDB(t *testing.T) {
 
 	// Устанавливаем мок для внешнего сервиса
 	external.GetUsernameByUserID = (&mockExternalService{}).GetUsernameByUserID
+	external.GetUsernamesByUserIDs = (&mockExternalService{}).GetUsernamesByUserIDs
 }
 
 func createTestUser(t *testing.T) {
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"log"
@@ -18,10 +19,12 @@ type Topic struct {
 	UpdatedAt   time.Time      `json:"updated_at"`
 }
 
-func GetAllTopics() []Topic {
+func GetAllTopics(ctx context.Context) []Topic {
 	var topics []Topic
 
-	rows, err := db.Db.Query("SELECT * FROM topics")
+	rows, err := db.Db.QueryContext(ctx, `
+		SELECT id, title, description, author_id, created_at, updated_at
+		FROM topics`)
 	if err != nil {
 		log.Fatal("Ошибка при выполнении запроса:", err)
 	}
@@ -45,9 +48,11 @@ func GetAllTopics() []Topic {
 	return topics
 }
 
-func GetTopicByID(id int) (*Topic, error) {
+func GetTopicByID(ctx context.Context, id int) (*Topic, error) {
 	var t Topic
-	err := db.Db.QueryRow("SELECT * FROM topics WHERE id = $1", id).
+	err := db.Db.QueryRowContext(ctx, `
+		SELECT id, title, description, author_id, created_at, updated_at
+		FROM topics WHERE id = $1`, id).
 		Scan(&t.ID, &t.Title, &t.Description,
 			&t.AuthorId, &t.CreatedAt, &t.UpdatedAt)
 
@@ -58,7 +63,7 @@ func GetTopicByID(id int) (*Topic, error) {
 	return &t, nil
 }
 
-func AddTopic(t *Topic) (int, error) {
+func AddTopic(ctx context.Context, t *Topic) (int, error) {
 	if t.Title == "" {
 		return 0, fmt.Errorf("заголовок не может быть пустым")
 	}
@@ -70,16 +75,16 @@ func AddTopic(t *Topic) (int, error) {
 		RETURNING id;
 	`
 
-	err := db.Db.QueryRow(query, t.Title, t.Description, t.AuthorId).Scan(&id)
+	err := db.Db.QueryRowContext(ctx, query, t.Title, t.Description, t.AuthorId).Scan(&id)
 	if err != nil {
 		return 0, fmt.Errorf("не удалось добавить топик: %w", err)
 	}
 	return id, nil
 }
 
-func DeleteTopicByID(id int) error {
+func DeleteTopicByID(ctx context.Context, id int) error {
 	query := `DELETE FROM topics WHERE id = $1`
-	result, err := db.Db.Exec(query, id)
+	result, err := db.Db.ExecContext(ctx, query, id)
 	if err != nil {
 		return fmt.Errorf("не удалось удалить топик: %w", err)
 	}
@@ -96,24 +101,134 @@ func DeleteTopicByID(id int) error {
 	return nil
 }
 
-func PutTopic(id int, updated *Topic) (Topic, error) {
+// PutTopic меняет заголовок и описание топика. Правка считается активностью в топике:
+// триггер topics_set_activity обновляет last_activity_at по updated_at
+func PutTopic(ctx context.Context, id int, updated *Topic) (Topic, error) {
 	if updated.Title == "" {
 		return Topic{}, fmt.Errorf("заголовок не может быть пустым")
 	}
 
 	query := `
 		UPDATE topics 
-		SET title = $1, description = $2
+		SET title = $1, description = $2, updated_at = CURRENT_TIMESTAMP
 		WHERE id = $3
 		RETURNING id, title, description, author_id, created_at, updated_at
 	`
 	log.Println(updated.Title)
 	log.Println(updated.Description.String)
 	var t Topic
-	err := db.Db.QueryRow(query, updated.Title, updated.Description.String, id).Scan(
+	err := db.Db.QueryRowContext(ctx, query, updated.Title, updated.Description.String, id).Scan(
 		&t.ID, &t.Title, &t.Description.String, &t.AuthorId, &t.CreatedAt, &t.UpdatedAt)
 	if err != nil {
 		return Topic{}, fmt.Errorf("не удалось обновить топик: %w", err)
 	}
 	return t, nil
 }
+
+// TopicSort - режим сортировки списка топиков
+type TopicSort string
+
+const (
+	// TopicSortNewest - сначала новые топики
+	TopicSortNewest TopicSort = "newest"
+	// TopicSortActive - сначала топики с самой свежей активностью
+	TopicSortActive TopicSort = "active"
+	// TopicSortComments - сначала самые обсуждаемые топики
+	TopicSortComments TopicSort = "comments"
+)
+
+// ParseTopicSort проверяет режим сортировки, пустая строка означает TopicSortNewest
+func ParseTopicSort(s string) (TopicSort, error) {
+	switch TopicSort(s) {
+	case "", TopicSortNewest:
+		return TopicSortNewest, nil
+	case TopicSortActive, TopicSortComments:
+		return TopicSort(s), nil
+	}
+	return "", fmt.Errorf("неизвестный режим сортировки: %s", s)
+}
+
+// TopicListItem - топик в списке вместе с данными для сортировки
+type TopicListItem struct {
+	Topic
+	CommentsCount  int       `json:"comments_count"`
+	LastActivityAt time.Time `json:"last_activity_at"`
+}
+
+// Cursor возвращает курсор, указывающий на этот топик в выдаче с заданной сортировкой
+func (t TopicListItem) Cursor(sort TopicSort) Cursor {
+	c := Cursor{Sort: string(sort), ID: t.ID}
+	switch sort {
+	case TopicSortActive:
+		c.Time = &t.LastActivityAt
+	case TopicSortComments:
+		c.Count = t.CommentsCount
+	default:
+		c.Time = &t.CreatedAt
+	}
+	return c
+}
+
+// ListTopics возвращает страницу топиков в порядке sort, начиная после курсора after.
+// Если after равен nil, возвращается первая страница
+func ListTopics(ctx context.Context, sort TopicSort, after *Cursor, limit int) ([]TopicListItem, error) {
+	var sortKey string
+	switch sort {
+	case TopicSortActive:
+		sortKey = "last_activity_at"
+	case TopicSortComments:
+		sortKey = "comments_count"
+	default:
+		sortKey = "created_at"
+	}
+
+	args := []interface{}{limit}
+	where := ""
+	if after != nil {
+		if after.Sort != string(sort) {
+			return nil, fmt.Errorf("%w: курсор не соответствует режиму сортировки", ErrInvalidCursor)
+		}
+		var key interface{} = after.Count
+		if sort != TopicSortComments {
+			if after.Time == nil {
+				return nil, ErrInvalidCursor
+			}
+			key = *after.Time
+		}
+		args = append(args, key, after.ID)
+		where = fmt.Sprintf("WHERE (%s, id) < ($2, $3)", sortKey)
+	}
+
+	// comments_count и last_activity_at поддерживаются триггерами, поэтому страница
+	// читается по индексу сортировки
+	query := fmt.Sprintf(`
+		SELECT id, title, description, author_id, created_at, updated_at,
+			comments_count, last_activity_at
+		FROM topics
+		%s
+		ORDER BY %s DESC, id DESC
+		LIMIT $1
+	`, where, sortKey)
+
+	rows, err := db.Db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить список топиков: %w", err)
+	}
+	defer rows.Close()
+
+	topics := make([]TopicListItem, 0, limit)
+	for rows.Next() {
+		var t TopicListItem
+		err := rows.Scan(&t.ID, &t.Title, &t.Description, &t.AuthorId,
+			&t.CreatedAt, &t.UpdatedAt, &t.CommentsCount, &t.LastActivityAt)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка при сканировании строки: %w", err)
+		}
+		topics = append(topics, t)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return topics, nil
+}
@@ -1,6 +1,7 @@
 package models_test
 
 import (
+	"context"
 	"database/sql"
 	"testing"
 
@@ -25,7 +26,7 @@ func TestTopicCRUD(t *testing.T) {
 
 	// Тест AddTopic
 	t.Run("AddTopic", func(t *testing.T) {
-		id, err := models.AddTopic(topic)
+		id, err := models.AddTopic(context.Background(), topic)
 		assert.NoError(t, err)
 		assert.Greater(t, id, 0)
 		topic.ID = id
@@ -33,7 +34,7 @@ func TestTopicCRUD(t *testing.T) {
 
 	// Тест GetTopicByID
 	t.Run("GetTopicByID", func(t *testing.T) {
-		retrievedTopic, err := models.GetTopicByID(topic.ID)
+		retrievedTopic, err := models.GetTopicByID(context.Background(), topic.ID)
 		assert.NoError(t, err)
 		assert.Equal(t, topic.Title, retrievedTopic.Title)
 		assert.Equal(t, topic.Description.String, retrievedTopic.Description.String)
@@ -42,7 +43,7 @@ func TestTopicCRUD(t *testing.T) {
 
 	// Тест GetAllTopics
 	t.Run("GetAllTopics", func(t *testing.T) {
-		topics := models.GetAllTopics()
+		topics := models.GetAllTopics(context.Background())
 		assert.Len(t, topics, 1)
 		assert.Equal(t, topic.Title, topics[0].Title)
 		assert.Equal(t, topic.Description.String, topics[0].Description.String)
@@ -55,19 +56,20 @@ func TestTopicCRUD(t *testing.T) {
 			Description: sql.NullString{String: "Updated Description", Valid: true},
 		}
 
-		result, err := models.PutTopic(topic.ID, updatedTopic)
+		result, err := models.PutTopic(context.Background(), topic.ID, updatedTopic)
 		assert.NoError(t, err)
 		assert.Equal(t, updatedTopic.Title, result.Title)
 		assert.Equal(t, updatedTopic.Description.String, result.Description.String)
+		assert.True(t, result.UpdatedAt.After(result.CreatedAt), "updated_at должен обновиться")
 	})
 
 	// Тест DeleteTopicByID
 	t.Run("DeleteTopicByID", func(t *testing.T) {
-		err := models.DeleteTopicByID(topic.ID)
+		err := models.DeleteTopicByID(context.Background(), topic.ID)
 		assert.NoError(t, err)
 
 		// Проверяем, что топик удален
-		_, err = models.GetTopicByID(topic.ID)
+		_, err = models.GetTopicByID(context.Background(), topic.ID)
 		assert.Error(t, err)
 	})
 }
@@ -78,7 +80,7 @@ func TestGetTopicByID_NotFound(t *testing.T) {
 	clearTestDB(t)
 
 	// Пытаемся получить несуществующий топик
-	_, err := models.GetTopicByID(999)
+	_, err := models.GetTopicByID(context.Background(), 999)
 	assert.Error(t, err)
 }
 
@@ -93,7 +95,7 @@ func TestPutTopic_NotFound(t *testing.T) {
 		Description: sql.NullString{String: "Updated Description", Valid: true},
 	}
 
-	_, err := models.PutTopic(999, updatedTopic)
+	_, err := models.PutTopic(context.Background(), 999, updatedTopic)
 	assert.Error(t, err)
 }
 
@@ -103,7 +105,7 @@ func TestDeleteTopicByID_NotFound(t *testing.T) {
 	clearTestDB(t)
 
 	// Пытаемся удалить несуществующий топик
-	err := models.DeleteTopicByID(999)
+	err := models.DeleteTopicByID(context.Background(), 999)
 	assert.Error(t, err)
 }
 
@@ -117,7 +119,7 @@ func TestAddTopic_InvalidData(t *testing.T) {
 		Description: sql.NullString{String: "Test Description", Valid: true},
 		AuthorId:    testUserID,
 	}
-	_, err := models.AddTopic(topic)
+	_, err := models.AddTopic(context.Background(), topic)
 	if err == nil {
 		t.Error("AddTopic не вернул ошибку для пустого заголовка")
 	}
@@ -128,7 +130,7 @@ func TestAddTopic_InvalidData(t *testing.T) {
 		Description: sql.NullString{String: "Test Description", Valid: true},
 		AuthorId:    999,
 	}
-	_, err = models.AddTopic(topic)
+	_, err = models.AddTopic(context.Background(), topic)
 	if err == nil {
 		t.Error("AddTopic не вернул ошибку для несуществующего автора")
 	}
@@ -139,7 +141,7 @@ func TestAddTopic_InvalidData(t *testing.T) {
 		Description: sql.NullString{String: "", Valid: false},
 		AuthorId:    testUserID,
 	}
-	_, err = models.AddTopic(topic)
+	_, err = models.AddTopic(context.Background(), topic)
 	if err != nil {
 		t.Errorf("AddTopic вернул ошибку для пустого описания: %v", err)
 	}
@@ -155,14 +157,14 @@ func TestPutTopic_InvalidData(t *testing.T) {
 		Description: sql.NullString{String: "Test Description", Valid: true},
 		AuthorId:    testUserID,
 	}
-	id, _ := models.AddTopic(topic)
+	id, _ := models.AddTopic(context.Background(), topic)
 
 	// Тест с пустым заголовком
 	updated := &models.Topic{
 		Title:       "",
 		Description: sql.NullString{String: "Updated Description", Valid: true},
 	}
-	_, err := models.PutTopic(id, updated)
+	_, err := models.PutTopic(context.Background(), id, updated)
 	if err == nil {
 		t.Error("PutTopic не вернул ошибку для пустого заголовка")
 	}
@@ -172,8 +174,87 @@ func TestPutTopic_InvalidData(t *testing.T) {
 		Title:       "Updated Topic",
 		Description: sql.NullString{String: "", Valid: false},
 	}
-	_, err = models.PutTopic(id, updated)
+	_, err = models.PutTopic(context.Background(), id, updated)
 	if err != nil {
 		t.Errorf("PutTopic вернул ошибку для пустого описания: %v", err)
 	}
 }
+
+func TestListTopics(t *testing.T) {
+	setupTestDB(t)
+	defer teardownTestDB(t)
+	clearTestDB(t)
+
+	var ids []int
+	for _, title := range []string{"First", "Second", "Third"} {
+		id, err := models.AddTopic(context.Background(), &models.Topic{Title: title, AuthorId: testUserID})
+		assert.NoError(t, err)
+		ids = append(ids, id)
+	}
+
+	// Два комментария в первом топике, один во втором
+	var commentIDs []int
+	for _, topicID := range []int{ids[0], ids[0], ids[1]} {
+		id, err := models.AddComment(context.Background(), &models.Comment{Content: "comment", AuthorId: testUserID, TopicId: topicID})
+		assert.NoError(t, err)
+		commentIDs = append(commentIDs, id)
+	}
+
+	// Постраничный обход по новизне
+	t.Run("Newest", func(t *testing.T) {
+		page, err := models.ListTopics(context.Background(), models.TopicSortNewest, nil, 2)
+		assert.NoError(t, err)
+		assert.Len(t, page, 2)
+		assert.Equal(t, ids[2], page[0].ID)
+		assert.Equal(t, ids[1], page[1].ID)
+
+		cursor := page[1].Cursor(models.TopicSortNewest)
+		page, err = models.ListTopics(context.Background(), models.TopicSortNewest, &cursor, 2)
+		assert.NoError(t, err)
+		assert.Len(t, page, 1)
+		assert.Equal(t, ids[0], page[0].ID)
+	})
+
+	t.Run("MostCommented", func(t *testing.T) {
+		page, err := models.ListTopics(context.Background(), models.TopicSortComments, nil, 10)
+		assert.NoError(t, err)
+		assert.Len(t, page, 3)
+		assert.Equal(t, ids[0], page[0].ID)
+		assert.Equal(t, 2, page[0].CommentsCount)
+		assert.Equal(t, ids[1], page[1].ID)
+	})
+
+	t.Run("RecentlyActive", func(t *testing.T) {
+		page, err := models.ListTopics(context.Background(), models.TopicSortActive, nil, 1)
+		assert.NoError(t, err)
+		assert.Len(t, page, 1)
+		assert.Equal(t, ids[1], page[0].ID)
+	})
+
+	// Счетчики в топике следуют за удалением комментариев
+	t.Run("CountersAfterDelete", func(t *testing.T) {
+		_, err := models.DeleteCommentByID(context.Background(), commentIDs[0])
+		assert.NoError(t, err)
+		_, err = models.DeleteCommentByID(context.Background(), commentIDs[2])
+		assert.NoError(t, err)
+
+		page, err := models.ListTopics(context.Background(), models.TopicSortComments, nil, 10)
+		assert.NoError(t, err)
+		assert.Len(t, page, 3)
+		assert.Equal(t, ids[0], page[0].ID)
+		assert.Equal(t, 1, page[0].CommentsCount)
+		assert.Equal(t, 0, page[1].CommentsCount)
+
+		page, err = models.ListTopics(context.Background(), models.TopicSortActive, nil, 1)
+		assert.NoError(t, err)
+		assert.Len(t, page, 1)
+		assert.Equal(t, ids[0], page[0].ID)
+	})
+
+	// Курсор от другой сортировки не принимается
+	t.Run("CursorSortMismatch", func(t *testing.T) {
+		cursor := models.Cursor{Sort: string(models.TopicSortComments), ID: ids[0], Count: 2}
+		_, err := models.ListTopics(context.Background(), models.TopicSortNewest, &cursor, 10)
+		assert.ErrorIs(t, err, models.ErrInvalidCursor)
+	})
+}
@@ -0,0 +1,126 @@
+package retention
+
+import (
+	"context"
+	"time"
+
+	"github.com/HedgeHogSE/forum/backend/forum/internal/logger"
+	"github.com/HedgeHogSE/forum/backend/forum/internal/models"
+	"github.com/rs/zerolog"
+)
+
+var log zerolog.Logger
+
+func init() {
+	log = logger.GetLogger("retention")
+}
+
+// PolicyResult - результат применения одной политики
+type PolicyResult struct {
+	Policy models.RetentionPolicy `json:"policy"`
+	// Matched - количество комментариев, подпадающих под политику
+	Matched int `json:"matched"`
+	// Affected - количество архивированных или удаленных комментариев, в dry-run всегда 0
+	Affected int64 `json:"affected"`
+}
+
+// Report - результат одного прохода воркера
+type Report struct {
+	DryRun  bool           `json:"dry_run"`
+	Results []PolicyResult `json:"results"`
+}
+
+// Worker периодически применяет политики хранения комментариев.
+// В режиме dry-run только подсчитывает и логирует комментарии, которые были бы затронуты
+type Worker struct {
+	interval time.Duration
+	dryRun   bool
+}
+
+// NewWorker создает воркер с периодом запуска interval
+func NewWorker(interval time.Duration, dryRun bool) *Worker {
+	return &Worker{
+		interval: interval,
+		dryRun:   dryRun,
+	}
+}
+
+// Run выполняет проход сразу и затем с периодом interval, пока не отменен ctx
+func (w *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := w.RunOnce(ctx); err != nil {
+			log.Error().Err(err).Msg("Retention run failed")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce применяет все политики хранения один раз
+func (w *Worker) RunOnce(ctx context.Context) (Report, error) {
+	report := Report{DryRun: w.dryRun}
+
+	policies, err := models.GetRetentionPolicies(ctx)
+	if err != nil {
+		return report, err
+	}
+
+	now := time.Now()
+	for _, policy := range policies {
+		if policy.Action == models.RetentionKeep {
+			continue
+		}
+
+		result, err := w.apply(ctx, policy, now)
+		if err != nil {
+			return report, err
+		}
+		report.Results = append(report.Results, result)
+	}
+	return report, nil
+}
+
+func (w *Worker) apply(ctx context.Context, policy models.RetentionPolicy, now time.Time) (PolicyResult, error) {
+	result := PolicyResult{Policy: policy}
+
+	ids, err := models.GetExpiredCommentIDs(ctx, policy.TopicId, policy.Cutoff(now))
+	if err != nil {
+		return result, err
+	}
+	result.Matched = len(ids)
+
+	event := log.Info().
+		Int("policy_id", policy.ID).
+		Str("action", string(policy.Action)).
+		Int("after_days", policy.AfterDays).
+		Int("matched", result.Matched).
+		Bool("dry_run", w.dryRun)
+	if policy.TopicId != nil {
+		event = event.Int("topic_id", *policy.TopicId)
+	}
+
+	if w.dryRun || len(ids) == 0 {
+		event.Msg("Retention policy checked")
+		return result, nil
+	}
+
+	switch policy.Action {
+	case models.RetentionArchive:
+		result.Affected, err = models.ArchiveComments(ctx, ids)
+	case models.RetentionDelete:
+		result.Affected, err = models.DeleteComments(ctx, ids)
+	}
+	if err != nil {
+		return result, err
+	}
+
+	event.Int64("affected", result.Affected).Msg("Retention policy applied")
+	return result, nil
+}
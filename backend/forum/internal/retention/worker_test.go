@@ -0,0 +1,103 @@
+package retention_test
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/HedgeHogSE/forum/backend/forum/internal/db"
+	"github.com/HedgeHogSE/forum/backend/forum/internal/models"
+	"github.com/HedgeHogSE/forum/backend/forum/internal/retention"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testUserID int
+
+func setupTestDB(t *testing.T) {
+	// Устанавливаем тестовые значения для БД
+	os.Setenv("DB_HOST", "localhost")
+	os.Setenv("DB_PORT", "5432")
+	os.Setenv("DB_USER", "postgres")
+	os.Setenv("DB_PASSWORD", "Sashaezhak2006")
+	os.Setenv("DB_NAME", "forum_test")
+	os.Setenv("DB_SSLMODE", "disable")
+
+	// Подключаемся к тестовой БД
+	err := db.SetupDB()
+	require.NoError(t, err)
+
+	// Очищаем таблицы перед тестами
+	_, err = db.Db.Exec("TRUNCATE comments, topics, comments_archive, retention_policies CASCADE")
+	require.NoError(t, err)
+
+	err = db.Db.QueryRow("SELECT id FROM users WHERE username = 'test_user'").Scan(&testUserID)
+	if err != nil {
+		err = db.Db.QueryRow(`
+			INSERT INTO users (username, email, password_hash, name)
+			VALUES ('test_user', 'test@example.com', 'test_hash', 'Test User')
+			RETURNING id
+		`).Scan(&testUserID)
+		require.NoError(t, err)
+	}
+}
+
+// addOldComment создает комментарий, созданный days дней назад
+func addOldComment(t *testing.T, topicID, days int) int {
+	id, err := models.AddComment(context.Background(), &models.Comment{Content: "old", TopicId: topicID, AuthorId: testUserID})
+	require.NoError(t, err)
+	_, err = db.Db.Exec("UPDATE comments SET created_at = $1 WHERE id = $2", time.Now().AddDate(0, 0, -days), id)
+	require.NoError(t, err)
+	return id
+}
+
+func countRows(t *testing.T, table string) int {
+	var n int
+	require.NoError(t, db.Db.QueryRow("SELECT count(*) FROM "+table).Scan(&n))
+	return n
+}
+
+func TestWorkerRunOnce(t *testing.T) {
+	setupTestDB(t)
+
+	archiveTopic, err := models.AddTopic(context.Background(), &models.Topic{Title: "Archive", AuthorId: testUserID})
+	require.NoError(t, err)
+	keepTopic, err := models.AddTopic(context.Background(), &models.Topic{Title: "Keep", AuthorId: testUserID})
+	require.NoError(t, err)
+	deleteTopic, err := models.AddTopic(context.Background(), &models.Topic{Title: "Delete", AuthorId: testUserID})
+	require.NoError(t, err)
+
+	addOldComment(t, archiveTopic, 20)
+	addOldComment(t, keepTopic, 400)
+	addOldComment(t, deleteTopic, 100)
+	addOldComment(t, deleteTopic, 1)
+
+	_, err = models.SetRetentionPolicy(context.Background(), models.RetentionPolicy{Action: models.RetentionDelete, AfterDays: 90})
+	require.NoError(t, err)
+	_, err = models.SetRetentionPolicy(context.Background(), models.RetentionPolicy{TopicId: &archiveTopic, Action: models.RetentionArchive, AfterDays: 14})
+	require.NoError(t, err)
+	_, err = models.SetRetentionPolicy(context.Background(), models.RetentionPolicy{TopicId: &keepTopic, Action: models.RetentionKeep})
+	require.NoError(t, err)
+
+	// Dry-run ничего не меняет
+	report, err := retention.NewWorker(time.Hour, true).RunOnce(context.Background())
+	require.NoError(t, err)
+	assert.True(t, report.DryRun)
+	require.Len(t, report.Results, 2)
+	for _, result := range report.Results {
+		assert.Equal(t, 1, result.Matched)
+		assert.Zero(t, result.Affected)
+	}
+	assert.Equal(t, 4, countRows(t, "comments"))
+
+	report, err = retention.NewWorker(time.Hour, false).RunOnce(context.Background())
+	require.NoError(t, err)
+	for _, result := range report.Results {
+		assert.Equal(t, int64(1), result.Affected)
+	}
+
+	// Архивированный комментарий перенесен, удаленный - нет, keep не затронут
+	assert.Equal(t, 2, countRows(t, "comments"))
+	assert.Equal(t, 1, countRows(t, "comments_archive"))
+}
@@ -0,0 +1,62 @@
+package websocket
+
+import (
+	"encoding/json"
+	"sync"
+)
+
+// Message - сообщение для рассылки подписчикам топика на всех экземплярах сервиса
+type Message struct {
+	TopicID int `json:"topic_id"`
+	// Skip - ID подключения, которому сообщение не доставляется (например, автору typing)
+	Skip    string          `json:"skip,omitempty"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+	// Control - служебная команда для самих экземпляров. Такое сообщение подписчикам
+	// топика не рассылается
+	Control *Control `json:"control,omitempty"`
+}
+
+// Broker доставляет сообщения между экземплярами сервиса.
+// Каждый экземпляр подписывает свой Hub и получает в том числе собственные сообщения
+type Broker interface {
+	Publish(msg Message) error
+	Subscribe(handler func(Message)) error
+	Close() error
+}
+
+// MemoryBroker доставляет сообщения только внутри процесса. Подходит для одного экземпляра
+type MemoryBroker struct {
+	mu       sync.RWMutex
+	handlers []func(Message)
+}
+
+// NewMemoryBroker создает MemoryBroker
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{}
+}
+
+func (b *MemoryBroker) Publish(msg Message) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for _, handler := range b.handlers {
+		handler(msg)
+	}
+	return nil
+}
+
+func (b *MemoryBroker) Subscribe(handler func(Message)) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.handlers = append(b.handlers, handler)
+	return nil
+}
+
+func (b *MemoryBroker) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.handlers = nil
+	return nil
+}
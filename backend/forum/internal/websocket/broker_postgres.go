@@ -0,0 +1,174 @@
+package websocket
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	// Канал LISTEN/NOTIFY для сообщений WebSocket
+	notifyChannel = "forum_ws"
+	// Лимит payload у NOTIFY - 8000 байт, сообщения больше сохраняются в ws_messages
+	maxNotifyPayload = 7900
+	// Сколько хранятся сообщения в ws_messages
+	spillRetention = time.Minute
+	// Период проверки соединения слушателя
+	listenerPingPeriod = 90 * time.Second
+)
+
+// pgNotification - payload уведомления: само сообщение или ссылка на строку ws_messages
+type pgNotification struct {
+	Message
+	Ref int64 `json:"ref,omitempty"`
+}
+
+// PostgresBroker рассылает сообщения через PostgreSQL LISTEN/NOTIFY,
+// поэтому их получают все экземпляры, подключенные к одной БД
+type PostgresBroker struct {
+	db       *sql.DB
+	listener *pq.Listener
+
+	mu       sync.RWMutex
+	handlers []func(Message)
+	done     chan struct{}
+	once     sync.Once
+}
+
+// NewPostgresBroker создает брокер. connStr используется для отдельного соединения LISTEN,
+// публикация идет через db
+func NewPostgresBroker(connStr string, db *sql.DB) (*PostgresBroker, error) {
+	listener := pq.NewListener(connStr, time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Println("Ошибка соединения LISTEN:", err)
+		}
+	})
+	if err := listener.Listen(notifyChannel); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("не удалось подписаться на канал %s: %w", notifyChannel, err)
+	}
+
+	b := &PostgresBroker{
+		db:       db,
+		listener: listener,
+		done:     make(chan struct{}),
+	}
+	go b.listen()
+	return b, nil
+}
+
+func (b *PostgresBroker) Publish(msg Message) error {
+	payload, err := json.Marshal(pgNotification{Message: msg})
+	if err != nil {
+		return err
+	}
+
+	if len(payload) > maxNotifyPayload {
+		payload, err = b.spill(payload)
+		if err != nil {
+			return err
+		}
+	}
+
+	if _, err := b.db.Exec("SELECT pg_notify($1, $2)", notifyChannel, string(payload)); err != nil {
+		return fmt.Errorf("не удалось отправить уведомление: %w", err)
+	}
+	return nil
+}
+
+// spill сохраняет большое сообщение в ws_messages и возвращает payload со ссылкой на него
+func (b *PostgresBroker) spill(payload []byte) ([]byte, error) {
+	var id int64
+	err := b.db.QueryRow("INSERT INTO ws_messages (payload) VALUES ($1) RETURNING id", string(payload)).Scan(&id)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось сохранить сообщение: %w", err)
+	}
+
+	// Срок считается по часам БД, как и created_at
+	_, err = b.db.Exec("DELETE FROM ws_messages WHERE created_at < now() - make_interval(secs => $1)",
+		spillRetention.Seconds())
+	if err != nil {
+		log.Println("Ошибка очистки ws_messages:", err)
+	}
+
+	return json.Marshal(pgNotification{Ref: id})
+}
+
+func (b *PostgresBroker) Subscribe(handler func(Message)) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.handlers = append(b.handlers, handler)
+	return nil
+}
+
+func (b *PostgresBroker) Close() error {
+	var err error
+	b.once.Do(func() {
+		close(b.done)
+		err = b.listener.Close()
+	})
+	return err
+}
+
+func (b *PostgresBroker) listen() {
+	ticker := time.NewTicker(listenerPingPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-b.done:
+			return
+
+		case n := <-b.listener.Notify:
+			// nil приходит после переподключения, уведомления за время разрыва потеряны
+			if n == nil {
+				log.Println("Соединение LISTEN восстановлено")
+				continue
+			}
+			msg, err := b.decode(n.Extra)
+			if err != nil {
+				log.Println("Ошибка разбора уведомления:", err)
+				continue
+			}
+			b.dispatch(msg)
+
+		case <-ticker.C:
+			go b.listener.Ping()
+		}
+	}
+}
+
+func (b *PostgresBroker) decode(extra string) (Message, error) {
+	var n pgNotification
+	if err := json.Unmarshal([]byte(extra), &n); err != nil {
+		return Message{}, err
+	}
+	if n.Ref == 0 {
+		return n.Message, nil
+	}
+
+	var payload string
+	err := b.db.QueryRow("SELECT payload FROM ws_messages WHERE id = $1", n.Ref).Scan(&payload)
+	if err != nil {
+		return Message{}, fmt.Errorf("не удалось получить сообщение %d: %w", n.Ref, err)
+	}
+	if err := json.Unmarshal([]byte(payload), &n); err != nil {
+		return Message{}, err
+	}
+	return n.Message, nil
+}
+
+func (b *PostgresBroker) dispatch(msg Message) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for _, handler := range b.handlers {
+		handler(msg)
+	}
+}
@@ -0,0 +1,80 @@
+package websocket_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/HedgeHogSE/forum/backend/forum/internal/db"
+	ws "github.com/HedgeHogSE/forum/backend/forum/internal/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryBroker(t *testing.T) {
+	b := ws.NewMemoryBroker()
+
+	var received []ws.Message
+	require.NoError(t, b.Subscribe(func(msg ws.Message) {
+		received = append(received, msg)
+	}))
+
+	msg := ws.Message{TopicID: 1, Skip: "conn", Payload: json.RawMessage(`{"v":1}`)}
+	require.NoError(t, b.Publish(msg))
+
+	require.Len(t, received, 1)
+	assert.Equal(t, msg, received[0])
+
+	// После закрытия сообщения не доставляются
+	require.NoError(t, b.Close())
+	require.NoError(t, b.Publish(msg))
+	assert.Len(t, received, 1)
+}
+
+// receive ждет сообщение от брокера
+func receive(t *testing.T, ch <-chan ws.Message) ws.Message {
+	t.Helper()
+	select {
+	case msg := <-ch:
+		return msg
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timeout waiting for broker message")
+		return ws.Message{}
+	}
+}
+
+func TestPostgresBroker(t *testing.T) {
+	setupTestDB(t)
+
+	// Два брокера имитируют два экземпляра сервиса
+	publisher, err := ws.NewPostgresBroker(db.ConnString(), db.Db)
+	require.NoError(t, err)
+	defer publisher.Close()
+
+	subscriber, err := ws.NewPostgresBroker(db.ConnString(), db.Db)
+	require.NoError(t, err)
+	defer subscriber.Close()
+
+	received := make(chan ws.Message, 2)
+	require.NoError(t, subscriber.Subscribe(func(msg ws.Message) {
+		received <- msg
+	}))
+
+	msg := ws.Message{TopicID: testTopicID, Payload: json.RawMessage(`{"v":1,"type":"typing"}`)}
+	require.NoError(t, publisher.Publish(msg))
+	assert.Equal(t, msg, receive(t, received))
+
+	// Сообщение больше лимита NOTIFY передается через ws_messages
+	large := ws.Message{
+		TopicID: testTopicID,
+		Payload: json.RawMessage(`{"content":"` + strings.Repeat("a", 10000) + `"}`),
+	}
+	require.NoError(t, publisher.Publish(large))
+	assert.Equal(t, large, receive(t, received))
+
+	// Служебная команда доходит до другого экземпляра без изменений
+	control := ws.Message{Control: &ws.Control{Type: ws.ControlUserDeleted, UserID: 1, Anonymized: true}}
+	require.NoError(t, publisher.Publish(control))
+	assert.Equal(t, control, receive(t, received))
+}
@@ -0,0 +1,86 @@
+package websocket
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// Размер очереди исходящих сообщений одного клиента
+	sendBufferSize = 64
+	// Время на запись одного сообщения клиенту
+	writeWait = 10 * time.Second
+	// Время ожидания pong от клиента
+	pongWait = 60 * time.Second
+	// Период отправки ping, должен быть меньше pongWait
+	pingPeriod = pongWait * 9 / 10
+	// Максимальный размер входящего сообщения
+	maxMessageSize = 16 * 1024
+)
+
+// Client - одно WebSocket подключение к комнате топика
+type Client struct {
+	// id уникален среди подключений всех экземпляров
+	id       string
+	conn     *websocket.Conn
+	topicID  int
+	userID   int
+	username string
+	// emailVerified - подтвердил ли пользователь email на момент подключения
+	emailVerified bool
+	send          chan []byte
+}
+
+func newClient(conn *websocket.Conn, topicID, userID int, username string) *Client {
+	return &Client{
+		id:       newClientID(),
+		conn:     conn,
+		topicID:  topicID,
+		userID:   userID,
+		username: username,
+		send:     make(chan []byte, sendBufferSize),
+	}
+}
+
+func newClientID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// writePump отправляет клиенту сообщения из очереди. Для каждого подключения
+// запускается своя горутина, поэтому медленный клиент не задерживает остальных
+func (c *Client) writePump() {
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+	}()
+
+	for {
+		select {
+		case msg, ok := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if !ok {
+				// Hub закрыл очередь
+				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
+			if err := c.conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+				log.Println("Ошибка отправки:", err)
+				return
+			}
+			messagesSent.Inc()
+
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
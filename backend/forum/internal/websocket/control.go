@@ -0,0 +1,63 @@
+package websocket
+
+import (
+	"log"
+
+	"github.com/HedgeHogSE/forum/backend/forum/internal/external"
+)
+
+// Типы служебных команд
+const (
+	// ControlUsernameChanged сбрасывает закешированное имя пользователя
+	ControlUsernameChanged = "username.changed"
+	// ControlUserDeleted скрывает имя удаленного пользователя и закрывает его подключения
+	ControlUserDeleted = "user.deleted"
+)
+
+// Control - служебная команда, которую брокер доставляет всем экземплярам сервиса.
+// auth-сервис уведомляет только один экземпляр, а кеш имен и подключения есть у каждого
+type Control struct {
+	Type   string `json:"type"`
+	UserID int    `json:"user_id"`
+	// Anonymized - пользователь обезличен, и его имя скрывается навсегда
+	Anonymized bool `json:"anonymized,omitempty"`
+}
+
+// NotifyUsernameChanged сбрасывает имя пользователя в кеше всех экземпляров
+func NotifyUsernameChanged(userID int) {
+	publishControl(Control{Type: ControlUsernameChanged, UserID: userID})
+}
+
+// NotifyUserDeleted скрывает имя удаленного пользователя и закрывает его WebSocket
+// подключения на всех экземплярах
+func NotifyUserDeleted(userID int, anonymized bool) {
+	publishControl(Control{Type: ControlUserDeleted, UserID: userID, Anonymized: anonymized})
+}
+
+// publishControl рассылает команду всем экземплярам. Если брокер недоступен,
+// команда применяется хотя бы на этом экземпляре
+func publishControl(c Control) {
+	brokerMu.RLock()
+	b := broker
+	brokerMu.RUnlock()
+
+	if err := b.Publish(Message{Control: &c}); err != nil {
+		log.Println("Ошибка публикации служебной команды:", err)
+		applyControl(c)
+	}
+}
+
+// applyControl выполняет команду на этом экземпляре
+func applyControl(c Control) {
+	switch c.Type {
+	case ControlUsernameChanged:
+		external.InvalidateUsername(c.UserID)
+	case ControlUserDeleted:
+		external.MarkUserDeleted(c.UserID, c.Anonymized)
+		if n := hub.DisconnectUser(c.UserID); n > 0 {
+			log.Printf("Closed %d websocket connections of deleted user %d", n, c.UserID)
+		}
+	default:
+		log.Println("Неизвестная служебная команда:", c.Type)
+	}
+}
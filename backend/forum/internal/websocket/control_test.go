@@ -0,0 +1,31 @@
+package websocket
+
+import (
+	"context"
+	"testing"
+
+	"github.com/HedgeHogSE/forum/backend/forum/internal/external"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestControlFromOtherInstance(t *testing.T) {
+	b := NewMemoryBroker()
+	require.NoError(t, SetBroker(b))
+	t.Cleanup(func() {
+		SetBroker(NewMemoryBroker())
+		external.ResetUsernameCache()
+	})
+
+	client := newClient(nil, 1, 42, "leaving")
+	hub.Join(client)
+
+	// Команду опубликовал другой экземпляр, получивший вызов auth-сервиса
+	require.NoError(t, b.Publish(Message{Control: &Control{Type: ControlUserDeleted, UserID: 42, Anonymized: true}}))
+
+	_, ok := <-client.send
+	assert.False(t, ok, "подключение удаленного пользователя должно быть закрыто")
+	names, err := external.GetUsernamesByUserIDs(context.Background(), []int{42})
+	require.NoError(t, err)
+	assert.Equal(t, map[int]string{42: external.DeletedUsername}, names)
+}
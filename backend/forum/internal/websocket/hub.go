@@ -0,0 +1,164 @@
+package websocket
+
+import (
+	"sync"
+)
+
+// Hub хранит подключения, сгруппированные по комнатам - по одной на топик
+type Hub struct {
+	mu    sync.RWMutex
+	rooms map[int]map[*Client]struct{}
+}
+
+// NewHub создает пустой Hub
+func NewHub() *Hub {
+	return &Hub{
+		rooms: make(map[int]map[*Client]struct{}),
+	}
+}
+
+// Join добавляет клиента в комнату его топика
+func (h *Hub) Join(c *Client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	room, ok := h.rooms[c.topicID]
+	if !ok {
+		room = make(map[*Client]struct{})
+		h.rooms[c.topicID] = room
+	}
+	room[c] = struct{}{}
+	clientsGauge.WithLabelValues(topicLabel(c.topicID)).Inc()
+}
+
+// Leave убирает клиента из комнаты и закрывает его очередь отправки
+func (h *Hub) Leave(c *Client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.remove(c)
+}
+
+// remove должен вызываться под h.mu
+func (h *Hub) remove(c *Client) {
+	room, ok := h.rooms[c.topicID]
+	if !ok {
+		return
+	}
+	if _, ok := room[c]; !ok {
+		return
+	}
+
+	delete(room, c)
+	if len(room) == 0 {
+		delete(h.rooms, c.topicID)
+		clientsGauge.DeleteLabelValues(topicLabel(c.topicID))
+	} else {
+		clientsGauge.WithLabelValues(topicLabel(c.topicID)).Dec()
+	}
+	close(c.send)
+}
+
+// Broadcast ставит сообщение в очередь всем подписчикам топика.
+// Отправка не блокируется: клиент, чья очередь переполнена, отключается
+func (h *Hub) Broadcast(topicID int, msg []byte) {
+	h.broadcast(topicID, msg, "")
+}
+
+// broadcast рассылает сообщение всем подписчикам топика, кроме подключения с ID skip
+func (h *Hub) broadcast(topicID int, msg []byte, skip string) {
+	var slow []*Client
+
+	h.mu.RLock()
+	for c := range h.rooms[topicID] {
+		if skip != "" && c.id == skip {
+			continue
+		}
+		select {
+		case c.send <- msg:
+		default:
+			droppedSends.Inc()
+			slow = append(slow, c)
+		}
+	}
+	h.mu.RUnlock()
+
+	if len(slow) == 0 {
+		return
+	}
+
+	h.mu.Lock()
+	for _, c := range slow {
+		h.remove(c)
+	}
+	h.mu.Unlock()
+}
+
+// Send ставит сообщение в очередь одному клиенту. Если клиент уже отключен
+// или его очередь переполнена, сообщение отбрасывается
+func (h *Hub) Send(c *Client, msg []byte) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	if _, ok := h.rooms[c.topicID][c]; !ok {
+		return
+	}
+	select {
+	case c.send <- msg:
+	default:
+		droppedSends.Inc()
+	}
+}
+
+// DisconnectUser отключает все подключения пользователя и возвращает их количество
+func (h *Hub) DisconnectUser(userID int) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var clients []*Client
+	for _, room := range h.rooms {
+		for c := range room {
+			if c.userID == userID {
+				clients = append(clients, c)
+			}
+		}
+	}
+	for _, c := range clients {
+		h.remove(c)
+	}
+	return len(clients)
+}
+
+// clients возвращает снимок всех подключений
+func (h *Hub) clients() []*Client {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	var clients []*Client
+	for _, room := range h.rooms {
+		for c := range room {
+			clients = append(clients, c)
+		}
+	}
+	return clients
+}
+
+// Size возвращает количество подключений во всех комнатах
+func (h *Hub) Size() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	n := 0
+	for _, room := range h.rooms {
+		n += len(room)
+	}
+	return n
+}
+
+// RoomSize возвращает количество подключений к топику
+func (h *Hub) RoomSize(topicID int) int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return len(h.rooms[topicID])
+}
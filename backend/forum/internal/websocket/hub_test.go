@@ -0,0 +1,136 @@
+package websocket
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func testClient(topicID int) *Client {
+	return newClient(nil, topicID, 1, "testuser")
+}
+
+func TestHubBroadcastToRoom(t *testing.T) {
+	h := NewHub()
+
+	a := testClient(1)
+	b := testClient(1)
+	other := testClient(2)
+	h.Join(a)
+	h.Join(b)
+	h.Join(other)
+
+	assert.Equal(t, 2, h.RoomSize(1))
+	assert.Equal(t, 1, h.RoomSize(2))
+
+	h.Broadcast(1, []byte("hello"))
+
+	// Сообщение получают только подписчики топика 1
+	assert.Equal(t, "hello", string(<-a.send))
+	assert.Equal(t, "hello", string(<-b.send))
+	assert.Empty(t, other.send)
+}
+
+func TestHubLeave(t *testing.T) {
+	h := NewHub()
+
+	c := testClient(1)
+	h.Join(c)
+	h.Leave(c)
+
+	assert.Equal(t, 0, h.RoomSize(1))
+	_, ok := <-c.send
+	assert.False(t, ok, "очередь отправки должна быть закрыта")
+
+	// Повторный выход не паникует
+	h.Leave(c)
+}
+
+func TestHubDropsSlowClient(t *testing.T) {
+	h := NewHub()
+
+	slow := testClient(1)
+	fast := testClient(1)
+	h.Join(slow)
+	h.Join(fast)
+
+	// Заполняем очередь медленного клиента
+	for i := 0; i < sendBufferSize; i++ {
+		h.Broadcast(1, []byte("msg"))
+		<-fast.send
+	}
+
+	// Следующая рассылка не блокируется, медленный клиент отключается
+	h.Broadcast(1, []byte("overflow"))
+
+	assert.Equal(t, 1, h.RoomSize(1))
+	assert.Equal(t, "overflow", string(<-fast.send))
+
+	for range slow.send {
+	}
+}
+
+func TestHubBroadcastSkipsSender(t *testing.T) {
+	h := NewHub()
+
+	sender := testClient(1)
+	other := testClient(1)
+	h.Join(sender)
+	h.Join(other)
+
+	h.broadcast(1, []byte("typing"), sender.id)
+
+	assert.Empty(t, sender.send)
+	assert.Equal(t, "typing", string(<-other.send))
+
+	// Send не пишет в очередь отключенного клиента
+	h.Leave(other)
+	h.Send(other, []byte("late"))
+}
+
+func TestHubDisconnectUser(t *testing.T) {
+	h := NewHub()
+
+	a := testClient(1)
+	b := testClient(2)
+	other := newClient(nil, 1, 2, "other")
+	h.Join(a)
+	h.Join(b)
+	h.Join(other)
+
+	assert.Equal(t, 2, h.DisconnectUser(1))
+	assert.Equal(t, 1, h.RoomSize(1))
+	assert.Equal(t, 0, h.RoomSize(2))
+
+	_, ok := <-a.send
+	assert.False(t, ok, "очередь отправки должна быть закрыта")
+	_, ok = <-b.send
+	assert.False(t, ok, "очередь отправки должна быть закрыта")
+	assert.Empty(t, other.send)
+}
+
+func TestHubMetrics(t *testing.T) {
+	h := NewHub()
+	const topicID = 9001
+	gauge := clientsGauge.WithLabelValues(topicLabel(topicID))
+
+	a := testClient(topicID)
+	b := testClient(topicID)
+	h.Join(a)
+	h.Join(b)
+	assert.Equal(t, 2.0, testutil.ToFloat64(gauge))
+
+	// Переполненная очередь: сообщение отбрасывается, клиент отключается
+	for i := 0; i < sendBufferSize; i++ {
+		a.send <- []byte("fill")
+	}
+	dropped := testutil.ToFloat64(droppedSends)
+	h.Broadcast(topicID, []byte("hello"))
+	assert.Equal(t, dropped+1, testutil.ToFloat64(droppedSends))
+	assert.Equal(t, 1.0, testutil.ToFloat64(gauge))
+
+	// Ряд пустого топика удаляется
+	h.Leave(b)
+	assert.False(t, clientsGauge.DeleteLabelValues(topicLabel(topicID)))
+}
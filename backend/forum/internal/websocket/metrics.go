@@ -0,0 +1,37 @@
+package websocket
+
+import (
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	// clientsGauge - количество подключений по топикам. Ряд топика удаляется,
+	// когда из него выходит последний клиент
+	clientsGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "forum_websocket_clients",
+		Help: "Количество WebSocket подключений к топику",
+	}, []string{"topic_id"})
+
+	messagesReceived = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "forum_websocket_messages_received_total",
+		Help: "Количество сообщений, полученных от WebSocket клиентов",
+	})
+
+	messagesSent = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "forum_websocket_messages_sent_total",
+		Help: "Количество сообщений, отправленных WebSocket клиентам",
+	})
+
+	// droppedSends считает сообщения, не поставленные в очередь из-за ее переполнения
+	droppedSends = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "forum_websocket_dropped_sends_total",
+		Help: "Количество сообщений, отброшенных из-за переполненной очереди клиента",
+	})
+)
+
+func topicLabel(topicID int) string {
+	return strconv.Itoa(topicID)
+}
@@ -0,0 +1,95 @@
+package websocket
+
+import (
+	"encoding/json"
+)
+
+// ProtocolVersion - текущая версия протокола. Сообщения с другой версией отклоняются
+const ProtocolVersion = 1
+
+// Типы событий
+const (
+	// EventHistory - история комментариев топика, отправляется сразу после подключения.
+	// Комментарий, созданный во время подключения, может прийти и в истории, и в comment.created:
+	// клиент сопоставляет их по id
+	EventHistory = "history"
+	// EventCommentCreate - запрос клиента на создание комментария
+	EventCommentCreate  = "comment.create"
+	EventCommentCreated = "comment.created"
+	EventCommentUpdated = "comment.updated"
+	// EventCommentDeleted - комментарий удален. Ответы на него не удаляются: каждый прямой
+	// ответ приходит следом в comment.updated с parent_id = null
+	EventCommentDeleted = "comment.deleted"
+	// EventTyping - пользователь набирает сообщение, рассылается остальным участникам топика
+	EventTyping        = "typing"
+	EventPresenceJoin  = "presence.join"
+	EventPresenceLeave = "presence.leave"
+	EventError         = "error"
+	// EventAck - подтверждение запроса клиента, ID совпадает с ID запроса
+	EventAck = "ack"
+)
+
+// Коды ошибок в событии error
+const (
+	ErrCodeBadMessage  = "bad_message"
+	ErrCodeBadVersion  = "unsupported_version"
+	ErrCodeUnknownType = "unknown_type"
+	ErrCodeRejected    = "rejected"
+	ErrCodeInternal    = "internal"
+	ErrCodeUnverified  = "email_not_verified"
+)
+
+// Envelope - конверт всех сообщений протокола в обе стороны
+type Envelope struct {
+	Version int    `json:"v"`
+	Type    string `json:"type"`
+	// ID задается клиентом и возвращается в ack или error на его запрос
+	ID      string          `json:"id,omitempty"`
+	TopicID int             `json:"topic_id,omitempty"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+// CommentCreateData - данные запроса comment.create
+type CommentCreateData struct {
+	Content  string `json:"content"`
+	ParentId *int   `json:"parent_id"`
+}
+
+// CommentDeletedData - данные события comment.deleted. Ответы удаляются вместе с комментарием
+type CommentDeletedData struct {
+	ID int `json:"id"`
+}
+
+// UserData - данные событий typing, presence.join и presence.leave
+type UserData struct {
+	UserID   int    `json:"user_id"`
+	Username string `json:"username"`
+	// Online - количество подключений к топику на этом экземпляре, только для presence
+	Online int `json:"online,omitempty"`
+}
+
+// AckData - данные события ack
+type AckData struct {
+	CommentID int `json:"comment_id,omitempty"`
+}
+
+// ErrorData - данные события error
+type ErrorData struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// newEvent собирает сериализованный конверт события
+func newEvent(eventType, id string, topicID int, data interface{}) ([]byte, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(Envelope{
+		Version: ProtocolVersion,
+		Type:    eventType,
+		ID:      id,
+		TopicID: topicID,
+		Data:    raw,
+	})
+}
@@ -0,0 +1,55 @@
+package websocket
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// shutdownPollInterval - как часто Shutdown проверяет, отключились ли клиенты
+const shutdownPollInterval = 50 * time.Millisecond
+
+// shuttingDown запрещает новые подключения после начала остановки
+var shuttingDown atomic.Bool
+
+// Shutdown отправляет всем клиентам close frame с кодом 1001 (going away) и ждет,
+// пока они ответят и отключатся. Соединения, не закрытые до истечения ctx,
+// закрываются принудительно. В конце закрывается брокер
+func Shutdown(ctx context.Context) error {
+	shuttingDown.Store(true)
+
+	closeMsg := websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down")
+	deadline := time.Now().Add(writeWait)
+	for _, c := range hub.clients() {
+		// WriteControl можно вызывать параллельно с writePump
+		c.conn.WriteControl(websocket.CloseMessage, closeMsg, deadline)
+	}
+
+	ticker := time.NewTicker(shutdownPollInterval)
+	defer ticker.Stop()
+
+	var err error
+wait:
+	for hub.Size() > 0 {
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+			break wait
+		case <-ticker.C:
+		}
+	}
+	// readPump завершится с ошибкой и уберет клиента из hub
+	for _, c := range hub.clients() {
+		c.conn.Close()
+	}
+
+	brokerMu.RLock()
+	b := broker
+	brokerMu.RUnlock()
+	if closeErr := b.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,87 @@
+package websocket
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// serveTestClient подключает клиента к hub без авторизации и истории топика
+func serveTestClient(w http.ResponseWriter, r *http.Request) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	client := newClient(conn, 1, 1, "testuser")
+	hub.Join(client)
+	go client.writePump()
+	readPump(client)
+	hub.Leave(client)
+}
+
+func TestShutdownClosesConnections(t *testing.T) {
+	t.Cleanup(func() {
+		shuttingDown.Store(false)
+		SetBroker(NewMemoryBroker())
+	})
+
+	server := httptest.NewServer(http.HandlerFunc(serveTestClient))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// Клиент должен прочитать close frame, чтобы ответить на него
+	closed := make(chan error, 1)
+	go func() {
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				closed <- err
+				return
+			}
+		}
+	}()
+	require.Eventually(t, func() bool { return hub.Size() == 1 }, time.Second, 10*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	require.NoError(t, Shutdown(ctx))
+
+	err = <-closed
+	assert.True(t, websocket.IsCloseError(err, websocket.CloseGoingAway), "ожидался код 1001, получено %v", err)
+	assert.Equal(t, 0, hub.Size())
+
+	// Новые подключения после остановки отклоняются
+	rec := httptest.NewRecorder()
+	HandleConnections(rec, httptest.NewRequest(http.MethodGet, "/ws", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+}
+
+func TestShutdownForcesUnresponsiveClients(t *testing.T) {
+	t.Cleanup(func() {
+		shuttingDown.Store(false)
+		SetBroker(NewMemoryBroker())
+	})
+
+	server := httptest.NewServer(http.HandlerFunc(serveTestClient))
+	defer server.Close()
+
+	// Клиент не читает сообщения и поэтому не отвечает на close frame
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	require.Eventually(t, func() bool { return hub.Size() == 1 }, time.Second, 10*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, Shutdown(ctx), context.DeadlineExceeded)
+	assert.Eventually(t, func() bool { return hub.Size() == 0 }, time.Second, 10*time.Millisecond)
+}
@@ -1,54 +1,177 @@
 package websocket
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/HedgeHogSE/forum/backend/forum/internal/external"
+	"github.com/HedgeHogSE/forum/backend/forum/internal/metrics"
 	"github.com/HedgeHogSE/forum/backend/forum/internal/models"
 	"github.com/gorilla/websocket"
+	"go.opentelemetry.io/otel"
 )
 
-/*type Message struct {
-	Content string `json:"content"`
-	Time    string `json:"time"`
-}*/
+// bearerProtocol - подпротокол, через который браузерные клиенты передают JWT:
+// Sec-WebSocket-Protocol: bearer, <token>
+const bearerProtocol = "bearer"
 
 var (
 	upgrader = websocket.Upgrader{
-		CheckOrigin: func(r *http.Request) bool {
-			return true
-		},
+		CheckOrigin:  checkOrigin,
+		Subprotocols: []string{bearerProtocol},
 	}
-	messages      = make([]models.CommentWithUsername, 0)
-	messagesMutex sync.Mutex
-	clients       = make(map[*websocket.Conn]bool)
-	clientsMutex  sync.Mutex
+	allowedOrigins   []string
+	allowedOriginsMu sync.RWMutex
+	// requireVerifiedEmail запрещает писать комментарии без подтвержденного email
+	requireVerifiedEmail atomic.Bool
+	hub                  = NewHub()
+	broker               Broker
+	tracer               = otel.Tracer("forum/websocket")
+	brokerMu             sync.RWMutex
 )
 
-func HandleConnections(w http.ResponseWriter, r *http.Request) {
-	topicID := r.URL.Query().Get("topic")
-	num, err := strconv.Atoi(topicID)
+func init() {
+	SetBroker(NewMemoryBroker())
+}
+
+// SetBroker задает брокер, через который сообщения рассылаются подписчикам на всех
+// экземплярах сервиса. Предыдущий брокер закрывается
+func SetBroker(b Broker) error {
+	if err := b.Subscribe(func(msg Message) {
+		if msg.Control != nil {
+			applyControl(*msg.Control)
+			return
+		}
+		hub.broadcast(msg.TopicID, msg.Payload, msg.Skip)
+	}); err != nil {
+		return err
+	}
+
+	brokerMu.Lock()
+	previous := broker
+	broker = b
+	brokerMu.Unlock()
+
+	if previous != nil {
+		return previous.Close()
+	}
+	return nil
+}
+
+// publish рассылает событие подписчикам топика, кроме подключения skip.
+// Если брокер недоступен, событие получают хотя бы локальные подписчики
+func publish(topicID int, event []byte, skip string) {
+	brokerMu.RLock()
+	b := broker
+	brokerMu.RUnlock()
+
+	err := b.Publish(Message{TopicID: topicID, Skip: skip, Payload: event})
 	if err != nil {
-		log.Println("Ошибка при преобразовании topicID в int:", err)
-		return
+		log.Println("Ошибка публикации сообщения:", err)
+		hub.broadcast(topicID, event, skip)
 	}
-	messages, err = models.GetCommentsByTopicID(num)
-	time := time.Now()
-	for _, m := range messages {
-		if int(time.Sub(m.CreatedAt).Hours()/24) >= 14 {
-			err := models.DeleteCommentByID(m.ID)
-			if err != nil {
-				log.Println("Ошибка при удалении комментария:", err)
-			}
+}
+
+// SetRequireVerifiedEmail включает запрет на создание комментариев
+// пользователями с неподтвержденным email
+func SetRequireVerifiedEmail(required bool) {
+	requireVerifiedEmail.Store(required)
+}
+
+// SetAllowedOrigins задает список Origin, с которых разрешено подключение.
+// "*" разрешает любой Origin, пустой список - только тот же хост
+func SetAllowedOrigins(origins []string) {
+	allowedOriginsMu.Lock()
+	allowedOrigins = origins
+	allowedOriginsMu.Unlock()
+}
+
+func checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	// Заголовок Origin отправляют только браузеры
+	if origin == "" {
+		return true
+	}
+
+	allowedOriginsMu.RLock()
+	defer allowedOriginsMu.RUnlock()
+	for _, allowed := range allowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
 		}
 	}
 
+	u, err := url.Parse(origin)
 	if err != nil {
-		log.Println("Ошибка при получении комментариев:", err)
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
+
+// tokenFromRequest достает JWT из параметра token или из подпротокола "bearer, <token>".
+// Параметр token попадает в логи прокси, поэтому браузерам лучше использовать подпротокол
+func tokenFromRequest(r *http.Request) string {
+	if token := r.URL.Query().Get("token"); token != "" {
+		return token
+	}
+
+	protocols := websocket.Subprotocols(r)
+	for i := 0; i+1 < len(protocols); i++ {
+		if protocols[i] == bearerProtocol {
+			return protocols[i+1]
+		}
+	}
+	return ""
+}
+
+// authenticate проверяет JWT из запроса на подключение
+func authenticate(r *http.Request) (*external.Identity, int) {
+	token := tokenFromRequest(r)
+	if token == "" {
+		return nil, http.StatusUnauthorized
+	}
+
+	identity, err := external.ValidateToken(r.Context(), token)
+	if err != nil {
+		if errors.Is(err, external.ErrInvalidToken) {
+			return nil, http.StatusUnauthorized
+		}
+		log.Println("Ошибка проверки токена:", err)
+		return nil, http.StatusServiceUnavailable
+	}
+	return identity, http.StatusOK
+}
+
+func HandleConnections(w http.ResponseWriter, r *http.Request) {
+	if shuttingDown.Load() {
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+	if !checkOrigin(r) {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+
+	identity, status := authenticate(r)
+	if identity == nil {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	topicID := r.URL.Query().Get("topic")
+	num, err := strconv.Atoi(topicID)
+	if err != nil {
+		log.Println("Ошибка при преобразовании topicID в int:", err)
+		http.Error(w, "invalid topic", http.StatusBadRequest)
 		return
 	}
 
@@ -57,67 +180,247 @@ func HandleConnections(w http.ResponseWriter, r *http.Request) {
 		log.Println("Ошибка при обновлении соединения:", err)
 		return
 	}
-	defer ws.Close()
 
-	clientsMutex.Lock()
-	clients[ws] = true
-	clientsMutex.Unlock()
+	client := newClient(ws, num, identity.UserID, identity.Username)
+	client.emailVerified = identity.EmailVerified
 
-	messagesMutex.Lock()
-	history, _ := json.Marshal(messages)
-	messagesMutex.Unlock()
-	ws.WriteMessage(websocket.TextMessage, history)
+	// Клиент подписывается на топик до загрузки истории, иначе комментарии, созданные
+	// между запросом истории и подпиской, не попали бы ни в историю, ни в события.
+	// Пока история не отправлена, события копятся в очереди клиента
+	hub.Join(client)
+	if err := sendHistory(r.Context(), client); err != nil {
+		log.Println("Ошибка при отправке истории:", err)
+		closeMsg := websocket.FormatCloseMessage(websocket.CloseInternalServerErr, "")
+		ws.WriteControl(websocket.CloseMessage, closeMsg, time.Now().Add(writeWait))
+		hub.Leave(client)
+		ws.Close()
+		return
+	}
+	go client.writePump()
 
 	log.Println("Новое соединение установлено")
+	broadcastPresence(client, EventPresenceJoin)
+
+	readPump(client)
+
+	hub.Leave(client)
+	broadcastPresence(client, EventPresenceLeave)
+}
+
+// sendHistory отправляет клиенту историю комментариев топика. Вызывается до запуска
+// writePump, поэтому история приходит раньше событий из очереди. Комментарий, созданный
+// во время загрузки истории, может прийти и в истории, и событием comment.created
+func sendHistory(ctx context.Context, client *Client) error {
+	messages, err := models.GetCommentsByTopicID(ctx, client.topicID)
+	if err != nil {
+		return err
+	}
+	history, err := newEvent(EventHistory, "", client.topicID, messages)
+	if err != nil {
+		return err
+	}
+	client.conn.SetWriteDeadline(time.Now().Add(writeWait))
+	if err := client.conn.WriteMessage(websocket.TextMessage, history); err != nil {
+		return err
+	}
+	messagesSent.Inc()
+	return nil
+}
+
+// readPump читает сообщения клиента до закрытия соединения
+func readPump(client *Client) {
+	ws := client.conn
+	ws.SetReadLimit(maxMessageSize)
+	ws.SetReadDeadline(time.Now().Add(pongWait))
+	ws.SetPongHandler(func(string) error {
+		ws.SetReadDeadline(time.Now().Add(pongWait))
+		return nil
+	})
 
 	for {
 		_, msg, err := ws.ReadMessage()
 		if err != nil {
-			log.Println(msg)
 			log.Println("Ошибка чтения сообщения:", err)
-			break
-		}
-
-		type IncomingMessage struct {
-			Content  string `json:"content"`
-			TopicId  int    `json:"topic_id"`
-			AuthorId int    `json:"author_id"`
-			Username string `json:"username"`
+			return
 		}
+		messagesReceived.Inc()
 
-		var newMessage IncomingMessage
-		if err := json.Unmarshal(msg, &newMessage); err != nil {
+		var env Envelope
+		if err := json.Unmarshal(msg, &env); err != nil {
 			log.Println("Ошибка разбора сообщения:", err)
+			sendError(client, "", ErrCodeBadMessage, "invalid json")
 			continue
 		}
-
-		comment := &models.Comment{
-			Content:  newMessage.Content,
-			TopicId:  newMessage.TopicId,
-			AuthorId: newMessage.AuthorId,
-		}
-
-		messagesMutex.Lock()
-		_, err = models.AddComment(comment)
-		if err != nil {
-			log.Println(err)
-			return
+		if env.Version != ProtocolVersion {
+			sendError(client, env.ID, ErrCodeBadVersion, "unsupported protocol version")
+			continue
 		}
-		messagesMutex.Unlock()
 
-		clientsMutex.Lock()
-		for client := range clients {
-			err := client.WriteMessage(websocket.TextMessage, msg)
+		switch env.Type {
+		case EventCommentCreate:
+			// Каждое сообщение - отдельная трассировка, а не часть долгого запроса на подключение
+			ctx, span := tracer.Start(context.Background(), "websocket "+env.Type)
+			handleCommentCreate(ctx, client, env)
+			span.End()
+		case EventTyping:
+			event, err := newEvent(EventTyping, "", client.topicID, UserData{
+				UserID:   client.userID,
+				Username: client.username,
+			})
 			if err != nil {
-				log.Println("Ошибка отправки:", err)
-				client.Close()
-				delete(clients, client)
+				log.Println("Ошибка сериализации события:", err)
+				continue
 			}
+			publish(client.topicID, event, client.id)
+		default:
+			sendError(client, env.ID, ErrCodeUnknownType, "unknown event type")
+		}
+	}
+}
+
+// handleCommentCreate сохраняет комментарий, подтверждает его автору и рассылает участникам топика
+func handleCommentCreate(ctx context.Context, client *Client, env Envelope) {
+	if requireVerifiedEmail.Load() && !client.emailVerified {
+		sendError(client, env.ID, ErrCodeUnverified, "email is not verified")
+		return
+	}
+
+	var data CommentCreateData
+	if err := json.Unmarshal(env.Data, &data); err != nil {
+		sendError(client, env.ID, ErrCodeBadMessage, "invalid comment data")
+		return
+	}
+
+	// Автор определяется по токену, топик - по параметру подключения
+	comment := &models.Comment{
+		Content:  data.Content,
+		TopicId:  client.topicID,
+		AuthorId: client.userID,
+		ParentId: data.ParentId,
+	}
+
+	id, err := models.AddComment(ctx, comment)
+	if err != nil {
+		log.Println("Ошибка при добавлении комментария:", err)
+		sendError(client, env.ID, ErrCodeRejected, "comment rejected")
+		return
+	}
+	metrics.CommentsCreated.WithLabelValues("websocket").Inc()
+
+	outgoing, err := threadMessage(ctx, id, client.username)
+	if err != nil {
+		log.Println("Ошибка при получении позиции комментария в ветке:", err)
+		sendError(client, env.ID, ErrCodeInternal, "internal error")
+		return
+	}
+
+	if env.ID != "" {
+		ack, err := newEvent(EventAck, env.ID, client.topicID, AckData{CommentID: id})
+		if err == nil {
+			hub.Send(client, ack)
 		}
-		clientsMutex.Unlock()
 	}
 
-	clientsMutex.Lock()
-	delete(clients, ws)
-	clientsMutex.Unlock()
+	event, err := newEvent(EventCommentCreated, "", client.topicID, outgoing)
+	if err != nil {
+		log.Println("Ошибка сериализации события:", err)
+		return
+	}
+	publish(client.topicID, event, "")
+}
+
+// sendError отправляет клиенту событие error в ответ на запрос id
+func sendError(client *Client, id, code, message string) {
+	event, err := newEvent(EventError, id, client.topicID, ErrorData{Code: code, Message: message})
+	if err != nil {
+		log.Println("Ошибка сериализации события:", err)
+		return
+	}
+	hub.Send(client, event)
+}
+
+// broadcastPresence сообщает остальным участникам топика о подключении или отключении клиента
+func broadcastPresence(client *Client, eventType string) {
+	event, err := newEvent(eventType, "", client.topicID, UserData{
+		UserID:   client.userID,
+		Username: client.username,
+		Online:   hub.RoomSize(client.topicID),
+	})
+	if err != nil {
+		log.Println("Ошибка сериализации события:", err)
+		return
+	}
+	publish(client.topicID, event, client.id)
+}
+
+// NotifyCommentCreated рассылает подписчикам топика комментарий, созданный через HTTP API
+func NotifyCommentCreated(ctx context.Context, commentID int) {
+	notifyComment(ctx, EventCommentCreated, commentID)
+}
+
+// NotifyCommentUpdated рассылает подписчикам топика измененный комментарий
+func NotifyCommentUpdated(ctx context.Context, commentID int) {
+	notifyComment(ctx, EventCommentUpdated, commentID)
+}
+
+// notifyComment рассылает подписчикам топика событие eventType с комментарием и его местом в ветке
+func notifyComment(ctx context.Context, eventType string, commentID int) {
+	comment, err := models.GetCommentByID(ctx, commentID)
+	if err != nil {
+		log.Println("Ошибка при получении комментария:", err)
+		return
+	}
+
+	username, err := external.GetUsernameByUserID(ctx, comment.AuthorId)
+	if err != nil {
+		log.Println("Ошибка при получении имени пользователя:", err)
+	}
+
+	outgoing, err := threadMessage(ctx, commentID, username)
+	if err != nil {
+		log.Println("Ошибка при получении позиции комментария в ветке:", err)
+		return
+	}
+
+	event, err := newEvent(eventType, "", comment.TopicId, outgoing)
+	if err != nil {
+		log.Println("Ошибка сериализации события:", err)
+		return
+	}
+	publish(comment.TopicId, event, "")
+}
+
+// NotifyCommentDeleted рассылает подписчикам топика удаление комментария
+func NotifyCommentDeleted(topicID, commentID int) {
+	event, err := newEvent(EventCommentDeleted, "", topicID, CommentDeletedData{ID: commentID})
+	if err != nil {
+		log.Println("Ошибка сериализации события:", err)
+		return
+	}
+	publish(topicID, event, "")
+}
+
+// threadMessage возвращает комментарий с его местом в ветке
+func threadMessage(ctx context.Context, commentID int, username string) (models.CommentWithUsername, error) {
+	comment, err := models.GetCommentByID(ctx, commentID)
+	if err != nil {
+		return models.CommentWithUsername{}, err
+	}
+
+	depth, err := models.GetCommentDepth(ctx, commentID)
+	if err != nil {
+		return models.CommentWithUsername{}, err
+	}
+
+	return models.CommentWithUsername{
+		ID:        comment.ID,
+		Content:   comment.Content,
+		AuthorId:  comment.AuthorId,
+		TopicId:   comment.TopicId,
+		ParentId:  comment.ParentId,
+		Depth:     depth,
+		CreatedAt: comment.CreatedAt,
+		UpdatedAt: comment.UpdatedAt,
+		Username:  username,
+	}, nil
 }
@@ -12,6 +12,7 @@ import (
 	"time"
 
 	"github.com/HedgeHogSE/forum/backend/forum/internal/db"
+	"github.com/HedgeHogSE/forum/backend/forum/internal/external"
 	"github.com/HedgeHogSE/forum/backend/forum/internal/models"
 	ws "github.com/HedgeHogSE/forum/backend/forum/internal/websocket"
 	gorilla "github.com/gorilla/websocket"
@@ -27,6 +28,20 @@ var upgrader = gorilla.Upgrader{
 var testUserID int
 var testTopicID int
 
+const testToken = "test-token"
+
+// mockValidateToken подменяет проверку токена: валиден только testToken
+func mockValidateToken(t *testing.T) {
+	originalFunc := external.ValidateToken
+	t.Cleanup(func() { external.ValidateToken = originalFunc })
+	external.ValidateToken = func(_ context.Context, token string) (*external.Identity, error) {
+		if token != testToken {
+			return nil, external.ErrInvalidToken
+		}
+		return &external.Identity{UserID: testUserID, Username: "test_user"}, nil
+	}
+}
+
 // setupTestServer создает тестовый HTTP сервер с WebSocket handler
 func setupTestServer(t *testing.T) *httptest.Server {
 	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -36,7 +51,7 @@ func setupTestServer(t *testing.T) *httptest.Server {
 
 // connectWebSocket подключается к WebSocket серверу с таймаутом
 func connectWebSocket(t *testing.T, server *httptest.Server, topicID int) *gorilla.Conn {
-	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "?topic=" + strconv.Itoa(topicID)
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "?topic=" + strconv.Itoa(topicID) + "&token=" + testToken
 
 	// Создаем контекст с таймаутом
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -68,6 +83,31 @@ func connectWebSocket(t *testing.T, server *httptest.Server, topicID int) *goril
 	}
 }
 
+// readEvent читает сообщения до события нужного типа, пропуская остальные (presence, typing)
+func readEvent(t *testing.T, conn *gorilla.Conn, eventType string) ws.Envelope {
+	t.Helper()
+	for {
+		_, message, err := conn.ReadMessage()
+		require.NoError(t, err)
+
+		var env ws.Envelope
+		require.NoError(t, json.Unmarshal(message, &env))
+		assert.Equal(t, ws.ProtocolVersion, env.Version)
+		if env.Type == eventType {
+			return env
+		}
+	}
+}
+
+// sendEvent отправляет запрос в конверте протокола
+func sendEvent(t *testing.T, conn *gorilla.Conn, eventType, id string, data interface{}) {
+	t.Helper()
+	raw, err := json.Marshal(data)
+	require.NoError(t, err)
+	err = conn.WriteJSON(ws.Envelope{Version: ws.ProtocolVersion, Type: eventType, ID: id, Data: raw})
+	require.NoError(t, err)
+}
+
 // setupTestDB настраивает тестовую БД
 func setupTestDB(t *testing.T) {
 	// Устанавливаем тестовые значения для БД
@@ -91,6 +131,8 @@ func setupTestDB(t *testing.T) {
 
 	// Создаем тестовый топик
 	createTestTopic(t)
+
+	mockValidateToken(t)
 }
 
 // createTestUser создает тестового пользователя
@@ -131,22 +173,28 @@ func TestWebSocketConnection(t *testing.T) {
 	defer server.Close()
 
 	// Подключаемся к WebSocket
-	ws := connectWebSocket(t, server, testTopicID)
-	if ws == nil {
+	conn := connectWebSocket(t, server, testTopicID)
+	if conn == nil {
 		return
 	}
-	defer ws.Close()
+	defer conn.Close()
 
 	// Устанавливаем таймаут для чтения
-	ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
 
-	// Проверяем, что получили историю сообщений
-	_, message, err := ws.ReadMessage()
+	// Первым приходит событие с историей сообщений
+	_, message, err := conn.ReadMessage()
 	require.NoError(t, err)
 
-	// Проверяем, что сообщение - это JSON массив
+	var env ws.Envelope
+	require.NoError(t, json.Unmarshal(message, &env))
+	assert.Equal(t, ws.ProtocolVersion, env.Version)
+	assert.Equal(t, ws.EventHistory, env.Type)
+	assert.Equal(t, testTopicID, env.TopicID)
+
+	// История - JSON массив комментариев
 	var messages []models.CommentWithUsername
-	err = json.Unmarshal(message, &messages)
+	err = json.Unmarshal(env.Data, &messages)
 	require.NoError(t, err)
 }
 
@@ -174,35 +222,35 @@ func TestWebSocketMessageExchange(t *testing.T) {
 	ws2.SetReadDeadline(time.Now().Add(5 * time.Second))
 
 	// Пропускаем историю сообщений
-	_, _, err := ws1.ReadMessage()
-	require.NoError(t, err)
-	_, _, err = ws2.ReadMessage()
-	require.NoError(t, err)
+	readEvent(t, ws1, ws.EventHistory)
+	readEvent(t, ws2, ws.EventHistory)
+
+	// Первый клиент видит подключение второго
+	env := readEvent(t, ws1, ws.EventPresenceJoin)
+	var presence ws.UserData
+	require.NoError(t, json.Unmarshal(env.Data, &presence))
+	assert.Equal(t, testUserID, presence.UserID)
+	assert.Equal(t, 2, presence.Online)
 
 	// Отправляем сообщение от первого клиента
-	message := map[string]interface{}{
-		"content":   "test message",
-		"topic_id":  testTopicID,
-		"author_id": testUserID,
-		"username":  "test_user",
-	}
-	messageBytes, err := json.Marshal(message)
-	require.NoError(t, err)
+	sendEvent(t, ws1, ws.EventCommentCreate, "req-1", ws.CommentCreateData{Content: "test message"})
 
-	err = ws1.WriteMessage(gorilla.TextMessage, messageBytes)
-	require.NoError(t, err)
+	// Автор получает подтверждение с ID своего запроса
+	env = readEvent(t, ws1, ws.EventAck)
+	assert.Equal(t, "req-1", env.ID)
+	var ack ws.AckData
+	require.NoError(t, json.Unmarshal(env.Data, &ack))
+	assert.NotZero(t, ack.CommentID)
 
 	// Проверяем, что второй клиент получил сообщение
-	_, receivedMessage, err := ws2.ReadMessage()
-	require.NoError(t, err)
-
-	var received map[string]interface{}
-	err = json.Unmarshal(receivedMessage, &received)
-	require.NoError(t, err)
-	assert.Equal(t, message["content"], received["content"])
-	assert.Equal(t, float64(message["topic_id"].(int)), received["topic_id"].(float64))
-	assert.Equal(t, float64(message["author_id"].(int)), received["author_id"].(float64))
-	assert.Equal(t, message["username"], received["username"])
+	env = readEvent(t, ws2, ws.EventCommentCreated)
+	var received models.CommentWithUsername
+	require.NoError(t, json.Unmarshal(env.Data, &received))
+	assert.Equal(t, ack.CommentID, received.ID)
+	assert.Equal(t, "test message", received.Content)
+	assert.Equal(t, testTopicID, received.TopicId)
+	assert.Equal(t, testUserID, received.AuthorId)
+	assert.Equal(t, "test_user", received.Username)
 }
 
 func TestWebSocketInvalidTopicID(t *testing.T) {
@@ -212,7 +260,7 @@ func TestWebSocketInvalidTopicID(t *testing.T) {
 	defer server.Close()
 
 	// Пытаемся подключиться с неверным topic ID
-	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "?topic=invalid"
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "?topic=invalid&token=" + testToken
 
 	// Создаем контекст с таймаутом
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -254,73 +302,142 @@ func TestWebSocketMultipleClients(t *testing.T) {
 		clients[i].SetReadDeadline(time.Now().Add(5 * time.Second))
 
 		// Пропускаем историю сообщений
-		_, _, err := clients[i].ReadMessage()
-		require.NoError(t, err)
+		readEvent(t, clients[i], ws.EventHistory)
 	}
 
 	// Отправляем сообщение от первого клиента
-	message := map[string]interface{}{
-		"content":   "broadcast message",
-		"topic_id":  testTopicID,
-		"author_id": testUserID,
-		"username":  "test_user",
+	sendEvent(t, clients[0], ws.EventCommentCreate, "", ws.CommentCreateData{Content: "broadcast message"})
+
+	// Проверяем, что все клиенты, включая автора, получили сообщение
+	for i := 0; i < 3; i++ {
+		env := readEvent(t, clients[i], ws.EventCommentCreated)
+
+		var received models.CommentWithUsername
+		require.NoError(t, json.Unmarshal(env.Data, &received))
+		assert.Equal(t, "broadcast message", received.Content)
 	}
-	messageBytes, err := json.Marshal(message)
+}
+
+func TestWebSocketTyping(t *testing.T) {
+	setupTestDB(t)
+
+	server := setupTestServer(t)
+	defer server.Close()
+
+	ws1 := connectWebSocket(t, server, testTopicID)
+	defer ws1.Close()
+	ws2 := connectWebSocket(t, server, testTopicID)
+	defer ws2.Close()
+
+	ws1.SetReadDeadline(time.Now().Add(5 * time.Second))
+	ws2.SetReadDeadline(time.Now().Add(5 * time.Second))
+	readEvent(t, ws1, ws.EventHistory)
+	readEvent(t, ws2, ws.EventHistory)
+
+	sendEvent(t, ws1, ws.EventTyping, "", nil)
+
+	env := readEvent(t, ws2, ws.EventTyping)
+	var typing ws.UserData
+	require.NoError(t, json.Unmarshal(env.Data, &typing))
+	assert.Equal(t, "test_user", typing.Username)
+
+	// При отключении клиента остальные получают presence.leave
+	ws1.Close()
+	env = readEvent(t, ws2, ws.EventPresenceLeave)
+	var presence ws.UserData
+	require.NoError(t, json.Unmarshal(env.Data, &presence))
+	assert.Equal(t, 1, presence.Online)
+}
+
+func TestWebSocketNotifyComment(t *testing.T) {
+	setupTestDB(t)
+
+	server := setupTestServer(t)
+	defer server.Close()
+
+	id, err := models.AddComment(context.Background(), &models.Comment{Content: "original", TopicId: testTopicID, AuthorId: testUserID})
 	require.NoError(t, err)
 
-	err = clients[0].WriteMessage(gorilla.TextMessage, messageBytes)
+	originalFunc := external.GetUsernameByUserID
+	defer func() { external.GetUsernameByUserID = originalFunc }()
+	external.GetUsernameByUserID = func(ctx context.Context, userID int) (string, error) {
+		return "test_user", nil
+	}
+
+	conn := connectWebSocket(t, server, testTopicID)
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	readEvent(t, conn, ws.EventHistory)
+
+	// Комментарий, созданный через REST, доходит до подписчиков топика
+	replyID, err := models.AddComment(context.Background(), &models.Comment{Content: "reply", TopicId: testTopicID, AuthorId: testUserID, ParentId: &id})
 	require.NoError(t, err)
+	ws.NotifyCommentCreated(context.Background(), replyID)
 
-	// Проверяем, что все остальные клиенты получили сообщение
-	for i := 1; i < 3; i++ {
-		_, receivedMessage, err := clients[i].ReadMessage()
-		require.NoError(t, err)
+	env := readEvent(t, conn, ws.EventCommentCreated)
+	var created models.CommentWithUsername
+	require.NoError(t, json.Unmarshal(env.Data, &created))
+	assert.Equal(t, replyID, created.ID)
+	assert.Equal(t, "test_user", created.Username)
 
-		var received map[string]interface{}
-		err = json.Unmarshal(receivedMessage, &received)
-		require.NoError(t, err)
-		assert.Equal(t, message["content"], received["content"])
-	}
+	// Изменение через REST доходит до подписчиков топика
+	_, err = models.PutComment(context.Background(), id, models.Comment{Content: "edited", TopicId: testTopicID, AuthorId: testUserID})
+	require.NoError(t, err)
+	ws.NotifyCommentUpdated(context.Background(), id)
+
+	env = readEvent(t, conn, ws.EventCommentUpdated)
+	var updated models.CommentWithUsername
+	require.NoError(t, json.Unmarshal(env.Data, &updated))
+	assert.Equal(t, id, updated.ID)
+	assert.Equal(t, "edited", updated.Content)
+
+	ws.NotifyCommentDeleted(testTopicID, id)
+
+	env = readEvent(t, conn, ws.EventCommentDeleted)
+	var deleted ws.CommentDeletedData
+	require.NoError(t, json.Unmarshal(env.Data, &deleted))
+	assert.Equal(t, id, deleted.ID)
 }
 
-func TestWebSocketOldMessagesCleanup(t *testing.T) {
+func TestWebSocketKeepsOldMessages(t *testing.T) {
 	setupTestDB(t)
 
 	server := setupTestServer(t)
 	defer server.Close()
 
 	// Создаем старый комментарий
-	oldComment := &models.Comment{
-		Content:   "old message",
-		TopicId:   testTopicID,
-		AuthorId:  testUserID,
-		CreatedAt: time.Now().AddDate(0, 0, -15), // 15 дней назад
-	}
-	_, err := models.AddComment(oldComment)
+	id, err := models.AddComment(context.Background(), &models.Comment{
+		Content:  "old message",
+		TopicId:  testTopicID,
+		AuthorId: testUserID,
+	})
+	require.NoError(t, err)
+	_, err = db.Db.Exec("UPDATE comments SET created_at = $1 WHERE id = $2", time.Now().AddDate(0, 0, -15), id)
 	require.NoError(t, err)
 
 	// Подключаемся к WebSocket
-	ws := connectWebSocket(t, server, testTopicID)
-	if ws == nil {
+	conn := connectWebSocket(t, server, testTopicID)
+	if conn == nil {
 		return
 	}
-	defer ws.Close()
+	defer conn.Close()
 
 	// Устанавливаем таймаут для чтения
-	ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
 
 	// Получаем историю сообщений
-	_, message, err := ws.ReadMessage()
-	require.NoError(t, err)
+	env := readEvent(t, conn, ws.EventHistory)
 
 	var messages []models.CommentWithUsername
-	err = json.Unmarshal(message, &messages)
+	err = json.Unmarshal(env.Data, &messages)
 	require.NoError(t, err)
 
-	// Проверяем, что старый комментарий был удален
-	for _, msg := range messages {
-		assert.NotEqual(t, "old message", msg.Content)
-	}
+	// Подключение не удаляет комментарии - этим занимаются политики хранения
+	require.Len(t, messages, 1)
+	assert.Equal(t, "old message", messages[0].Content)
+
+	_, err = models.GetCommentByID(context.Background(), id)
+	assert.NoError(t, err)
 }
 
 func TestWebSocketInvalidMessage(t *testing.T) {
@@ -330,25 +447,127 @@ func TestWebSocketInvalidMessage(t *testing.T) {
 	defer server.Close()
 
 	// Подключаемся к WebSocket
-	ws := connectWebSocket(t, server, testTopicID)
-	if ws == nil {
+	conn := connectWebSocket(t, server, testTopicID)
+	if conn == nil {
 		return
 	}
-	defer ws.Close()
+	defer conn.Close()
 
 	// Устанавливаем таймаут для чтения
-	ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
 
 	// Пропускаем историю сообщений
-	_, _, err := ws.ReadMessage()
-	require.NoError(t, err)
+	readEvent(t, conn, ws.EventHistory)
 
 	// Отправляем неверное сообщение
 	invalidMessage := []byte("invalid json")
-	err = ws.WriteMessage(gorilla.TextMessage, invalidMessage)
+	err := conn.WriteMessage(gorilla.TextMessage, invalidMessage)
+	require.NoError(t, err)
+
+	env := readEvent(t, conn, ws.EventError)
+	var errData ws.ErrorData
+	require.NoError(t, json.Unmarshal(env.Data, &errData))
+	assert.Equal(t, ws.ErrCodeBadMessage, errData.Code)
+
+	// Неподдерживаемая версия протокола и неизвестный тип события
+	err = conn.WriteJSON(ws.Envelope{Version: ws.ProtocolVersion + 1, Type: ws.EventTyping, ID: "v"})
 	require.NoError(t, err)
+	env = readEvent(t, conn, ws.EventError)
+	assert.Equal(t, "v", env.ID)
+
+	sendEvent(t, conn, "unknown", "u", nil)
+	env = readEvent(t, conn, ws.EventError)
+	assert.Equal(t, "u", env.ID)
+	require.NoError(t, json.Unmarshal(env.Data, &errData))
+	assert.Equal(t, ws.ErrCodeUnknownType, errData.Code)
 
 	// Проверяем, что соединение все еще активно
-	err = ws.WriteMessage(gorilla.PingMessage, nil)
+	err = conn.WriteMessage(gorilla.PingMessage, nil)
+	require.NoError(t, err)
+}
+
+func TestWebSocketRequireVerifiedEmail(t *testing.T) {
+	setupTestDB(t)
+
+	ws.SetRequireVerifiedEmail(true)
+	defer ws.SetRequireVerifiedEmail(false)
+
+	server := setupTestServer(t)
+	defer server.Close()
+
+	conn := connectWebSocket(t, server, testTopicID)
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	readEvent(t, conn, ws.EventHistory)
+
+	// Тестовый пользователь не подтвердил email, комментарий отклоняется
+	sendEvent(t, conn, ws.EventCommentCreate, "c", ws.CommentCreateData{Content: "hello"})
+	env := readEvent(t, conn, ws.EventError)
+	assert.Equal(t, "c", env.ID)
+	var errData ws.ErrorData
+	require.NoError(t, json.Unmarshal(env.Data, &errData))
+	assert.Equal(t, ws.ErrCodeUnverified, errData.Code)
+}
+
+func TestWebSocketAuthentication(t *testing.T) {
+	mockValidateToken(t)
+
+	server := setupTestServer(t)
+	defer server.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "?topic=1"
+
+	// Без токена подключение отклоняется до обращения к БД
+	_, resp, err := gorilla.DefaultDialer.Dial(wsURL, nil)
+	require.Error(t, err)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	// Неверный токен
+	_, resp, err = gorilla.DefaultDialer.Dial(wsURL+"&token=bad", nil)
+	require.Error(t, err)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	// Неверный токен в подпротоколе
+	dialer := gorilla.Dialer{Subprotocols: []string{"bearer", "bad"}}
+	_, resp, err = dialer.Dial(wsURL, nil)
+	require.Error(t, err)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
+
+func TestWebSocketSubprotocolToken(t *testing.T) {
+	setupTestDB(t)
+
+	server := setupTestServer(t)
+	defer server.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "?topic=" + strconv.Itoa(testTopicID)
+	dialer := gorilla.Dialer{Subprotocols: []string{"bearer", testToken}}
+	conn, _, err := dialer.Dial(wsURL, nil)
 	require.NoError(t, err)
+	defer conn.Close()
+
+	// Сервер выбирает подпротокол bearer, сам токен не возвращается
+	assert.Equal(t, "bearer", conn.Subprotocol())
+}
+
+func TestWebSocketAllowedOrigins(t *testing.T) {
+	mockValidateToken(t)
+
+	server := setupTestServer(t)
+	defer server.Close()
+	defer ws.SetAllowedOrigins(nil)
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "?topic=invalid&token=" + testToken
+	header := http.Header{"Origin": {"https://evil.example"}}
+
+	// Чужой Origin отклоняется при пустом списке
+	_, resp, err := gorilla.DefaultDialer.Dial(wsURL, header)
+	require.Error(t, err)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	// Разрешенный Origin проходит проверку и доходит до разбора топика
+	ws.SetAllowedOrigins([]string{"https://evil.example"})
+	_, resp, err = gorilla.DefaultDialer.Dial(wsURL, header)
+	require.Error(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
 }
@@ -0,0 +1,4 @@
+ALTER TABLE comments
+    ADD COLUMN parent_id INTEGER REFERENCES comments(id) ON DELETE CASCADE;
+
+CREATE INDEX idx_comments_parent_id ON comments(parent_id);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_comments_topic_id;
+
+DROP INDEX IF EXISTS idx_topics_created_at;
@@ -0,0 +1,3 @@
+CREATE INDEX idx_topics_created_at ON topics(created_at DESC, id DESC);
+
+CREATE INDEX idx_comments_topic_id ON comments(topic_id, created_at);
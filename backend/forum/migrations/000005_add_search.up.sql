@@ -0,0 +1,14 @@
+ALTER TABLE topics
+    ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (
+        setweight(to_tsvector('simple', coalesce(title, '')), 'A') ||
+        setweight(to_tsvector('simple', coalesce(description, '')), 'B')
+    ) STORED;
+
+CREATE INDEX idx_topics_search_vector ON topics USING GIN (search_vector);
+
+ALTER TABLE comments
+    ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (
+        to_tsvector('simple', content)
+    ) STORED;
+
+CREATE INDEX idx_comments_search_vector ON comments USING GIN (search_vector);
@@ -0,0 +1,9 @@
+-- Сообщения WebSocket, не помещающиеся в NOTIFY (лимит 8000 байт).
+-- Хранятся недолго: экземпляры читают их сразу после уведомления
+CREATE TABLE ws_messages (
+    id BIGSERIAL PRIMARY KEY,
+    payload TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_ws_messages_created_at ON ws_messages(created_at);
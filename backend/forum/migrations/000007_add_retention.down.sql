@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS comments_archive;
+DROP TABLE IF EXISTS retention_policies;
@@ -0,0 +1,26 @@
+-- Политики хранения комментариев. Политика с topic_id = NULL - глобальная,
+-- она действует для топиков без собственной политики
+CREATE TABLE retention_policies (
+    id SERIAL PRIMARY KEY,
+    topic_id INT UNIQUE REFERENCES topics(id) ON DELETE CASCADE,
+    action VARCHAR(16) NOT NULL CHECK (action IN ('keep', 'archive', 'delete')),
+    after_days INT NOT NULL DEFAULT 0 CHECK (after_days >= 0),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Глобальная политика может быть только одна
+CREATE UNIQUE INDEX idx_retention_policies_global ON retention_policies ((topic_id IS NULL)) WHERE topic_id IS NULL;
+
+CREATE TABLE comments_archive (
+    id INT PRIMARY KEY,
+    content TEXT NOT NULL,
+    author_id INT NOT NULL,
+    topic_id INT NOT NULL,
+    parent_id INT,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    archived_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_comments_archive_topic_id ON comments_archive(topic_id);
@@ -0,0 +1,17 @@
+DROP TRIGGER IF EXISTS comments_update_activity ON comments;
+DROP TRIGGER IF EXISTS comments_delete_activity ON comments;
+DROP TRIGGER IF EXISTS comments_insert_activity ON comments;
+DROP TRIGGER IF EXISTS topics_set_activity ON topics;
+
+DROP FUNCTION IF EXISTS comments_update_activity();
+DROP FUNCTION IF EXISTS comments_delete_activity();
+DROP FUNCTION IF EXISTS comments_insert_activity();
+DROP FUNCTION IF EXISTS refresh_topic_activity(INTEGER[]);
+DROP FUNCTION IF EXISTS topics_set_activity();
+
+DROP INDEX IF EXISTS idx_topics_last_activity_at;
+DROP INDEX IF EXISTS idx_topics_comments_count;
+
+ALTER TABLE topics
+    DROP COLUMN IF EXISTS last_activity_at,
+    DROP COLUMN IF EXISTS comments_count;
@@ -0,0 +1,79 @@
+-- Число комментариев и время последней активности хранятся в топике и обновляются
+-- триггерами, чтобы список топиков читал страницу по индексу, не агрегируя все комментарии
+ALTER TABLE topics
+    ADD COLUMN comments_count INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN last_activity_at TIMESTAMP WITH TIME ZONE;
+
+UPDATE topics t SET
+    comments_count = (SELECT count(*) FROM comments c WHERE c.topic_id = t.id),
+    last_activity_at = GREATEST(t.updated_at, (SELECT max(c.created_at) FROM comments c WHERE c.topic_id = t.id));
+
+CREATE INDEX idx_topics_comments_count ON topics(comments_count DESC, id DESC);
+CREATE INDEX idx_topics_last_activity_at ON topics(last_activity_at DESC, id DESC);
+
+-- Активность топика - время его изменения или последнего комментария
+CREATE FUNCTION topics_set_activity() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        NEW.comments_count := 0;
+        NEW.last_activity_at := NEW.updated_at;
+    ELSE
+        NEW.last_activity_at := GREATEST(NEW.last_activity_at, NEW.updated_at);
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER topics_set_activity
+    BEFORE INSERT OR UPDATE OF updated_at ON topics
+    FOR EACH ROW EXECUTE FUNCTION topics_set_activity();
+
+-- Пересчет по индексу idx_comments_topic_id для топиков, из которых комментарии
+-- удалены или перенесены
+CREATE FUNCTION refresh_topic_activity(topic_ids INTEGER[]) RETURNS void AS $$
+    UPDATE topics t SET
+        comments_count = (SELECT count(*) FROM comments c WHERE c.topic_id = t.id),
+        last_activity_at = GREATEST(t.updated_at, (SELECT max(c.created_at) FROM comments c WHERE c.topic_id = t.id))
+    WHERE t.id = ANY(topic_ids);
+$$ LANGUAGE sql;
+
+CREATE FUNCTION comments_insert_activity() RETURNS trigger AS $$
+BEGIN
+    UPDATE topics SET
+        comments_count = comments_count + 1,
+        last_activity_at = GREATEST(last_activity_at, NEW.created_at)
+    WHERE id = NEW.topic_id;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER comments_insert_activity
+    AFTER INSERT ON comments
+    FOR EACH ROW EXECUTE FUNCTION comments_insert_activity();
+
+-- Политики хранения удаляют комментарии пачками, поэтому пересчет выполняется
+-- один раз на оператор
+CREATE FUNCTION comments_delete_activity() RETURNS trigger AS $$
+BEGIN
+    PERFORM refresh_topic_activity(ARRAY(SELECT DISTINCT topic_id FROM deleted_comments));
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER comments_delete_activity
+    AFTER DELETE ON comments
+    REFERENCING OLD TABLE AS deleted_comments
+    FOR EACH STATEMENT EXECUTE FUNCTION comments_delete_activity();
+
+CREATE FUNCTION comments_update_activity() RETURNS trigger AS $$
+BEGIN
+    PERFORM refresh_topic_activity(ARRAY[OLD.topic_id, NEW.topic_id]);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER comments_update_activity
+    AFTER UPDATE OF topic_id, created_at ON comments
+    FOR EACH ROW
+    WHEN (OLD.topic_id IS DISTINCT FROM NEW.topic_id OR OLD.created_at IS DISTINCT FROM NEW.created_at)
+    EXECUTE FUNCTION comments_update_activity();
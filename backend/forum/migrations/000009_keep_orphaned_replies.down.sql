@@ -0,0 +1,4 @@
+ALTER TABLE comments
+    DROP CONSTRAINT comments_parent_id_fkey,
+    ADD CONSTRAINT comments_parent_id_fkey
+        FOREIGN KEY (parent_id) REFERENCES comments(id) ON DELETE CASCADE;
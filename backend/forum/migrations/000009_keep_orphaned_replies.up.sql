@@ -0,0 +1,5 @@
+-- Удаление комментария не удаляет ответы других пользователей: они становятся корневыми
+ALTER TABLE comments
+    DROP CONSTRAINT comments_parent_id_fkey,
+    ADD CONSTRAINT comments_parent_id_fkey
+        FOREIGN KEY (parent_id) REFERENCES comments(id) ON DELETE SET NULL;
@@ -0,0 +1,197 @@
+// Package health реализует проверки живости и готовности сервиса: HTTP эндпоинты
+// /healthz и /readyz и статус стандартного gRPC сервиса grpc.health.v1.
+package health
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// Check - проверка одной зависимости. Возвращает ошибку, если зависимость недоступна
+type Check func(ctx context.Context) error
+
+// Report - результат проверок: общий статус и статус каждой проверки
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks"`
+}
+
+// OK сообщает, прошли ли все проверки
+func (r Report) OK() bool {
+	return r.Status == StatusOK
+}
+
+// Checker хранит проверки готовности сервиса. Проверки добавляются при запуске,
+// до начала обработки запросов
+type Checker struct {
+	timeout time.Duration
+	names   []string
+	checks  map[string]Check
+}
+
+// New создает Checker. На выполнение всех проверок отводится timeout
+func New(timeout time.Duration) *Checker {
+	return &Checker{timeout: timeout, checks: make(map[string]Check)}
+}
+
+// Add добавляет проверку с именем name
+func (c *Checker) Add(name string, check Check) {
+	if _, ok := c.checks[name]; !ok {
+		c.names = append(c.names, name)
+	}
+	c.checks[name] = check
+}
+
+// Run параллельно выполняет проверки names, а если они не заданы - все проверки
+func (c *Checker) Run(ctx context.Context, names ...string) Report {
+	if len(names) == 0 {
+		names = c.names
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	report := Report{Status: StatusOK, Checks: make(map[string]string, len(names))}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, name := range names {
+		check, ok := c.checks[name]
+		if !ok {
+			check = func(context.Context) error { return errors.New("неизвестная проверка") }
+		}
+		wg.Add(1)
+		go func(name string, check Check) {
+			defer wg.Done()
+			result := StatusOK
+			if err := check(ctx); err != nil {
+				log.Warn().Err(err).Str("check", name).Msg("Health check failed")
+				result = StatusFail
+			}
+			mu.Lock()
+			report.Checks[name] = result
+			if result != StatusOK {
+				report.Status = StatusFail
+			}
+			mu.Unlock()
+		}(name, check)
+	}
+	wg.Wait()
+	return report
+}
+
+// Liveness отвечает 200, пока процесс способен обрабатывать запросы.
+// Зависимости не проверяются, чтобы их сбой не приводил к перезапуску сервиса
+func Liveness(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": StatusOK})
+}
+
+// Readiness выполняет все проверки и отвечает 200, если они прошли, иначе 503.
+// Причины сбоев пишутся в лог, в ответе только статусы проверок
+func (c *Checker) Readiness(ctx *gin.Context) {
+	report := c.Run(ctx.Request.Context())
+	code := http.StatusOK
+	if !report.OK() {
+		code = http.StatusServiceUnavailable
+	}
+	ctx.JSON(code, report)
+}
+
+// WatchGRPC периодически выполняет проверки names и выставляет в srv статус
+// SERVING или NOT_SERVING для сервера в целом и для сервисов services.
+// Блокируется до отмены ctx, после чего переводит все сервисы в NOT_SERVING,
+// чтобы балансировщик перестал направлять запросы до остановки сервера
+func (c *Checker) WatchGRPC(ctx context.Context, srv *grpchealth.Server, interval time.Duration, services []string, names ...string) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	serving := healthpb.HealthCheckResponse_UNKNOWN
+	for {
+		next := healthpb.HealthCheckResponse_SERVING
+		if !c.Run(ctx, names...).OK() {
+			next = healthpb.HealthCheckResponse_NOT_SERVING
+		}
+		// Проверки, прерванные остановкой, не должны менять статус
+		if next != serving && ctx.Err() == nil {
+			log.Info().Str("status", next.String()).Msg("gRPC health status changed")
+			srv.SetServingStatus("", next)
+			for _, service := range services {
+				srv.SetServingStatus(service, next)
+			}
+			serving = next
+		}
+
+		select {
+		case <-ctx.Done():
+			srv.Shutdown()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Database проверяет соединение с БД
+func Database(db *sql.DB) Check {
+	return func(ctx context.Context) error {
+		if err := db.PingContext(ctx); err != nil {
+			return fmt.Errorf("БД недоступна: %w", err)
+		}
+		return nil
+	}
+}
+
+// Migrations проверяет, что схема БД в таблице миграций golang-migrate table
+// имеет версию не ниже expected и последняя миграция применена полностью.
+// Более новая версия допустима: при обновлении миграции применяются до выкладки сервиса
+func Migrations(db *sql.DB, table string, expected uint) Check {
+	query := fmt.Sprintf(`SELECT version, dirty FROM "%s" LIMIT 1`, strings.ReplaceAll(table, `"`, `""`))
+	return func(ctx context.Context) error {
+		var version uint
+		var dirty bool
+		err := db.QueryRowContext(ctx, query).Scan(&version, &dirty)
+		if errors.Is(err, sql.ErrNoRows) {
+			return errors.New("миграции не применены")
+		}
+		if err != nil {
+			return fmt.Errorf("не удалось получить версию схемы: %w", err)
+		}
+		if dirty {
+			return fmt.Errorf("миграция %d применена не полностью", version)
+		}
+		if version < expected {
+			return fmt.Errorf("версия схемы %d, ожидается %d", version, expected)
+		}
+		return nil
+	}
+}
+
+// PingGRPC проверяет доступность gRPC сервера через соединение conn. Сервер считается
+// доступным, если он ответил, даже если его собственные проверки не прошли:
+// иначе сбой одного сервиса выводил бы из балансировки и его соседей
+func PingGRPC(ctx context.Context, conn *grpc.ClientConn) error {
+	_, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	switch status.Code(err) {
+	case codes.OK, codes.NotFound, codes.Unimplemented:
+		return nil
+	default:
+		return err
+	}
+}
@@ -0,0 +1,173 @@
+package health_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"forum/backend/health"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func ok(context.Context) error { return nil }
+
+func fail(context.Context) error { return errors.New("connection refused") }
+
+func serve(t *testing.T, checker *health.Checker, path string) (int, health.Report) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/healthz", health.Liveness)
+	router.GET("/readyz", checker.Readiness)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	var report health.Report
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+	return rec.Code, report
+}
+
+func TestReadiness(t *testing.T) {
+	checker := health.New(time.Second)
+	checker.Add("database", ok)
+	checker.Add("migrations", ok)
+
+	code, report := serve(t, checker, "/readyz")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, health.Report{
+		Status: health.StatusOK,
+		Checks: map[string]string{"database": health.StatusOK, "migrations": health.StatusOK},
+	}, report)
+
+	checker.Add("peer_grpc", fail)
+	code, report = serve(t, checker, "/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, health.StatusFail, report.Status)
+	assert.Equal(t, health.StatusFail, report.Checks["peer_grpc"])
+	assert.Equal(t, health.StatusOK, report.Checks["database"])
+}
+
+func TestReadinessTimeout(t *testing.T) {
+	checker := health.New(50 * time.Millisecond)
+	checker.Add("slow", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	start := time.Now()
+	code, _ := serve(t, checker, "/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestLivenessIgnoresChecks(t *testing.T) {
+	checker := health.New(time.Second)
+	checker.Add("database", fail)
+
+	code, report := serve(t, checker, "/healthz")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, health.StatusOK, report.Status)
+}
+
+func TestRunSelectedChecks(t *testing.T) {
+	checker := health.New(time.Second)
+	checker.Add("database", ok)
+	checker.Add("peer_grpc", fail)
+
+	assert.True(t, checker.Run(context.Background(), "database").OK())
+	assert.False(t, checker.Run(context.Background()).OK())
+	assert.False(t, checker.Run(context.Background(), "unknown").OK())
+}
+
+func TestWatchGRPC(t *testing.T) {
+	var dbUp atomic.Bool
+	dbUp.Store(true)
+	checker := health.New(time.Second)
+	checker.Add("database", func(context.Context) error {
+		if !dbUp.Load() {
+			return errors.New("connection refused")
+		}
+		return nil
+	})
+	checker.Add("peer_grpc", fail)
+
+	srv := grpchealth.NewServer()
+	statusOf := func(service string) healthpb.HealthCheckResponse_ServingStatus {
+		resp, err := srv.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+		if err != nil {
+			return healthpb.HealthCheckResponse_UNKNOWN
+		}
+		return resp.GetStatus()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		checker.WatchGRPC(ctx, srv, 10*time.Millisecond, []string{"proto.TestService"}, "database")
+		close(done)
+	}()
+
+	// Сбой проверки, не указанной в names, не влияет на статус
+	require.Eventually(t, func() bool {
+		return statusOf("proto.TestService") == healthpb.HealthCheckResponse_SERVING
+	}, time.Second, 5*time.Millisecond)
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, statusOf(""))
+
+	dbUp.Store(false)
+	require.Eventually(t, func() bool {
+		return statusOf("") == healthpb.HealthCheckResponse_NOT_SERVING &&
+			statusOf("proto.TestService") == healthpb.HealthCheckResponse_NOT_SERVING
+	}, time.Second, 5*time.Millisecond)
+
+	dbUp.Store(true)
+	require.Eventually(t, func() bool {
+		return statusOf("") == healthpb.HealthCheckResponse_SERVING
+	}, time.Second, 5*time.Millisecond)
+
+	// После остановки сервер больше не принимает запросы
+	cancel()
+	<-done
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, statusOf(""))
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, statusOf("proto.TestService"))
+}
+
+func TestPingGRPC(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := lis.Addr().String()
+
+	healthServer := grpchealth.NewServer()
+	srv := grpc.NewServer()
+	healthpb.RegisterHealthServer(srv, healthServer)
+	go srv.Serve(lis)
+
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.NoError(t, health.PingGRPC(ctx, conn))
+
+	// Сервер доступен, даже если его собственные проверки не прошли
+	healthServer.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+	assert.NoError(t, health.PingGRPC(ctx, conn))
+
+	srv.Stop()
+	ctx, cancel = context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	assert.Error(t, health.PingGRPC(ctx, conn))
+}
@@ -0,0 +1,173 @@
+// Package lifecycle запускает компоненты сервиса (HTTP и gRPC серверы, фоновые воркеры)
+// и корректно останавливает их по сигналу или после фатальной ошибки одного из них.
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
+)
+
+// Component - часть сервиса с собственным жизненным циклом
+type Component struct {
+	Name string
+	// Start блокируется, пока компонент работает. Ошибка до начала остановки
+	// считается фатальной и останавливает весь сервис. Может быть nil,
+	// если компоненту нужна только остановка
+	Start func() error
+	// Stop останавливает компонент, завершая текущую работу до истечения ctx
+	Stop func(ctx context.Context) error
+}
+
+// Runner запускает компоненты и останавливает их в обратном порядке
+type Runner struct {
+	shutdownTimeout time.Duration
+	components      []Component
+	closers         []Component
+}
+
+// New создает Runner. На остановку всех компонентов отводится shutdownTimeout
+func New(shutdownTimeout time.Duration) *Runner {
+	return &Runner{shutdownTimeout: shutdownTimeout}
+}
+
+// Add добавляет компонент. Компоненты запускаются в порядке добавления,
+// а останавливаются в обратном: последним добавляется то, что принимает запросы
+func (r *Runner) Add(c Component) {
+	r.components = append(r.components, c)
+}
+
+// OnShutdown регистрирует функцию, которая вызывается после остановки всех
+// компонентов, например закрытие соединения с БД
+func (r *Runner) OnShutdown(name string, fn func() error) {
+	r.closers = append(r.closers, Component{Name: name, Stop: func(context.Context) error { return fn() }})
+}
+
+// Run запускает компоненты и ждет отмены ctx или первой ошибки компонента,
+// затем останавливает все компоненты. Возвращает эту ошибку или nil при штатной остановке
+func (r *Runner) Run(ctx context.Context) error {
+	errc := make(chan error, len(r.components))
+	for _, c := range r.components {
+		if c.Start == nil {
+			continue
+		}
+		go func(c Component) {
+			if err := c.Start(); err != nil {
+				errc <- fmt.Errorf("%s: %w", c.Name, err)
+			}
+		}(c)
+	}
+
+	var fatal error
+	select {
+	case <-ctx.Done():
+		log.Info().Msg("Shutdown requested")
+	case fatal = <-errc:
+		log.Error().Err(fatal).Msg("Component failed, shutting down")
+	}
+
+	stopCtx, cancel := context.WithTimeout(context.Background(), r.shutdownTimeout)
+	defer cancel()
+
+	for i := len(r.components) - 1; i >= 0; i-- {
+		r.stop(stopCtx, r.components[i])
+	}
+	for _, c := range r.closers {
+		r.stop(stopCtx, c)
+	}
+	return fatal
+}
+
+func (r *Runner) stop(ctx context.Context, c Component) {
+	if c.Stop == nil {
+		return
+	}
+	start := time.Now()
+	if err := c.Stop(ctx); err != nil {
+		log.Error().Err(err).Str("component", c.Name).Msg("Failed to stop component")
+		return
+	}
+	log.Info().Str("component", c.Name).Dur("took", time.Since(start)).Msg("Component stopped")
+}
+
+// HTTPServer - HTTP сервер. При остановке перестает принимать соединения
+// и дожидается завершения текущих запросов
+func HTTPServer(name string, srv *http.Server) Component {
+	return Component{
+		Name: name,
+		Start: func() error {
+			log.Info().Str("component", name).Str("addr", srv.Addr).Msg("Starting HTTP server")
+			if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+				return err
+			}
+			return nil
+		},
+		Stop: srv.Shutdown,
+	}
+}
+
+// GRPCServer - gRPC сервер на адресе addr. При остановке дожидается завершения
+// текущих вызовов, а по истечении срока прерывает их
+func GRPCServer(name string, srv *grpc.Server, addr string) Component {
+	return Component{
+		Name: name,
+		Start: func() error {
+			lis, err := net.Listen("tcp", addr)
+			if err != nil {
+				return err
+			}
+			log.Info().Str("component", name).Str("addr", addr).Msg("Starting gRPC server")
+			return srv.Serve(lis)
+		},
+		Stop: func(ctx context.Context) error {
+			done := make(chan struct{})
+			go func() {
+				srv.GracefulStop()
+				close(done)
+			}()
+			select {
+			case <-done:
+				return nil
+			case <-ctx.Done():
+				srv.Stop()
+				return ctx.Err()
+			}
+		},
+	}
+}
+
+// Worker - фоновая задача. run должна завершиться после отмены переданного ей контекста
+func Worker(name string, run func(ctx context.Context)) Component {
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	return Component{
+		Name: name,
+		Start: func() error {
+			defer wg.Done()
+			run(ctx)
+			return nil
+		},
+		Stop: func(stopCtx context.Context) error {
+			cancel()
+			done := make(chan struct{})
+			go func() {
+				wg.Wait()
+				close(done)
+			}()
+			select {
+			case <-done:
+				return nil
+			case <-stopCtx.Done():
+				return stopCtx.Err()
+			}
+		},
+	}
+}
@@ -0,0 +1,146 @@
+package lifecycle_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"forum/backend/lifecycle"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recorder запоминает порядок остановки компонентов
+type recorder struct {
+	mu      sync.Mutex
+	stopped []string
+}
+
+func (r *recorder) component(name string, start func() error) lifecycle.Component {
+	return lifecycle.Component{
+		Name:  name,
+		Start: start,
+		Stop: func(context.Context) error {
+			r.mu.Lock()
+			r.stopped = append(r.stopped, name)
+			r.mu.Unlock()
+			return nil
+		},
+	}
+}
+
+func blockUntil(ch <-chan struct{}) func() error {
+	return func() error {
+		<-ch
+		return nil
+	}
+}
+
+func TestRunStopsInReverseOrder(t *testing.T) {
+	rec := &recorder{}
+	done := make(chan struct{})
+	defer close(done)
+
+	r := lifecycle.New(time.Second)
+	r.Add(rec.component("worker", blockUntil(done)))
+	r.Add(rec.component("grpc", blockUntil(done)))
+	r.Add(rec.component("http", blockUntil(done)))
+	r.OnShutdown("db", func() error {
+		rec.mu.Lock()
+		rec.stopped = append(rec.stopped, "db")
+		rec.mu.Unlock()
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.NoError(t, r.Run(ctx))
+	assert.Equal(t, []string{"http", "grpc", "worker", "db"}, rec.stopped)
+}
+
+func TestRunPropagatesFirstError(t *testing.T) {
+	rec := &recorder{}
+	done := make(chan struct{})
+	defer close(done)
+	failure := errors.New("listen failed")
+
+	r := lifecycle.New(time.Second)
+	r.Add(rec.component("worker", blockUntil(done)))
+	r.Add(rec.component("http", func() error { return failure }))
+
+	err := r.Run(context.Background())
+	assert.ErrorIs(t, err, failure)
+	assert.Equal(t, []string{"http", "worker"}, rec.stopped)
+}
+
+func TestWorker(t *testing.T) {
+	started := make(chan struct{})
+	w := lifecycle.Worker("worker", func(ctx context.Context) {
+		close(started)
+		<-ctx.Done()
+	})
+
+	r := lifecycle.New(time.Second)
+	r.Add(w)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel()
+	}()
+	require.NoError(t, r.Run(ctx))
+}
+
+func TestHTTPServerDrainsRequests(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := lis.Addr().String()
+	lis.Close()
+
+	inFlight := make(chan struct{})
+	release := make(chan struct{})
+	srv := &http.Server{Addr: addr, Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(inFlight)
+		<-release
+		io.WriteString(w, "done")
+	})}
+
+	r := lifecycle.New(2 * time.Second)
+	r.Add(lifecycle.HTTPServer("http", srv))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runErr := make(chan error, 1)
+	go func() { runErr <- r.Run(ctx) }()
+
+	var resp *http.Response
+	respErr := make(chan error, 1)
+	go func() {
+		var err error
+		// Сервер запускается асинхронно, первые попытки могут не пройти
+		for i := 0; i < 100; i++ {
+			if resp, err = http.Get("http://" + addr); err == nil {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		respErr <- err
+	}()
+
+	<-inFlight
+	cancel()
+	// Начатый запрос завершается, несмотря на остановку
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+
+	require.NoError(t, <-respErr)
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, "done", string(body))
+	assert.NoError(t, <-runErr)
+}
@@ -0,0 +1,113 @@
+// Package metrics собирает метрики Prometheus, общие для всех сервисов: HTTP запросы,
+// вызовы gRPC и пул соединений с БД, и отдает их на эндпоинте /metrics.
+// Метрики предметной области объявляются в самих сервисах.
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// unmatchedRoute - значение метки route для запросов, не совпавших ни с одним маршрутом.
+// Исходный путь не используется, чтобы случайные URL не порождали новые временные ряды
+const unmatchedRoute = "unmatched"
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "Количество обработанных HTTP запросов",
+	}, []string{"method", "route", "status"})
+
+	httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "Время обработки HTTP запросов",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	grpcServerHandled = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "grpc_server_handled_total",
+		Help: "Количество обработанных gRPC вызовов",
+	}, []string{"method", "code"})
+
+	grpcServerDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "grpc_server_handling_seconds",
+		Help:    "Время обработки gRPC вызовов",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method"})
+
+	grpcClientHandled = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "grpc_client_handled_total",
+		Help: "Количество исходящих gRPC вызовов",
+	}, []string{"method", "code"})
+
+	grpcClientDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "grpc_client_handling_seconds",
+		Help:    "Время исходящих gRPC вызовов",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method"})
+)
+
+// Handler отдает метрики в формате Prometheus
+func Handler() gin.HandlerFunc {
+	return gin.WrapH(promhttp.Handler())
+}
+
+// HTTPMiddleware считает HTTP запросы и время их обработки по маршруту и статусу ответа.
+// В метке route используется шаблон маршрута, например /topics/:topic_id
+func HTTPMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+		labels := prometheus.Labels{
+			"method": c.Request.Method,
+			"route":  route,
+			"status": strconv.Itoa(c.Writer.Status()),
+		}
+		httpRequests.With(labels).Inc()
+		httpDuration.With(labels).Observe(time.Since(start).Seconds())
+	}
+}
+
+// UnaryServerInterceptor считает входящие unary вызовы gRPC по методу и коду ответа
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+
+		grpcServerHandled.WithLabelValues(info.FullMethod, status.Code(err).String()).Inc()
+		grpcServerDuration.WithLabelValues(info.FullMethod).Observe(time.Since(start).Seconds())
+		return resp, err
+	}
+}
+
+// UnaryClientInterceptor считает исходящие unary вызовы gRPC по методу и коду ответа
+func UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		start := time.Now()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+
+		grpcClientHandled.WithLabelValues(method, status.Code(err).String()).Inc()
+		grpcClientDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+		return err
+	}
+}
+
+// RegisterDB регистрирует метрики пула соединений db (sql.DBStats) с меткой db_name
+func RegisterDB(db *sql.DB, name string) error {
+	return prometheus.Register(collectors.NewDBStatsCollector(db, name))
+}
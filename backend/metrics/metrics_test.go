@@ -0,0 +1,82 @@
+package metrics_test
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"forum/backend/metrics"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func newRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(metrics.HTTPMiddleware())
+	router.GET("/metrics", metrics.Handler())
+	router.GET("/topics/:topic_id", func(c *gin.Context) { c.Status(http.StatusOK) })
+	return router
+}
+
+func scrape(t *testing.T, router *gin.Engine) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	return rec.Body.String()
+}
+
+func TestHTTPMiddleware(t *testing.T) {
+	router := newRouter()
+	for _, path := range []string{"/topics/1", "/topics/2", "/no/such/path"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	body := scrape(t, router)
+	// Запросы к разным топикам попадают в один ряд шаблона маршрута
+	assert.Contains(t, body, `http_requests_total{method="GET",route="/topics/:topic_id",status="200"} 2`)
+	assert.Contains(t, body, `http_requests_total{method="GET",route="unmatched",status="404"} 1`)
+	assert.Contains(t, body, `http_request_duration_seconds_count{method="GET",route="/topics/:topic_id",status="200"} 2`)
+	assert.NotContains(t, body, "/no/such/path")
+}
+
+func TestGRPCInterceptors(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	srv := grpc.NewServer(grpc.UnaryInterceptor(metrics.UnaryServerInterceptor()))
+	healthpb.RegisterHealthServer(srv, grpchealth.NewServer())
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	conn, err := grpc.NewClient(lis.Addr().String(),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUnaryInterceptor(metrics.UnaryClientInterceptor()))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	client := healthpb.NewHealthClient(conn)
+	_, err = client.Check(ctx, &healthpb.HealthCheckRequest{})
+	require.NoError(t, err)
+	_, err = client.Check(ctx, &healthpb.HealthCheckRequest{Service: "unknown"})
+	require.Error(t, err)
+
+	body := scrape(t, newRouter())
+	const method = `method="/grpc.health.v1.Health/Check"`
+	assert.Contains(t, body, `grpc_server_handled_total{code="OK",`+method+`} 1`)
+	assert.Contains(t, body, `grpc_server_handled_total{code="NotFound",`+method+`} 1`)
+	assert.Contains(t, body, `grpc_client_handled_total{code="OK",`+method+`} 1`)
+	assert.Contains(t, body, `grpc_client_handling_seconds_count{`+method+`} 2`)
+}
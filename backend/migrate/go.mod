@@ -1,14 +1,19 @@
 module migrate
 
-go 1.21
+go 1.23.6
 
 require (
+	forum v0.0.0
 	github.com/golang-migrate/migrate/v4 v4.17.0
+	github.com/lib/pq v1.10.9
 )
 
 require (
 	github.com/hashicorp/errwrap v1.1.0 // indirect
 	github.com/hashicorp/go-multierror v1.1.1 // indirect
-	github.com/lib/pq v1.10.9 // indirect
+	github.com/pelletier/go-toml/v2 v2.2.3 // indirect
 	go.uber.org/atomic v1.7.0 // indirect
-) 
\ No newline at end of file
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
+
+replace forum => ../..
@@ -110,6 +110,350 @@ func (x *UserResponse) GetUserName() string {
 	return ""
 }
 
+type UserNamesRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UserIds       []int32                `protobuf:"varint,1,rep,packed,name=user_ids,json=userIds,proto3" json:"user_ids,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UserNamesRequest) Reset() {
+	*x = UserNamesRequest{}
+	mi := &file_user_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UserNamesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UserNamesRequest) ProtoMessage() {}
+
+func (x *UserNamesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_user_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UserNamesRequest.ProtoReflect.Descriptor instead.
+func (*UserNamesRequest) Descriptor() ([]byte, []int) {
+	return file_user_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *UserNamesRequest) GetUserIds() []int32 {
+	if x != nil {
+		return x.UserIds
+	}
+	return nil
+}
+
+// Пользователи, которых нет в базе, в ответ не попадают.
+// Для удаленных пользователей возвращается "[deleted user]"
+type UserNamesResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UserNames     map[int32]string       `protobuf:"bytes,1,rep,name=user_names,json=userNames,proto3" json:"user_names,omitempty" protobuf_key:"varint,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UserNamesResponse) Reset() {
+	*x = UserNamesResponse{}
+	mi := &file_user_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UserNamesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UserNamesResponse) ProtoMessage() {}
+
+func (x *UserNamesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_user_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UserNamesResponse.ProtoReflect.Descriptor instead.
+func (*UserNamesResponse) Descriptor() ([]byte, []int) {
+	return file_user_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *UserNamesResponse) GetUserNames() map[int32]string {
+	if x != nil {
+		return x.UserNames
+	}
+	return nil
+}
+
+type InvalidateUserNameResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *InvalidateUserNameResponse) Reset() {
+	*x = InvalidateUserNameResponse{}
+	mi := &file_user_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *InvalidateUserNameResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*InvalidateUserNameResponse) ProtoMessage() {}
+
+func (x *InvalidateUserNameResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_user_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use InvalidateUserNameResponse.ProtoReflect.Descriptor instead.
+func (*InvalidateUserNameResponse) Descriptor() ([]byte, []int) {
+	return file_user_proto_rawDescGZIP(), []int{4}
+}
+
+type UserDeletedRequest struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	UserId int32                  `protobuf:"varint,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	// true, если срок восстановления истек и данные пользователя обезличены
+	Anonymized    bool `protobuf:"varint,2,opt,name=anonymized,proto3" json:"anonymized,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UserDeletedRequest) Reset() {
+	*x = UserDeletedRequest{}
+	mi := &file_user_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UserDeletedRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UserDeletedRequest) ProtoMessage() {}
+
+func (x *UserDeletedRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_user_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UserDeletedRequest.ProtoReflect.Descriptor instead.
+func (*UserDeletedRequest) Descriptor() ([]byte, []int) {
+	return file_user_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *UserDeletedRequest) GetUserId() int32 {
+	if x != nil {
+		return x.UserId
+	}
+	return 0
+}
+
+func (x *UserDeletedRequest) GetAnonymized() bool {
+	if x != nil {
+		return x.Anonymized
+	}
+	return false
+}
+
+type UserDeletedResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UserDeletedResponse) Reset() {
+	*x = UserDeletedResponse{}
+	mi := &file_user_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UserDeletedResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UserDeletedResponse) ProtoMessage() {}
+
+func (x *UserDeletedResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_user_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UserDeletedResponse.ProtoReflect.Descriptor instead.
+func (*UserDeletedResponse) Descriptor() ([]byte, []int) {
+	return file_user_proto_rawDescGZIP(), []int{6}
+}
+
+type ValidateTokenRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Token         string                 `protobuf:"bytes,1,opt,name=token,proto3" json:"token,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ValidateTokenRequest) Reset() {
+	*x = ValidateTokenRequest{}
+	mi := &file_user_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ValidateTokenRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ValidateTokenRequest) ProtoMessage() {}
+
+func (x *ValidateTokenRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_user_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ValidateTokenRequest.ProtoReflect.Descriptor instead.
+func (*ValidateTokenRequest) Descriptor() ([]byte, []int) {
+	return file_user_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *ValidateTokenRequest) GetToken() string {
+	if x != nil {
+		return x.Token
+	}
+	return ""
+}
+
+type ValidateTokenResponse struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	UserId   int32                  `protobuf:"varint,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	UserName string                 `protobuf:"bytes,2,opt,name=user_name,json=userName,proto3" json:"user_name,omitempty"`
+	IsAdmin  bool                   `protobuf:"varint,3,opt,name=is_admin,json=isAdmin,proto3" json:"is_admin,omitempty"`
+	Role     string                 `protobuf:"bytes,4,opt,name=role,proto3" json:"role,omitempty"`
+	// Права роли из таблицы role_permissions
+	Permissions   []string `protobuf:"bytes,5,rep,name=permissions,proto3" json:"permissions,omitempty"`
+	EmailVerified bool     `protobuf:"varint,6,opt,name=email_verified,json=emailVerified,proto3" json:"email_verified,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ValidateTokenResponse) Reset() {
+	*x = ValidateTokenResponse{}
+	mi := &file_user_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ValidateTokenResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ValidateTokenResponse) ProtoMessage() {}
+
+func (x *ValidateTokenResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_user_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ValidateTokenResponse.ProtoReflect.Descriptor instead.
+func (*ValidateTokenResponse) Descriptor() ([]byte, []int) {
+	return file_user_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *ValidateTokenResponse) GetUserId() int32 {
+	if x != nil {
+		return x.UserId
+	}
+	return 0
+}
+
+func (x *ValidateTokenResponse) GetUserName() string {
+	if x != nil {
+		return x.UserName
+	}
+	return ""
+}
+
+func (x *ValidateTokenResponse) GetIsAdmin() bool {
+	if x != nil {
+		return x.IsAdmin
+	}
+	return false
+}
+
+func (x *ValidateTokenResponse) GetRole() string {
+	if x != nil {
+		return x.Role
+	}
+	return ""
+}
+
+func (x *ValidateTokenResponse) GetPermissions() []string {
+	if x != nil {
+		return x.Permissions
+	}
+	return nil
+}
+
+func (x *ValidateTokenResponse) GetEmailVerified() bool {
+	if x != nil {
+		return x.EmailVerified
+	}
+	return false
+}
+
 // Новые сообщения для статистики комментариев
 type UserCommentsRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
@@ -120,7 +464,7 @@ type UserCommentsRequest struct {
 
 func (x *UserCommentsRequest) Reset() {
 	*x = UserCommentsRequest{}
-	mi := &file_user_proto_msgTypes[2]
+	mi := &file_user_proto_msgTypes[9]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -132,7 +476,7 @@ func (x *UserCommentsRequest) String() string {
 func (*UserCommentsRequest) ProtoMessage() {}
 
 func (x *UserCommentsRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_user_proto_msgTypes[2]
+	mi := &file_user_proto_msgTypes[9]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -145,7 +489,7 @@ func (x *UserCommentsRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use UserCommentsRequest.ProtoReflect.Descriptor instead.
 func (*UserCommentsRequest) Descriptor() ([]byte, []int) {
-	return file_user_proto_rawDescGZIP(), []int{2}
+	return file_user_proto_rawDescGZIP(), []int{9}
 }
 
 func (x *UserCommentsRequest) GetUserId() int32 {
@@ -167,7 +511,7 @@ type Comment struct {
 
 func (x *Comment) Reset() {
 	*x = Comment{}
-	mi := &file_user_proto_msgTypes[3]
+	mi := &file_user_proto_msgTypes[10]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -179,7 +523,7 @@ func (x *Comment) String() string {
 func (*Comment) ProtoMessage() {}
 
 func (x *Comment) ProtoReflect() protoreflect.Message {
-	mi := &file_user_proto_msgTypes[3]
+	mi := &file_user_proto_msgTypes[10]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -192,7 +536,7 @@ func (x *Comment) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Comment.ProtoReflect.Descriptor instead.
 func (*Comment) Descriptor() ([]byte, []int) {
-	return file_user_proto_rawDescGZIP(), []int{3}
+	return file_user_proto_rawDescGZIP(), []int{10}
 }
 
 func (x *Comment) GetId() int32 {
@@ -232,7 +576,7 @@ type UserCommentsResponse struct {
 
 func (x *UserCommentsResponse) Reset() {
 	*x = UserCommentsResponse{}
-	mi := &file_user_proto_msgTypes[4]
+	mi := &file_user_proto_msgTypes[11]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -244,7 +588,7 @@ func (x *UserCommentsResponse) String() string {
 func (*UserCommentsResponse) ProtoMessage() {}
 
 func (x *UserCommentsResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_user_proto_msgTypes[4]
+	mi := &file_user_proto_msgTypes[11]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -257,7 +601,7 @@ func (x *UserCommentsResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use UserCommentsResponse.ProtoReflect.Descriptor instead.
 func (*UserCommentsResponse) Descriptor() ([]byte, []int) {
-	return file_user_proto_rawDescGZIP(), []int{4}
+	return file_user_proto_rawDescGZIP(), []int{11}
 }
 
 func (x *UserCommentsResponse) GetComments() []*Comment {
@@ -276,7 +620,31 @@ const file_user_proto_rawDesc = "" +
 	"\vUserRequest\x12\x17\n" +
 	"\auser_id\x18\x01 \x01(\x05R\x06userId\"+\n" +
 	"\fUserResponse\x12\x1b\n" +
-	"\tuser_name\x18\x01 \x01(\tR\buserName\".\n" +
+	"\tuser_name\x18\x01 \x01(\tR\buserName\"-\n" +
+	"\x10UserNamesRequest\x12\x19\n" +
+	"\buser_ids\x18\x01 \x03(\x05R\auserIds\"\x99\x01\n" +
+	"\x11UserNamesResponse\x12F\n" +
+	"\n" +
+	"user_names\x18\x01 \x03(\v2'.proto.UserNamesResponse.UserNamesEntryR\tuserNames\x1a<\n" +
+	"\x0eUserNamesEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\x05R\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\x1c\n" +
+	"\x1aInvalidateUserNameResponse\"M\n" +
+	"\x12UserDeletedRequest\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\x05R\x06userId\x12\x1e\n" +
+	"\n" +
+	"anonymized\x18\x02 \x01(\bR\n" +
+	"anonymized\"\x15\n" +
+	"\x13UserDeletedResponse\",\n" +
+	"\x14ValidateTokenRequest\x12\x14\n" +
+	"\x05token\x18\x01 \x01(\tR\x05token\"\xc5\x01\n" +
+	"\x15ValidateTokenResponse\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\x05R\x06userId\x12\x1b\n" +
+	"\tuser_name\x18\x02 \x01(\tR\buserName\x12\x19\n" +
+	"\bis_admin\x18\x03 \x01(\bR\aisAdmin\x12\x12\n" +
+	"\x04role\x18\x04 \x01(\tR\x04role\x12 \n" +
+	"\vpermissions\x18\x05 \x03(\tR\vpermissions\x12%\n" +
+	"\x0eemail_verified\x18\x06 \x01(\bR\remailVerified\".\n" +
 	"\x13UserCommentsRequest\x12\x17\n" +
 	"\auser_id\x18\x01 \x01(\x05R\x06userId\"m\n" +
 	"\aComment\x12\x0e\n" +
@@ -286,11 +654,15 @@ const file_user_proto_rawDesc = "" +
 	"\n" +
 	"created_at\x18\x04 \x01(\tR\tcreatedAt\"B\n" +
 	"\x14UserCommentsResponse\x12*\n" +
-	"\bcomments\x18\x01 \x03(\v2\x0e.proto.CommentR\bcomments2E\n" +
+	"\bcomments\x18\x01 \x03(\v2\x0e.proto.CommentR\bcomments2\xd4\x01\n" +
 	"\vAuthService\x126\n" +
-	"\vGetUserName\x12\x12.proto.UserRequest\x1a\x13.proto.UserResponse2\\\n" +
+	"\vGetUserName\x12\x12.proto.UserRequest\x1a\x13.proto.UserResponse\x12A\n" +
+	"\fGetUserNames\x12\x17.proto.UserNamesRequest\x1a\x18.proto.UserNamesResponse\x12J\n" +
+	"\rValidateToken\x12\x1b.proto.ValidateTokenRequest\x1a\x1c.proto.ValidateTokenResponse2\xef\x01\n" +
 	"\x0eBackendService\x12J\n" +
-	"\x0fGetUserComments\x12\x1a.proto.UserCommentsRequest\x1a\x1b.proto.UserCommentsResponseB\x18Z\x16forum/protos/go/userpbb\x06proto3"
+	"\x0fGetUserComments\x12\x1a.proto.UserCommentsRequest\x1a\x1b.proto.UserCommentsResponse\x12K\n" +
+	"\x12InvalidateUserName\x12\x12.proto.UserRequest\x1a!.proto.InvalidateUserNameResponse\x12D\n" +
+	"\vUserDeleted\x12\x19.proto.UserDeletedRequest\x1a\x1a.proto.UserDeletedResponseB\x18Z\x16forum/protos/go/userpbb\x06proto3"
 
 var (
 	file_user_proto_rawDescOnce sync.Once
@@ -304,25 +676,42 @@ func file_user_proto_rawDescGZIP() []byte {
 	return file_user_proto_rawDescData
 }
 
-var file_user_proto_msgTypes = make([]protoimpl.MessageInfo, 5)
+var file_user_proto_msgTypes = make([]protoimpl.MessageInfo, 13)
 var file_user_proto_goTypes = []any{
-	(*UserRequest)(nil),          // 0: proto.UserRequest
-	(*UserResponse)(nil),         // 1: proto.UserResponse
-	(*UserCommentsRequest)(nil),  // 2: proto.UserCommentsRequest
-	(*Comment)(nil),              // 3: proto.Comment
-	(*UserCommentsResponse)(nil), // 4: proto.UserCommentsResponse
+	(*UserRequest)(nil),                // 0: proto.UserRequest
+	(*UserResponse)(nil),               // 1: proto.UserResponse
+	(*UserNamesRequest)(nil),           // 2: proto.UserNamesRequest
+	(*UserNamesResponse)(nil),          // 3: proto.UserNamesResponse
+	(*InvalidateUserNameResponse)(nil), // 4: proto.InvalidateUserNameResponse
+	(*UserDeletedRequest)(nil),         // 5: proto.UserDeletedRequest
+	(*UserDeletedResponse)(nil),        // 6: proto.UserDeletedResponse
+	(*ValidateTokenRequest)(nil),       // 7: proto.ValidateTokenRequest
+	(*ValidateTokenResponse)(nil),      // 8: proto.ValidateTokenResponse
+	(*UserCommentsRequest)(nil),        // 9: proto.UserCommentsRequest
+	(*Comment)(nil),                    // 10: proto.Comment
+	(*UserCommentsResponse)(nil),       // 11: proto.UserCommentsResponse
+	nil,                                // 12: proto.UserNamesResponse.UserNamesEntry
 }
 var file_user_proto_depIdxs = []int32{
-	3, // 0: proto.UserCommentsResponse.comments:type_name -> proto.Comment
-	0, // 1: proto.AuthService.GetUserName:input_type -> proto.UserRequest
-	2, // 2: proto.BackendService.GetUserComments:input_type -> proto.UserCommentsRequest
-	1, // 3: proto.AuthService.GetUserName:output_type -> proto.UserResponse
-	4, // 4: proto.BackendService.GetUserComments:output_type -> proto.UserCommentsResponse
-	3, // [3:5] is the sub-list for method output_type
-	1, // [1:3] is the sub-list for method input_type
-	1, // [1:1] is the sub-list for extension type_name
-	1, // [1:1] is the sub-list for extension extendee
-	0, // [0:1] is the sub-list for field type_name
+	12, // 0: proto.UserNamesResponse.user_names:type_name -> proto.UserNamesResponse.UserNamesEntry
+	10, // 1: proto.UserCommentsResponse.comments:type_name -> proto.Comment
+	0,  // 2: proto.AuthService.GetUserName:input_type -> proto.UserRequest
+	2,  // 3: proto.AuthService.GetUserNames:input_type -> proto.UserNamesRequest
+	7,  // 4: proto.AuthService.ValidateToken:input_type -> proto.ValidateTokenRequest
+	9,  // 5: proto.BackendService.GetUserComments:input_type -> proto.UserCommentsRequest
+	0,  // 6: proto.BackendService.InvalidateUserName:input_type -> proto.UserRequest
+	5,  // 7: proto.BackendService.UserDeleted:input_type -> proto.UserDeletedRequest
+	1,  // 8: proto.AuthService.GetUserName:output_type -> proto.UserResponse
+	3,  // 9: proto.AuthService.GetUserNames:output_type -> proto.UserNamesResponse
+	8,  // 10: proto.AuthService.ValidateToken:output_type -> proto.ValidateTokenResponse
+	11, // 11: proto.BackendService.GetUserComments:output_type -> proto.UserCommentsResponse
+	4,  // 12: proto.BackendService.InvalidateUserName:output_type -> proto.InvalidateUserNameResponse
+	6,  // 13: proto.BackendService.UserDeleted:output_type -> proto.UserDeletedResponse
+	8,  // [8:14] is the sub-list for method output_type
+	2,  // [2:8] is the sub-list for method input_type
+	2,  // [2:2] is the sub-list for extension type_name
+	2,  // [2:2] is the sub-list for extension extendee
+	0,  // [0:2] is the sub-list for field type_name
 }
 
 func init() { file_user_proto_init() }
@@ -336,7 +725,7 @@ func file_user_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_user_proto_rawDesc), len(file_user_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   5,
+			NumMessages:   13,
 			NumExtensions: 0,
 			NumServices:   2,
 		},
@@ -19,7 +19,9 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	AuthService_GetUserName_FullMethodName = "/proto.AuthService/GetUserName"
+	AuthService_GetUserName_FullMethodName   = "/proto.AuthService/GetUserName"
+	AuthService_GetUserNames_FullMethodName  = "/proto.AuthService/GetUserNames"
+	AuthService_ValidateToken_FullMethodName = "/proto.AuthService/ValidateToken"
 )
 
 // AuthServiceClient is the client API for AuthService service.
@@ -29,6 +31,10 @@ const (
 // Существующий сервис auth
 type AuthServiceClient interface {
 	GetUserName(ctx context.Context, in *UserRequest, opts ...grpc.CallOption) (*UserResponse, error)
+	// Имена нескольких пользователей за один вызов
+	GetUserNames(ctx context.Context, in *UserNamesRequest, opts ...grpc.CallOption) (*UserNamesResponse, error)
+	// Проверяет JWT и возвращает данные его владельца
+	ValidateToken(ctx context.Context, in *ValidateTokenRequest, opts ...grpc.CallOption) (*ValidateTokenResponse, error)
 }
 
 type authServiceClient struct {
@@ -49,6 +55,26 @@ func (c *authServiceClient) GetUserName(ctx context.Context, in *UserRequest, op
 	return out, nil
 }
 
+func (c *authServiceClient) GetUserNames(ctx context.Context, in *UserNamesRequest, opts ...grpc.CallOption) (*UserNamesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(UserNamesResponse)
+	err := c.cc.Invoke(ctx, AuthService_GetUserNames_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *authServiceClient) ValidateToken(ctx context.Context, in *ValidateTokenRequest, opts ...grpc.CallOption) (*ValidateTokenResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ValidateTokenResponse)
+	err := c.cc.Invoke(ctx, AuthService_ValidateToken_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // AuthServiceServer is the server API for AuthService service.
 // All implementations must embed UnimplementedAuthServiceServer
 // for forward compatibility.
@@ -56,6 +82,10 @@ func (c *authServiceClient) GetUserName(ctx context.Context, in *UserRequest, op
 // Существующий сервис auth
 type AuthServiceServer interface {
 	GetUserName(context.Context, *UserRequest) (*UserResponse, error)
+	// Имена нескольких пользователей за один вызов
+	GetUserNames(context.Context, *UserNamesRequest) (*UserNamesResponse, error)
+	// Проверяет JWT и возвращает данные его владельца
+	ValidateToken(context.Context, *ValidateTokenRequest) (*ValidateTokenResponse, error)
 	mustEmbedUnimplementedAuthServiceServer()
 }
 
@@ -69,6 +99,12 @@ type UnimplementedAuthServiceServer struct{}
 func (UnimplementedAuthServiceServer) GetUserName(context.Context, *UserRequest) (*UserResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetUserName not implemented")
 }
+func (UnimplementedAuthServiceServer) GetUserNames(context.Context, *UserNamesRequest) (*UserNamesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetUserNames not implemented")
+}
+func (UnimplementedAuthServiceServer) ValidateToken(context.Context, *ValidateTokenRequest) (*ValidateTokenResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ValidateToken not implemented")
+}
 func (UnimplementedAuthServiceServer) mustEmbedUnimplementedAuthServiceServer() {}
 func (UnimplementedAuthServiceServer) testEmbeddedByValue()                     {}
 
@@ -108,6 +144,42 @@ func _AuthService_GetUserName_Handler(srv interface{}, ctx context.Context, dec
 	return interceptor(ctx, in, info, handler)
 }
 
+func _AuthService_GetUserNames_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UserNamesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthServiceServer).GetUserNames(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AuthService_GetUserNames_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthServiceServer).GetUserNames(ctx, req.(*UserNamesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AuthService_ValidateToken_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ValidateTokenRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthServiceServer).ValidateToken(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AuthService_ValidateToken_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthServiceServer).ValidateToken(ctx, req.(*ValidateTokenRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // AuthService_ServiceDesc is the grpc.ServiceDesc for AuthService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -119,22 +191,36 @@ var AuthService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetUserName",
 			Handler:    _AuthService_GetUserName_Handler,
 		},
+		{
+			MethodName: "GetUserNames",
+			Handler:    _AuthService_GetUserNames_Handler,
+		},
+		{
+			MethodName: "ValidateToken",
+			Handler:    _AuthService_ValidateToken_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "user.proto",
 }
 
 const (
-	BackendService_GetUserComments_FullMethodName = "/proto.BackendService/GetUserComments"
+	BackendService_GetUserComments_FullMethodName    = "/proto.BackendService/GetUserComments"
+	BackendService_InvalidateUserName_FullMethodName = "/proto.BackendService/InvalidateUserName"
+	BackendService_UserDeleted_FullMethodName        = "/proto.BackendService/UserDeleted"
 )
 
 // BackendServiceClient is the client API for BackendService service.
 //
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 //
-// Новый сервис для forum
+// Новый сервис для backend
 type BackendServiceClient interface {
 	GetUserComments(ctx context.Context, in *UserCommentsRequest, opts ...grpc.CallOption) (*UserCommentsResponse, error)
+	// Сбрасывает закешированное имя пользователя после переименования
+	InvalidateUserName(ctx context.Context, in *UserRequest, opts ...grpc.CallOption) (*InvalidateUserNameResponse, error)
+	// Сообщает, что пользователь удален и его имя нужно скрыть
+	UserDeleted(ctx context.Context, in *UserDeletedRequest, opts ...grpc.CallOption) (*UserDeletedResponse, error)
 }
 
 type backendServiceClient struct {
@@ -155,13 +241,37 @@ func (c *backendServiceClient) GetUserComments(ctx context.Context, in *UserComm
 	return out, nil
 }
 
+func (c *backendServiceClient) InvalidateUserName(ctx context.Context, in *UserRequest, opts ...grpc.CallOption) (*InvalidateUserNameResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(InvalidateUserNameResponse)
+	err := c.cc.Invoke(ctx, BackendService_InvalidateUserName_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *backendServiceClient) UserDeleted(ctx context.Context, in *UserDeletedRequest, opts ...grpc.CallOption) (*UserDeletedResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(UserDeletedResponse)
+	err := c.cc.Invoke(ctx, BackendService_UserDeleted_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // BackendServiceServer is the server API for BackendService service.
 // All implementations must embed UnimplementedBackendServiceServer
 // for forward compatibility.
 //
-// Новый сервис для forum
+// Новый сервис для backend
 type BackendServiceServer interface {
 	GetUserComments(context.Context, *UserCommentsRequest) (*UserCommentsResponse, error)
+	// Сбрасывает закешированное имя пользователя после переименования
+	InvalidateUserName(context.Context, *UserRequest) (*InvalidateUserNameResponse, error)
+	// Сообщает, что пользователь удален и его имя нужно скрыть
+	UserDeleted(context.Context, *UserDeletedRequest) (*UserDeletedResponse, error)
 	mustEmbedUnimplementedBackendServiceServer()
 }
 
@@ -175,6 +285,12 @@ type UnimplementedBackendServiceServer struct{}
 func (UnimplementedBackendServiceServer) GetUserComments(context.Context, *UserCommentsRequest) (*UserCommentsResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetUserComments not implemented")
 }
+func (UnimplementedBackendServiceServer) InvalidateUserName(context.Context, *UserRequest) (*InvalidateUserNameResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method InvalidateUserName not implemented")
+}
+func (UnimplementedBackendServiceServer) UserDeleted(context.Context, *UserDeletedRequest) (*UserDeletedResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UserDeleted not implemented")
+}
 func (UnimplementedBackendServiceServer) mustEmbedUnimplementedBackendServiceServer() {}
 func (UnimplementedBackendServiceServer) testEmbeddedByValue()                        {}
 
@@ -214,6 +330,42 @@ func _BackendService_GetUserComments_Handler(srv interface{}, ctx context.Contex
 	return interceptor(ctx, in, info, handler)
 }
 
+func _BackendService_InvalidateUserName_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UserRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BackendServiceServer).InvalidateUserName(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: BackendService_InvalidateUserName_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BackendServiceServer).InvalidateUserName(ctx, req.(*UserRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BackendService_UserDeleted_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UserDeletedRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BackendServiceServer).UserDeleted(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: BackendService_UserDeleted_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BackendServiceServer).UserDeleted(ctx, req.(*UserDeletedRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // BackendService_ServiceDesc is the grpc.ServiceDesc for BackendService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -225,6 +377,14 @@ var BackendService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetUserComments",
 			Handler:    _BackendService_GetUserComments_Handler,
 		},
+		{
+			MethodName: "InvalidateUserName",
+			Handler:    _BackendService_InvalidateUserName_Handler,
+		},
+		{
+			MethodName: "UserDeleted",
+			Handler:    _BackendService_UserDeleted_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "user.proto",
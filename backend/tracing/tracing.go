@@ -0,0 +1,132 @@
+// Package tracing настраивает распределенную трассировку OpenTelemetry: экспорт спанов,
+// передачу контекста между сервисами и инструментацию Gin, gRPC и database/sql.
+package tracing
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+
+	"github.com/XSAM/otelsql"
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+)
+
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterOTLP   = "otlp"
+)
+
+// Config - настройки экспорта спанов, общие для всех сервисов
+type Config struct {
+	Exporter string `config:"exporter" env:"TRACING_EXPORTER" flag:"tracing-exporter" default:"none" usage:"экспорт трассировки: none, stdout или otlp"`
+	// OTLPEndpoint - адрес OTLP/gRPC коллектора в виде host:port
+	OTLPEndpoint string `config:"otlp_endpoint" env:"TRACING_OTLP_ENDPOINT" default:"localhost:4317"`
+	OTLPInsecure bool   `config:"otlp_insecure" env:"TRACING_OTLP_INSECURE" default:"true"`
+}
+
+// Validate проверяет способ экспорта
+func (c *Config) Validate() error {
+	switch c.Exporter {
+	case ExporterNone, ExporterStdout, ExporterOTLP:
+		return nil
+	default:
+		return fmt.Errorf("tracing.exporter (TRACING_EXPORTER): неизвестный способ экспорта %q", c.Exporter)
+	}
+}
+
+// Setup настраивает глобальный TracerProvider сервиса service и возвращает функцию,
+// которая отправляет оставшиеся спаны и останавливает экспорт. При Exporter=none
+// спаны не записываются, но контекст трассировки по-прежнему передается дальше
+func Setup(ctx context.Context, service string, cfg Config) (func(context.Context) error, error) {
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch cfg.Exporter {
+	case ExporterNone:
+		otel.SetTextMapPropagator(propagator())
+		return func(context.Context) error { return nil }, nil
+	case ExporterStdout:
+		exporter, err = stdouttrace.New()
+	case ExporterOTLP:
+		opts := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(cfg.OTLPEndpoint)}
+		if cfg.OTLPInsecure {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+		exporter, err = otlptracegrpc.New(ctx, opts...)
+	default:
+		return nil, fmt.Errorf("неизвестный способ экспорта %q", cfg.Exporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("не удалось создать экспорт спанов: %w", err)
+	}
+
+	tp := NewProvider(service, sdktrace.WithBatcher(exporter))
+	Install(tp)
+	return tp.Shutdown, nil
+}
+
+// NewProvider создает TracerProvider с именем сервиса в ресурсе.
+// В тестах спаны можно собирать через sdktrace.WithSyncer(tracetest.NewInMemoryExporter())
+func NewProvider(service string, opts ...sdktrace.TracerProviderOption) *sdktrace.TracerProvider {
+	opts = append([]sdktrace.TracerProviderOption{
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(service))),
+	}, opts...)
+	return sdktrace.NewTracerProvider(opts...)
+}
+
+// Install делает tp глобальным TracerProvider и включает передачу контекста
+// в заголовках W3C traceparent и baggage
+func Install(tp trace.TracerProvider) {
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagator())
+}
+
+func propagator() propagation.TextMapPropagator {
+	return propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+}
+
+// HTTPMiddleware создает спан для каждого HTTP запроса. Имя спана - шаблон маршрута
+func HTTPMiddleware(service string) gin.HandlerFunc {
+	return otelgin.Middleware(service)
+}
+
+// ServerOption создает спаны для входящих вызовов gRPC, продолжая трассировку вызывающего
+func ServerOption() grpc.ServerOption {
+	return grpc.StatsHandler(otelgrpc.NewServerHandler())
+}
+
+// DialOption создает спаны для исходящих вызовов gRPC и передает контекст трассировки серверу
+func DialOption() grpc.DialOption {
+	return grpc.WithStatsHandler(otelgrpc.NewClientHandler())
+}
+
+// OpenDB открывает БД через driverName с трассировкой запросов. Модели передают в запросы
+// контекст HTTP или gRPC вызова, поэтому запросы попадают в его трассировку. Спан создается
+// только для контекста, уже входящего в трассировку: фоновые задачи (политики хранения,
+// обезличивание пользователей) иначе порождали бы отдельную трассировку на каждый запрос
+func OpenDB(driverName, dsn string) (*sql.DB, error) {
+	return otelsql.Open(driverName, dsn,
+		otelsql.WithAttributes(semconv.DBSystemPostgreSQL),
+		otelsql.WithSpanOptions(otelsql.SpanOptions{
+			OmitConnResetSession: true,
+			OmitConnPrepare:      true,
+			OmitRows:             true,
+			SpanFilter:           inTrace,
+		}))
+}
+
+func inTrace(ctx context.Context, _ otelsql.Method, _ string, _ []driver.NamedValue) bool {
+	return trace.SpanContextFromContext(ctx).IsValid()
+}
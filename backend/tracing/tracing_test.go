@@ -0,0 +1,160 @@
+package tracing_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"forum/backend/tracing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// install подключает TracerProvider с экспортом в память на время теста
+func install(t *testing.T) *tracetest.InMemoryExporter {
+	t.Helper()
+	exporter := tracetest.NewInMemoryExporter()
+	tp := tracing.NewProvider("test", sdktrace.WithSyncer(exporter))
+	tracing.Install(tp)
+	t.Cleanup(func() {
+		tp.Shutdown(context.Background())
+		tracing.Install(noop.NewTracerProvider())
+	})
+	return exporter
+}
+
+func spanByName(t *testing.T, spans tracetest.SpanStubs, name string) tracetest.SpanStub {
+	t.Helper()
+	for _, s := range spans {
+		if s.Name == name {
+			return s
+		}
+	}
+	t.Fatalf("спан %q не найден среди %d спанов", name, len(spans))
+	return tracetest.SpanStub{}
+}
+
+func TestPropagationAcrossHTTPAndGRPC(t *testing.T) {
+	exporter := install(t)
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	srv := grpc.NewServer(tracing.ServerOption())
+	healthpb.RegisterHealthServer(srv, grpchealth.NewServer())
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	conn, err := grpc.NewClient(lis.Addr().String(),
+		grpc.WithTransportCredentials(insecure.NewCredentials()), tracing.DialOption())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// Обработчик вызывает соседний сервис, как GET /users/:user_id вызывает forum
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(tracing.HTTPMiddleware("test"))
+	router.GET("/users/:user_id", func(c *gin.Context) {
+		_, err := healthpb.NewHealthClient(conn).Check(c.Request.Context(), &healthpb.HealthCheckRequest{})
+		require.NoError(t, err)
+		c.Status(http.StatusOK)
+	})
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/users/1", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 3)
+	httpSpan := spanByName(t, spans, "/users/:user_id")
+	var client, server tracetest.SpanStub
+	for _, s := range spans {
+		switch s.SpanKind {
+		case trace.SpanKindClient:
+			client = s
+		case trace.SpanKindServer:
+			if s.Name == "grpc.health.v1.Health/Check" {
+				server = s
+			}
+		}
+	}
+
+	// Все спаны в одной трассировке: HTTP -> gRPC клиент -> gRPC сервер
+	traceID := httpSpan.SpanContext.TraceID()
+	assert.Equal(t, traceID, client.SpanContext.TraceID())
+	assert.Equal(t, traceID, server.SpanContext.TraceID())
+	assert.Equal(t, httpSpan.SpanContext.SpanID(), client.Parent.SpanID())
+	assert.Equal(t, client.SpanContext.SpanID(), server.Parent.SpanID())
+	assert.True(t, server.Parent.IsRemote())
+}
+
+func TestOpenDBSpans(t *testing.T) {
+	exporter := install(t)
+
+	sql.Register("tracing-test", fakeDriver{})
+	db, err := tracing.OpenDB("tracing-test", "")
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Запрос вне трассировки не создает спан
+	rows, err := db.QueryContext(context.Background(), "SELECT 1")
+	require.NoError(t, err)
+	rows.Close()
+	assert.Empty(t, exporter.GetSpans())
+
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "handler")
+	rows, err = db.QueryContext(ctx, "SELECT id FROM users WHERE id = $1", 1)
+	require.NoError(t, err)
+	rows.Close()
+	parent.End()
+
+	spans := exporter.GetSpans()
+	query := spanByName(t, spans, "sql.conn.query")
+	assert.Equal(t, parent.SpanContext().SpanID(), query.Parent.SpanID())
+	assert.Len(t, spans, 2)
+}
+
+func TestConfigValidate(t *testing.T) {
+	for _, exporter := range []string{tracing.ExporterNone, tracing.ExporterStdout, tracing.ExporterOTLP} {
+		cfg := tracing.Config{Exporter: exporter}
+		assert.NoError(t, cfg.Validate(), exporter)
+	}
+	cfg := tracing.Config{Exporter: "jaeger"}
+	assert.Error(t, cfg.Validate())
+}
+
+// fakeDriver - драйвер БД, возвращающий пустой результат на любой запрос
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) { return fakeConn{}, nil }
+
+type fakeConn struct{}
+
+func (fakeConn) Prepare(string) (driver.Stmt, error) { return nil, driver.ErrSkip }
+func (fakeConn) Close() error                        { return nil }
+func (fakeConn) Begin() (driver.Tx, error)           { return nil, driver.ErrSkip }
+
+func (fakeConn) QueryContext(context.Context, string, []driver.NamedValue) (driver.Rows, error) {
+	return fakeRows{}, nil
+}
+
+type fakeRows struct{}
+
+func (fakeRows) Columns() []string         { return []string{"id"} }
+func (fakeRows) Close() error              { return nil }
+func (fakeRows) Next([]driver.Value) error { return io.EOF }